@@ -0,0 +1,47 @@
+//
+// Rover - Cache commands
+// * Manages the provider plugin cache shared by all stacks
+//
+
+package cmd
+
+import (
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/aztfmod/rover/pkg/terraform"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:         "cache",
+	Short:       "Manage the provider plugin cache",
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old provider versions from the plugin cache",
+	Long:  `Removes all but the newest version of each provider from the plugin cache, or everything with --all`,
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cacheDir, err := terraform.PluginCacheDir()
+		cobra.CheckErr(err)
+		console.Infof("Pruning plugin cache %s\n", cacheDir)
+
+		removed, err := terraform.PrunePluginCache(all, dryRun)
+		cobra.CheckErr(err)
+		console.Successf("Pruned %d provider version(s)\n", len(removed))
+	},
+}
+
+func init() {
+	cachePruneCmd.Flags().Bool("all", false, "Remove every provider from the cache")
+	cachePruneCmd.Flags().BoolP("dry-run", "d", false, "List what would be removed without removing anything")
+
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,60 @@
+//
+// Rover - Drift command
+// * Runs a refresh-only plan against deployed stacks and reports resources changed outside of Terraform
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+// driftExitCode is returned when drift is found, so pipelines can tell it apart from a failure
+const driftExitCode = 2
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Detect changes made outside of terraform",
+	Long: `Runs a refresh-only plan against deployed stacks, no state is written or locked.
+When using a config file, omit --level to check all stacks.
+Exits with code 2 when drift is detected, and 1 on any other failure`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		outFile, _ := cmd.Flags().GetString("out")
+
+		optionsList := buildOptionsList(cmd)
+
+		action := landingzone.NewDriftAction()
+		executeAction(action, optionsList)
+
+		console.Info("Drift report:")
+		landingzone.WriteDriftReport(console.Output, action.Report)
+
+		if outFile != "" {
+			reportJSON, err := json.MarshalIndent(action.Report, "", "  ")
+			cobra.CheckErr(err)
+			err = os.WriteFile(outFile, reportJSON, 0644)
+			cobra.CheckErr(err)
+			console.Infof("Drift report written to %s\n", outFile)
+		}
+
+		if action.Report.Drifted {
+			console.Warning("Drift was detected")
+			os.Exit(driftExitCode)
+		}
+		console.Success("No drift was detected")
+	},
+}
+
+func init() {
+	addActionFlags(driftCmd)
+	driftCmd.Flags().String("out", "", "Write the drift report as JSON to this file")
+
+	rootCmd.AddCommand(driftCmd)
+}
@@ -0,0 +1,145 @@
+//
+// Rover - Interrupt handling
+// * Ctrl-C or SIGTERM asks terraform to stop cleanly, releasing state locks. A second one forces it to stop
+// * Actions which run past their timeout are stopped in the same way, but reported as timed out with their own exit code
+//
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/aztfmod/rover/pkg/terraform"
+	"github.com/spf13/cobra"
+)
+
+// handleInterrupts starts watching for signals, the returned context is cancelled when rover is forced to stop
+func handleInterrupts() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		landingzone.SetInterrupted()
+		console.StopSpinner()
+		console.Warning("Interrupt received, waiting for terraform to stop cleanly. Interrupt again to force it to stop")
+		err := terraform.Interrupt()
+		if err != nil {
+			console.Errorf("Unable to interrupt terraform: %s\n", err)
+		}
+
+		<-signals
+		console.Error("Forcing terraform to stop, state may be left locked")
+		cancel()
+		err = terraform.Kill()
+		if err != nil {
+			console.Errorf("Unable to stop terraform: %s\n", err)
+		}
+		landingzone.ReportInterrupted()
+		os.Exit(landingzone.InterruptExitCode)
+	}()
+
+	return ctx
+}
+
+// withTimeout limits how long an action can run, when the time is up terraform is interrupted the same way as Ctrl-C
+// If it's still running after the grace period it's forced to stop, the returned cancel must be called when the action ends
+// Only one stack is stopped, the run isn't marked as interrupted
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	finished := make(chan struct{})
+
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		landingzone.SetTimedOut(timeout)
+		console.StopSpinner()
+		console.Errorf("Action timed out after %s, interrupting terraform so it can stop cleanly. It will be forced to stop if it's still running in %s\n", timeout, terraform.KillGracePeriod)
+		err := terraform.Interrupt()
+		if err != nil {
+			console.Errorf("Unable to interrupt terraform: %s\n", err)
+		}
+
+		select {
+		case <-finished:
+		case <-time.After(terraform.KillGracePeriod):
+			console.Error("Terraform did not stop in time, forcing it to stop, state may be left locked")
+			err = terraform.Kill()
+			if err != nil {
+				console.Errorf("Unable to stop terraform: %s\n", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		cancel()
+		once.Do(func() { close(finished) })
+		landingzone.ClearTimedOut()
+	}
+}
+
+// actionTimeout returns how long the action may run against the stack, zero when it isn't limited
+// Only Linux can stop terraform when the time is up, elsewhere --timeout is refused and configured timeouts are ignored
+func actionTimeout(action landingzone.Action, options landingzone.Options) (time.Duration, error) {
+	if terraform.CanInterrupt {
+		return options.TimeoutFor(action.GetName())
+	}
+	if options.Timeout > 0 {
+		return 0, fmt.Errorf("--timeout is only supported on Linux, rover can't stop terraform on %s", runtime.GOOS)
+	}
+	timeout, err := options.TimeoutFor(action.GetName())
+	if err == nil && timeout > 0 {
+		console.Warningf("Ignoring the %s timeout for %s, timeouts are only supported on Linux\n", timeout, options.StateName)
+	}
+	return 0, err
+}
+
+// executeAction runs the action against each set of options in turn, stopping early if rover is interrupted or an action times out
+func executeAction(action landingzone.Action, optionsList []landingzone.Options) {
+	ctx := handleInterrupts()
+
+	for _, options := range optionsList {
+		// Now start the action execution...
+		// If an error occurs, depend on downstream code to log messages
+		console.Infof("Executing action %s for %s\n", action.GetName(), options.StateName)
+		timeout, err := actionTimeout(action, options)
+		cobra.CheckErr(err)
+
+		actionCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			actionCtx, cancel = withTimeout(ctx, timeout)
+		}
+		err = action.Execute(actionCtx, &options)
+
+		if landingzone.Interrupted() {
+			landingzone.ReportInterrupted()
+			os.Exit(landingzone.InterruptExitCode)
+		}
+		// Terraform may have finished just as the time ran out, only a failed action is reported as timed out
+		if err != nil && landingzone.TimedOut() > 0 {
+			landingzone.ReportTimedOut()
+			os.Exit(landingzone.TimeoutExitCode)
+		}
+		cancel()
+		cobra.CheckErr(err)
+	}
+
+	if summaryAction, ok := action.(landingzone.ActionWithSummary); ok {
+		err := summaryAction.Summarize()
+		cobra.CheckErr(err)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build unit
+// +build unit
+
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Timeout_Is_Not_An_Interrupt(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 10*time.Millisecond)
+	<-ctx.Done()
+
+	// The timeout is picked up once the deadline has passed, the run isn't interrupted so later stacks still run
+	assert.Eventually(t, func() bool { return landingzone.TimedOut() == 10*time.Millisecond }, time.Second, time.Millisecond)
+	assert.False(t, landingzone.Interrupted())
+	assert.True(t, landingzone.Stopped())
+
+	// Once the action has ended the timeout isn't held against the next stack
+	cancel()
+	assert.Equal(t, time.Duration(0), landingzone.TimedOut())
+	assert.False(t, landingzone.Stopped())
+}
@@ -8,8 +8,11 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
 	"github.com/spf13/cobra"
 )
 
@@ -23,25 +26,24 @@ var lzListCmd = &cobra.Command{
 		cafEnv, _ := cmd.Flags().GetString("environment")
 		workspace, _ := cmd.Flags().GetString("workspace")
 		stateSub, _ := cmd.Flags().GetString("state-sub")
+		backendConf := setStorageBackend(cmd)
 
+		cloud := azure.NewSession(backendConf.UsesAzureADAuth())
 		if stateSub == "" {
-			sub, err := azure.GetSubscription()
+			sub, err := cloud.GetSubscription()
 			cobra.CheckErr(err)
 			stateSub = sub.ID
 		}
 
 		console.Infof("Fetching list of all deployed landing zones for level '%s' in env '%s'\n", level, cafEnv)
-		launchPadStorageID, _ := azure.FindStorageAccount(level, cafEnv, stateSub)
-		if launchPadStorageID == "" {
-			console.Error("Unable to locate the launchapd storage account for this level and env")
-			cobra.CheckErr("Leaving now...")
-		}
-
-		console.Successf("Located launchpad storage account:\n%s\n", launchPadStorageID)
+		launchPadStorageID := findLaunchpadStorage(cloud, backendConf, level, cafEnv, stateSub)
 		console.Warning("Landing zones:")
-		blobs, err := azure.ListBlobs(launchPadStorageID, workspace)
+		blobs, err := cloud.ListBlobs(launchPadStorageID, workspace)
 		cobra.CheckErr(err)
 		for _, blob := range blobs {
+			if strings.HasPrefix(blob.Name, landingzone.SnapshotsPrefix) {
+				continue
+			}
 			name := blob.Name[:len(blob.Name)-8]
 			console.Warningf(" - %s\t%dkb\t%v\n", name, *blob.Properties.ContentLength/1024, blob.Properties.LastModified)
 		}
@@ -53,6 +55,9 @@ func init() {
 	lzListCmd.Flags().StringP("environment", "e", "sandpit", "Name of CAF environment")
 	lzListCmd.Flags().StringP("workspace", "w", "tfstate", "Name of workspace")
 	lzListCmd.Flags().String("state-sub", "", "Azure subscription ID where state is held")
+	lzListCmd.Flags().Bool("azuread-auth", false, "Access state with the signed in identity rather than storage account keys")
+	addLaunchpadTagFlag(lzListCmd)
+	lzListCmd.Flags().String("state-storage-id", "", "Resource ID of the launchpad storage account, rather than finding it by its tags")
 	lzListCmd.Flags().SortFlags = true
 
 	landingzoneCmd.AddCommand(lzListCmd)
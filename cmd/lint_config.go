@@ -0,0 +1,68 @@
+//
+// Rover - Lint config command
+// * Checks tfvars in configuration directories against the variables declared by each landingzone
+//
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+var lintConfigCmd = &cobra.Command{
+	Use:   "lint-config",
+	Short: "Check tfvars against the landingzone variables",
+	Long: `Checks the tfvars files in the configuration directory against the variables declared in the landingzone source.
+Reports values for undeclared variables, values of the wrong type and required variables with no value.
+No Azure login is needed. When using a config file, omit --level to check all stacks`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		output := landingzone.ParseDiagnosticsFlags(cmd)
+		optionsList := buildOptionsList(cmd)
+
+		report := landingzone.ValidateReport{Valid: true, Diagnostics: []landingzone.Diagnostic{}}
+		linted := map[string]bool{}
+		for _, options := range optionsList {
+			// Stacks sharing the same source and configuration only need checking once
+			key := options.SourcePath + "|" + options.ConfigPath
+			if linted[key] {
+				continue
+			}
+			linted[key] = true
+
+			console.Infof("Checking configuration for %s\n", options.StateName)
+			diagnostics, err := options.LintConfig()
+			cobra.CheckErr(err)
+
+			for _, d := range diagnostics {
+				if d.Severity == "error" {
+					report.Valid = false
+					report.ErrorCount++
+				} else {
+					report.WarningCount++
+				}
+			}
+			report.Diagnostics = append(report.Diagnostics, diagnostics...)
+		}
+
+		err := output.Write(report)
+		cobra.CheckErr(err)
+
+		if !report.Valid {
+			cobra.CheckErr(fmt.Sprintf("configuration has %d errors and %d warnings", report.ErrorCount, report.WarningCount))
+		}
+		console.Success("Configuration matches the landingzone variables")
+	},
+}
+
+func init() {
+	addActionFlags(lintConfigCmd)
+	landingzone.AddDiagnosticsFlags(lintConfigCmd)
+
+	rootCmd.AddCommand(lintConfigCmd)
+}
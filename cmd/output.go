@@ -0,0 +1,53 @@
+//
+// Rover - Output command
+// * Reads landingzone outputs from remote state, for one stack or across all levels & stacks
+//
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+var outputCmd = &cobra.Command{
+	Use:   "output",
+	Short: "Read outputs from deployed landingzones",
+	Long: `Reads the outputs of landingzones from their remote state in the launchpad storage account.
+When using a config file, omit --level and --stack to read outputs from all stacks.
+Sensitive outputs are masked unless --show-sensitive is set`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		format, _ := cmd.Flags().GetString("format")
+		showSensitive, _ := cmd.Flags().GetBool("show-sensitive")
+
+		// Keep stdout clean for the outputs, so they can be piped or redirected to a file
+		if format != landingzone.OutputFormatTable {
+			console.SetOutput(os.Stderr)
+		}
+
+		optionsList := buildOptionsList(cmd)
+		err := landingzone.CheckOutputFormat(format, len(optionsList))
+		cobra.CheckErr(err)
+
+		action := landingzone.NewOutputAction(name)
+		executeAction(action, optionsList)
+
+		err = landingzone.WriteOutputs(os.Stdout, action.Results, format, showSensitive)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	addActionFlags(outputCmd)
+	outputCmd.Flags().String("name", "", "Name of a single output to read, default is all outputs")
+	outputCmd.Flags().StringP("format", "o", landingzone.OutputFormatTable, "Output format: table, json, dotenv or tfvars")
+	outputCmd.Flags().Bool("show-sensitive", false, "Show the values of sensitive outputs")
+
+	rootCmd.AddCommand(outputCmd)
+}
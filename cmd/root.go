@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/builtin/actions"
 	"github.com/aztfmod/rover/pkg/command"
 	"github.com/aztfmod/rover/pkg/console"
@@ -34,6 +35,7 @@ It acts as a toolchain development environment to avoid impacting the local mach
 to make sure that all contributors in the GitOps teams are using a consistent set of tools and version.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		console.DebugEnabled, _ = cmd.Flags().GetBool("debug")
+		console.Quiet, _ = cmd.Flags().GetBool("quiet")
 	},
 }
 
@@ -57,6 +59,7 @@ func GetVersion() string {
 
 func init() {
 	rootCmd.PersistentFlags().Bool("debug", false, "log extra debug information, may contain secrets")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "hide live terraform output, it is still written to the log file")
 
 	rootCmd.SetHelpCommand(helpCmd)
 
@@ -115,66 +118,181 @@ func BuildSubCommandsFromActionMap() {
 				// Dynamically building our commands has some limitations, instead we need to use the cmd name & the map
 				action = actions.ActionMap[cmd.Name()]
 
-				configFile, _ := cmd.Flags().GetString("config-file")
-				configPath, _ := cmd.Flags().GetString("config-dir")
-
-				// Handle the user trying to use both configPath and configFile or neither!
-				if configPath == "" && configFile == "" {
-					_ = cmd.Help()
-					os.Exit(0)
-				}
-				if configPath != "" && configFile != "" {
-					cobra.CheckErr("--config-file and --config-dir options must not be combined, specify only one")
-				}
-
-				var optionsList []landingzone.Options
-				// Handle symphony mode where config file and level is passed, this will return optionsList with MANY items
-				if configFile != "" {
-					// Depending on if we're running single or multi-level this will return one or many options
-					utils.SymphonyYamlFilePath = configFile
-					optionsList = symphony.BuildOptions(cmd)
-				}
-
-				// Handle CLI or standalone mode, this will return optionsList with a single item
-				if configPath != "" {
-					optionsList = landingzone.BuildOptions(cmd)
+				if flagsAction, ok := action.(landingzone.ActionWithFlags); ok {
+					flagsAction.ParseFlags(cmd)
 				}
 
-				for _, options := range optionsList {
-					// Now start the action execution...
-					// If an error occurs, depend on downstream code to log messages
-					console.Infof("Executing action %s for %s\n", action.GetName(), options.StateName)
-					err := action.Execute(&options)
-					if err != nil {
-						cobra.CheckErr(err)
-					}
-				}
+				optionsList := buildOptionsList(cmd)
+				executeAction(action, optionsList)
 
 				console.Success("Rover has finished")
 				os.Exit(0)
 			},
 		}
 
-		actionSubCmd.Flags().StringP("source", "s", "", "Path to source of landingzone")
-		actionSubCmd.Flags().StringP("config-file", "c", "", "Configuration file, you must supply this or config-dir")
-		actionSubCmd.Flags().StringP("config-dir", "v", "", "Configuration directory, you must supply this or config-file")
-		actionSubCmd.Flags().StringP("environment", "e", "", "Name of CAF environment")
-		actionSubCmd.Flags().StringP("workspace", "w", "", "Name of workspace")
-		actionSubCmd.Flags().StringP("statename", "n", "", "Name for state and plan files")
-		actionSubCmd.Flags().String("state-sub", "", "Azure subscription ID where state is held")
-		actionSubCmd.Flags().String("target-sub", "", "Azure subscription ID to operate on")
-		actionSubCmd.Flags().Bool("launchpad", false, "Run in launchpad mode, i.e. level 0")
-		actionSubCmd.Flags().StringP("level", "l", "", "CAF landingzone level name, default is all levels")
-		actionSubCmd.Flags().BoolP("dry-run", "d", false, "Execute a dry run where no actions will be executed")
-		actionSubCmd.Flags().StringP("stack", "t", "", "CAF landingzone level stack name")
-		actionSubCmd.Flags().StringP("test-source", "", "", "Path to source of tests")
-		actionSubCmd.Flags().SortFlags = true
+		addActionFlags(actionSubCmd)
+		if varOverrideActions[key] {
+			addVarFlags(actionSubCmd)
+		}
+		if flagsAction, ok := action.(landingzone.ActionWithFlags); ok {
+			flagsAction.AddFlags(actionSubCmd)
+		}
 
 		// Stuff it under the parent root command
 		rootCmd.AddCommand(actionSubCmd)
 	}
 }
 
+// addActionFlags adds the common flags used by every action that operates on landingzones
+func addActionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("source", "s", "", "Path to source of landingzone")
+	cmd.Flags().StringP("config-file", "c", "", "Configuration file, you must supply this or config-dir")
+	cmd.Flags().StringP("config-dir", "v", "", "Configuration directory, you must supply this or config-file")
+	cmd.Flags().StringP("environment", "e", "", "Name of CAF environment")
+	cmd.Flags().StringP("workspace", "w", "", "Name of workspace")
+	cmd.Flags().StringP("statename", "n", "", "Name for state and plan files")
+	cmd.Flags().String("state-sub", "", "Azure subscription ID where state is held")
+	cmd.Flags().String("target-sub", "", "Azure subscription ID to operate on")
+	cmd.Flags().Bool("launchpad", false, "Run in launchpad mode, i.e. level 0")
+	cmd.Flags().StringP("level", "l", "", "CAF landingzone level name, default is all levels")
+	cmd.Flags().BoolP("dry-run", "d", false, "Execute a dry run where no actions will be executed")
+	cmd.Flags().StringP("stack", "t", "", "CAF landingzone level stack name")
+	cmd.Flags().StringP("test-source", "", "", "Path to source of tests")
+	cmd.Flags().Duration("timeout", 0, "Stop the action on each stack if it runs longer than this, e.g. 45m or 2h, Linux only")
+	cmd.Flags().String("state-backend", "", "Where terraform state is held: azurerm (default) or local")
+	cmd.Flags().String("state-dir", "", "Root directory for state with the local state backend, default is the rover home dir")
+	cmd.Flags().Bool("azuread-auth", false, "Access azurerm state with the signed in identity rather than storage account keys")
+	addLaunchpadTagFlag(cmd)
+	cmd.Flags().String("state-storage-id", "", "Resource ID of the launchpad storage account, rather than finding it by its tags")
+	cmd.Flags().String("state-keyvault-id", "", "Resource ID of the launchpad key vault, rather than finding it by its tags")
+	cmd.Flags().SortFlags = true
+}
+
+// These actions run terraform with the tfvars from the config directory, so accept overrides on top
+// apply only checks they match the ones the saved plan was created with, import is done with "rover tf -- import"
+var varOverrideActions = map[string]bool{
+	"plan":    true,
+	"apply":   true,
+	"destroy": true,
+}
+
+// addVarFlags adds the repeatable --var and --var-file flags, applied after the tfvars in the config directory
+func addVarFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("var", []string{}, "Set a variable as key=value, overriding the config directory, can be repeated")
+	cmd.Flags().StringArray("var-file", []string{}, "Path to a tfvars file applied after the config directory, can be repeated")
+}
+
+// buildOptionsList returns the options for all the levels & stacks the command should run against
+// Handles both symphony (config file) and standalone (config dir) modes
+func buildOptionsList(cmd *cobra.Command) []landingzone.Options {
+	configFile, _ := cmd.Flags().GetString("config-file")
+	configPath, _ := cmd.Flags().GetString("config-dir")
+
+	// Handle the user trying to use both configPath and configFile or neither!
+	if configPath == "" && configFile == "" {
+		_ = cmd.Help()
+		os.Exit(0)
+	}
+	if configPath != "" && configFile != "" {
+		cobra.CheckErr("--config-file and --config-dir options must not be combined, specify only one")
+	}
+
+	var optionsList []landingzone.Options
+	// Handle symphony mode where config file and level is passed, this will return optionsList with MANY items
+	if configFile != "" {
+		// Depending on if we're running single or multi-level this will return one or many options
+		utils.SymphonyYamlFilePath = configFile
+		optionsList = symphony.BuildOptions(cmd)
+	}
+
+	// Handle CLI or standalone mode, this will return optionsList with a single item
+	if configPath != "" {
+		optionsList = landingzone.BuildOptions(cmd)
+	}
+
+	// Stacks share a session, so Azure lookups aren't repeated for each of them
+	// There's one for each way of accessing state blobs, matching how terraform authenticates to the stack's state
+	sessions := map[bool]*azure.Session{}
+	run := &landingzone.RunState{}
+	levels := map[string]bool{}
+	for i := range optionsList {
+		setStateBackend(cmd, &optionsList[i])
+		azureADAuth := optionsList[i].StateBackend.UsesAzureADAuth()
+		if sessions[azureADAuth] == nil {
+			sessions[azureADAuth] = azure.NewSession(azureADAuth)
+		}
+		optionsList[i].Cloud = sessions[azureADAuth]
+		optionsList[i].Run = run
+		levels[optionsList[i].Level] = true
+	}
+	// A resource ID names the launchpad of one level
+	if len(levels) > 1 && (cmd.Flags().Changed("state-storage-id") || cmd.Flags().Changed("state-keyvault-id")) {
+		cobra.CheckErr("--state-storage-id and --state-keyvault-id can only be used with a single level, choose one with --level")
+	}
+
+	// Overrides apply to every stack the command runs against
+	if cmd.Flags().Lookup("var") != nil {
+		vars, _ := cmd.Flags().GetStringArray("var")
+		varFiles, _ := cmd.Flags().GetStringArray("var-file")
+		overrides, err := landingzone.ParseVarOverrides(vars, varFiles)
+		cobra.CheckErr(err)
+		for i := range optionsList {
+			optionsList[i].Overrides = overrides
+		}
+	}
+
+	return optionsList
+}
+
+// setStateBackend applies the state backend flags, they take precedence over the symphony config
+func setStateBackend(cmd *cobra.Command, o *landingzone.Options) {
+	backendType, _ := cmd.Flags().GetString("state-backend")
+	stateDir, _ := cmd.Flags().GetString("state-dir")
+	if backendType == "" && stateDir != "" {
+		backendType = landingzone.BackendLocal
+	}
+
+	backendConf := o.StateBackend
+	if backendType != "" {
+		backendConf.Type = backendType
+		backendConf.Path = stateDir
+	}
+	if cmd.Flags().Changed("azuread-auth") {
+		azureADAuth, _ := cmd.Flags().GetBool("azuread-auth")
+		backendConf.AzureADAuth = &azureADAuth
+	}
+	setLaunchpadFlags(cmd, &backendConf)
+	err := o.SetStateBackend(backendConf)
+	cobra.CheckErr(err)
+}
+
+// addLaunchpadTagFlag adds the repeatable --launchpad-tag flag, narrowing the search for the launchpad
+func addLaunchpadTagFlag(cmd *cobra.Command) {
+	cmd.Flags().StringToString("launchpad-tag", map[string]string{}, "Tag the launchpad storage account & key vault must have as name=value, can be repeated")
+}
+
+// setLaunchpadFlags applies the launchpad tag & resource ID flags which the command has
+// Tags are added to those in the symphony config
+func setLaunchpadFlags(cmd *cobra.Command, backendConf *landingzone.StateBackendConfig) {
+	if cmd.Flags().Changed("launchpad-tag") {
+		flagTags, _ := cmd.Flags().GetStringToString("launchpad-tag")
+		tags := map[string]string{}
+		for name, value := range backendConf.LaunchpadTags {
+			tags[name] = value
+		}
+		for name, value := range flagTags {
+			tags[name] = value
+		}
+		backendConf.LaunchpadTags = tags
+	}
+	if cmd.Flags().Changed("state-storage-id") {
+		backendConf.StorageAccountID, _ = cmd.Flags().GetString("state-storage-id")
+	}
+	if cmd.Flags().Changed("state-keyvault-id") {
+		backendConf.KeyVaultID, _ = cmd.Flags().GetString("state-keyvault-id")
+	}
+}
+
 func helpMessageByGroups(cmd *cobra.Command) string {
 	groups := map[string][]string{}
 	for _, c := range cmd.Commands() {
@@ -4,6 +4,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -56,7 +57,7 @@ func Test_Rover_Standalone_Apply_Launchpad(t *testing.T) {
 
 	getActionMap()
 	action := actions.ActionMap["mock"]
-	_ = action.Execute(&optionsList[0])
+	_ = action.Execute(context.Background(), &optionsList[0])
 }
 
 func Test_Builtin_Init_Command(t *testing.T) {
@@ -0,0 +1,232 @@
+//
+// Rover - State commands
+// * Inspect and release the locks terraform takes on landingzone state
+// * List and restore the snapshots rover takes of state
+// * Migrate state between levels, environments & workspaces
+//
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:         "state",
+	Short:       "Inspect and manage landingzone state",
+	Long:        `Commands for working with the terraform state of landingzones, held in the launchpad storage for each level`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+}
+
+var stateLockInfoCmd = &cobra.Command{
+	Use:   "lock-info",
+	Short: "Show who holds the lock on the state for each stack",
+	Long: `Shows the lock held on the state for each stack, read from the blob lease and the lock details terraform saves.
+When using a config file, omit --level to check all stacks`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		optionsList := buildOptionsList(cmd)
+
+		locked := 0
+		for _, options := range optionsList {
+			lock, err := options.StateLockInfo(context.Background())
+			cobra.CheckErr(err)
+			if lock == nil {
+				console.Successf("%s: state %s.tfstate is not locked\n", options.Level, options.StateName)
+				continue
+			}
+
+			locked++
+			console.Warningf("%s: state %s.tfstate is locked\n", options.Level, options.StateName)
+			printStateLock(lock)
+		}
+
+		if locked > 0 {
+			console.Warningf("%d of %d stacks are locked, release a lock with 'rover state unlock' once the holder has finished\n", locked, len(optionsList))
+		}
+	},
+}
+
+var stateUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Force release the lock on the state for a stack",
+	Long: `Releases the lock on the state for a single stack, e.g. one left behind by a pipeline which died.
+Only do this when the holder is no longer running, otherwise state may be corrupted.
+Pass --lock-id to only release the lock if it has not changed since lock-info was run`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		lockID, _ := cmd.Flags().GetString("lock-id")
+
+		optionsList := buildOptionsList(cmd)
+		if len(optionsList) != 1 {
+			cobra.CheckErr("unlock works on a single stack, use --level and --stack to choose it")
+		}
+		options := optionsList[0]
+
+		lock, err := options.UnlockState(context.Background(), lockID)
+		cobra.CheckErr(err)
+		if lock == nil {
+			console.Successf("State %s.tfstate is not locked, nothing to do\n", options.StateName)
+			return
+		}
+
+		console.Warning("Released lock:")
+		printStateLock(lock)
+		console.Successf("State %s.tfstate is now unlocked\n", options.StateName)
+	},
+}
+
+var stateSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List the snapshots of the state for each stack",
+	Long: `Lists the copies of state rover takes before each apply, destroy or state change, oldest first.
+When using a config file, omit --level to list snapshots for all stacks`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		optionsList := buildOptionsList(cmd)
+
+		for _, options := range optionsList {
+			snapshots, err := options.StateSnapshots(context.Background())
+			cobra.CheckErr(err)
+			if len(snapshots) == 0 {
+				console.Infof("%s: no snapshots of state %s.tfstate\n", options.Level, options.StateName)
+				continue
+			}
+
+			console.Successf("%s: %d snapshot(s) of state %s.tfstate\n", options.Level, len(snapshots), options.StateName)
+			for _, snapshot := range snapshots {
+				console.Infof("  %s\t%s\t%dkb\n", snapshot.ID, snapshot.Created.Local().Format(time.RFC1123), snapshot.Size/1024)
+			}
+		}
+	},
+}
+
+var stateRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the state for a stack from a snapshot",
+	Long: `Replaces the state for a single stack with one of its snapshots, listed with 'rover state snapshots'.
+The snapshot must have the same lineage as the current state and must not be newer than it, pass --force to skip these checks.
+The current state is snapshotted before it is replaced, so a restore can be undone`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		snapshotID, _ := cmd.Flags().GetString("snapshot")
+		force, _ := cmd.Flags().GetBool("force")
+		if snapshotID == "" {
+			cobra.CheckErr("--snapshot is required, list them with 'rover state snapshots'")
+		}
+
+		optionsList := buildOptionsList(cmd)
+		if len(optionsList) != 1 {
+			cobra.CheckErr("restore works on a single stack, use --level and --stack to choose it")
+		}
+		options := optionsList[0]
+
+		err := options.RestoreSnapshot(context.Background(), snapshotID, force)
+		cobra.CheckErr(err)
+		console.Successf("State %s.tfstate was restored from snapshot %s\n", options.StateName, snapshotID)
+	},
+}
+
+var stateMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy or move the state for a stack to another level, environment, workspace or state name",
+	Long: `Copies state between launchpad storage locations, each given as level/environment/workspace/statename.
+Existing destination state must share the lineage of the source and not be newer, pass --force to overwrite it anyway.
+Use --delete-source to move the state rather than copy it, and --dry-run to only list what would move.
+Both are found in --state-sub unless --from-sub or --to-sub give their own subscription`,
+	Example: `  rover state migrate --from level1/sandpit/tfstate/web --to level1/prod/tfstate/web --dry-run
+  rover state migrate --from level1/sandpit/tfstate/web --to level1/prod/tfstate/web --to-sub <prod subscription id>
+  rover state migrate --from level2/sandpit/tfstate/networking --to level2/sandpit/tfstate/hub_networking --delete-source`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		if fromFlag == "" || toFlag == "" {
+			cobra.CheckErr("--from and --to are both required")
+		}
+		from, err := landingzone.ParseStateAddress(fromFlag)
+		cobra.CheckErr(err)
+		to, err := landingzone.ParseStateAddress(toFlag)
+		cobra.CheckErr(err)
+		from.Subscription, _ = cmd.Flags().GetString("from-sub")
+		to.Subscription, _ = cmd.Flags().GetString("to-sub")
+
+		opts := landingzone.MigrateOptions{}
+		opts.DeleteSource, _ = cmd.Flags().GetBool("delete-source")
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		opts.Force, _ = cmd.Flags().GetBool("force")
+
+		base := landingzone.Options{}
+		base.StateSubscription, _ = cmd.Flags().GetString("state-sub")
+		setStateBackend(cmd, &base)
+		base.Cloud = azure.NewSession(base.StateBackend.UsesAzureADAuth())
+
+		err = landingzone.MigrateState(context.Background(), base, from, to, opts)
+		cobra.CheckErr(err)
+		if opts.DryRun {
+			return
+		}
+		if opts.DeleteSource {
+			console.Successf("State for %s was moved to %s\n", from, to)
+		} else {
+			console.Successf("State for %s was copied to %s\n", from, to)
+		}
+	},
+}
+
+func printStateLock(lock *landingzone.StateLock) {
+	console.Infof("  Holder:    %s\n", lock.Who)
+	console.Infof("  Operation: %s\n", lock.Operation)
+	if !lock.Created.IsZero() {
+		console.Infof("  Created:   %s\n", lock.Created.Local().Format(time.RFC1123))
+	}
+	console.Infof("  Lock ID:   %s\n", lock.ID)
+	if lock.Lease != "" {
+		console.Infof("  Lease:     %s\n", lock.Lease)
+	}
+	if lock.Info != "" {
+		console.Infof("  Info:      %s\n", lock.Info)
+	}
+}
+
+func init() {
+	addActionFlags(stateLockInfoCmd)
+	addActionFlags(stateUnlockCmd)
+	stateUnlockCmd.Flags().String("lock-id", "", "Only release the lock if it has this ID")
+
+	addActionFlags(stateSnapshotsCmd)
+	addActionFlags(stateRestoreCmd)
+	stateRestoreCmd.Flags().String("snapshot", "", "ID of the snapshot to restore, as shown by 'rover state snapshots'")
+	stateRestoreCmd.Flags().Bool("force", false, "Restore even if the snapshot lineage or serial doesn't match the current state")
+
+	stateMigrateCmd.Flags().String("from", "", "State to copy, as level/environment/workspace/statename")
+	stateMigrateCmd.Flags().String("to", "", "Where to copy the state to, as level/environment/workspace/statename")
+	stateMigrateCmd.Flags().Bool("delete-source", false, "Delete the source state once copied, it is snapshotted first")
+	stateMigrateCmd.Flags().BoolP("dry-run", "d", false, "Only list what would be copied")
+	stateMigrateCmd.Flags().Bool("force", false, "Overwrite destination state even if it is newer or from a different lineage")
+	stateMigrateCmd.Flags().String("state-sub", "", "Azure subscription ID where state is held")
+	stateMigrateCmd.Flags().String("from-sub", "", "Azure subscription ID holding the source state, default is --state-sub")
+	stateMigrateCmd.Flags().String("to-sub", "", "Azure subscription ID to copy the state to, default is --state-sub")
+	addLaunchpadTagFlag(stateMigrateCmd)
+	stateMigrateCmd.Flags().String("state-backend", "", "Where terraform state is held: azurerm (default) or local")
+	stateMigrateCmd.Flags().String("state-dir", "", "Root directory for state with the local state backend, default is the rover home dir")
+	stateMigrateCmd.Flags().Bool("azuread-auth", false, "Access azurerm state with the signed in identity rather than storage account keys")
+
+	stateCmd.AddCommand(stateLockInfoCmd)
+	stateCmd.AddCommand(stateUnlockCmd)
+	stateCmd.AddCommand(stateSnapshotsCmd)
+	stateCmd.AddCommand(stateRestoreCmd)
+	stateCmd.AddCommand(stateMigrateCmd)
+	rootCmd.AddCommand(stateCmd)
+}
@@ -0,0 +1,72 @@
+//
+// Rover - Terraform commands
+// * Manages the versions of terraform installed into the rover home
+//
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/aztfmod/rover/pkg/symphony"
+	"github.com/aztfmod/rover/pkg/terraform"
+	"github.com/spf13/cobra"
+)
+
+var terraformCmd = &cobra.Command{
+	Use:         "terraform",
+	Short:       "Manage the versions of terraform used by rover",
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+}
+
+var tfInstallCmd = &cobra.Command{
+	Use:   "install [version]",
+	Short: "Install a version of terraform into the rover home",
+	Long: `Installs terraform into the rover home, where it is used in preference to terraform on the system path.
+The version can be exact or a constraint such as "~> 1.0.0", when omitted the terraformVersion
+from the symphony config file or rover config file is used`,
+	Args: cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, _ := cmd.Flags().GetString("config-file")
+		mirror, _ := cmd.Flags().GetString("mirror")
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		conf, err := rover.LoadConfig()
+		cobra.CheckErr(err)
+		if mirror == "" {
+			mirror = conf.TerraformMirror
+		}
+
+		tfVersion := conf.TerraformVersion
+		if configFile != "" {
+			symphonyConf, err := symphony.NewSymphonyConfig(configFile)
+			cobra.CheckErr(err)
+			if symphonyConf.Content.TerraformVersion != "" {
+				tfVersion = symphonyConf.Content.TerraformVersion
+			}
+		}
+		if len(args) > 0 {
+			tfVersion = args[0]
+		}
+		if tfVersion == "" {
+			cobra.CheckErr("no version given and terraformVersion is not set in the symphony or rover config file")
+		}
+
+		console.Infof("Installing terraform matching %s\n", tfVersion)
+		_, err = terraform.Install(context.Background(), tfVersion, mirror, offline)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	tfInstallCmd.Flags().StringP("config-file", "c", "", "Symphony configuration file to take the version from")
+	tfInstallCmd.Flags().String("mirror", "", "Directory of terraform release zip archives to install from")
+	tfInstallCmd.Flags().Bool("offline", false, "Only install from the mirror, never download")
+
+	terraformCmd.AddCommand(tfInstallCmd)
+	rootCmd.AddCommand(terraformCmd)
+}
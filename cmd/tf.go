@@ -0,0 +1,69 @@
+//
+// Rover - Terraform passthrough command
+// * Runs any terraform command in a landingzone, with the same environment & backend rover sets up for its actions
+//
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+var tfCmd = &cobra.Command{
+	Use:   "tf [flags] -- <terraform args>",
+	Short: "Run any terraform command in the landingzone",
+	Long: `Runs a terraform command which rover does not wrap, such as console, providers or graph.
+Subscription, identity, backend init and TF_VAR_ values are set up as they are for other actions.
+The tfvars in the config directory are added as -var-file options, for commands which accept them.
+Any --var and --var-file overrides are added after them, e.g. to run terraform import with a changed value.
+Terraform arguments must follow --, e.g. rover tf -c symphony.yaml -l level1 --stack web -- state list`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 || len(args) == dash {
+			cobra.CheckErr("terraform arguments must be given after --")
+		}
+		tfArgs := args[dash:]
+
+		optionsList := buildOptionsList(cmd)
+
+		// Terraform shares the terminal with rover and handles Ctrl-C itself, rover waits for it to exit
+		// SIGTERM is usually sent to the whole process group too, e.g. by a CI runner, so it's ignored the same way
+		signal.Ignore(os.Interrupt, syscall.SIGTERM)
+
+		action := landingzone.NewPassthroughAction(tfArgs)
+		for _, options := range optionsList {
+			console.Infof("Executing action %s for %s\n", action.GetName(), options.StateName)
+			timeout, err := options.TimeoutFor(action.GetName())
+			cobra.CheckErr(err)
+
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			err = action.Execute(ctx, &options)
+			cancel()
+			cobra.CheckErr(err)
+
+			// Stop at the first failure, passing on the exit code from terraform
+			if action.ExitCode != 0 {
+				os.Exit(action.ExitCode)
+			}
+		}
+	},
+}
+
+func init() {
+	addActionFlags(tfCmd)
+	addVarFlags(tfCmd)
+
+	rootCmd.AddCommand(tfCmd)
+}
@@ -0,0 +1,176 @@
+//
+// Rover - Workspace commands
+// * Workspaces are blob containers in the launchpad storage account, these commands manage them
+//
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/spf13/cobra"
+)
+
+var workspaceCmd = &cobra.Command{
+	Use:         "workspace",
+	Aliases:     []string{"ws"},
+	Short:       "Manage workspaces in the launchpad storage account",
+	Long:        `Workspaces hold landingzone state, each one is a blob container in the launchpad storage account for a level`,
+	Annotations: map[string]string{"cmd_group_annotation": landingzone.BuiltinCommand},
+}
+
+var wsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List workspaces",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		cloud, storageID, _ := findWorkspaceStorage(cmd)
+
+		containers, err := cloud.ListContainers(storageID)
+		cobra.CheckErr(err)
+		console.Warning("Workspaces:")
+		for _, container := range containers {
+			console.Warningf(" - %s\n", container)
+		}
+	},
+}
+
+var wsCreateCmd = &cobra.Command{
+	Use:   "create <workspace>",
+	Short: "Create a new empty workspace",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		cloud, storageID, _ := findWorkspaceStorage(cmd)
+
+		err := cloud.CreateContainer(storageID, args[0])
+		cobra.CheckErr(err)
+		console.Successf("Workspace '%s' was created\n", args[0])
+	},
+}
+
+var wsDeleteCmd = &cobra.Command{
+	Use:   "delete <workspace>",
+	Short: "Delete a workspace, it must be empty unless --force is set",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		cloud, storageID, _ := findWorkspaceStorage(cmd)
+
+		err := landingzone.CheckWorkspace(cloud, storageID, args[0])
+		cobra.CheckErr(err)
+
+		blobs, err := cloud.ListBlobs(storageID, args[0])
+		cobra.CheckErr(err)
+		if len(blobs) > 0 && !force {
+			cobra.CheckErr(fmt.Sprintf("workspace '%s' holds %d state file(s), use --force to delete it anyway", args[0], len(blobs)))
+		}
+
+		// Snapshots are kept in the workspace, so a copy of the state is saved locally before it goes
+		backupDir, saved, err := landingzone.BackupWorkspace(cloud, storageID, args[0])
+		cobra.CheckErr(err)
+		if saved > 0 {
+			console.Warningf("Saved a copy of %d state file(s) to %s, the workspace and its snapshots will be deleted\n", saved, backupDir)
+		}
+
+		err = cloud.DeleteContainer(storageID, args[0])
+		cobra.CheckErr(err)
+		console.Successf("Workspace '%s' was deleted\n", args[0])
+	},
+}
+
+var wsCopyCmd = &cobra.Command{
+	Use:   "copy <source workspace> <destination workspace>",
+	Short: "Copy all state in a workspace to another workspace, creating it if required",
+	Long: `Copies the state of every stack in a workspace to another, snapshots are not copied.
+State in the destination is snapshotted before it's replaced. Locked state is never replaced,
+and newer state or state from a different lineage is only replaced with --force`,
+	Args: cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		src, dest := args[0], args[1]
+		force, _ := cmd.Flags().GetBool("force")
+		cloud, storageID, backendConf := findWorkspaceStorage(cmd)
+
+		err := landingzone.CheckWorkspace(cloud, storageID, src)
+		cobra.CheckErr(err)
+
+		if landingzone.CheckWorkspace(cloud, storageID, dest) != nil {
+			console.Infof("Creating workspace '%s'\n", dest)
+			err = cloud.CreateContainer(storageID, dest)
+			cobra.CheckErr(err)
+		}
+
+		copied, err := landingzone.CopyWorkspace(cloud, backendConf, storageID, src, dest, force)
+		if err != nil {
+			console.Errorf("Copied %d state file(s) before failing\n", copied)
+			cobra.CheckErr(err)
+		}
+		console.Successf("Copied %d state file(s) from workspace '%s' to '%s'\n", copied, src, dest)
+	},
+}
+
+// setStorageBackend returns the azurerm backend config from the --azuread-auth & launchpad flags
+// Its Azure AD auth setting, which falls back to the rover config file, is how the session accesses blobs
+func setStorageBackend(cmd *cobra.Command) landingzone.StateBackendConfig {
+	backendConf := landingzone.StateBackendConfig{}
+	if cmd.Flags().Changed("azuread-auth") {
+		azureADAuth, _ := cmd.Flags().GetBool("azuread-auth")
+		backendConf.AzureADAuth = &azureADAuth
+	}
+	setLaunchpadFlags(cmd, &backendConf)
+	_, err := landingzone.NewStateBackend(backendConf)
+	cobra.CheckErr(err)
+	return backendConf
+}
+
+// findLaunchpadStorage locates the launchpad storage account for the level & environment, exiting when it can't
+func findLaunchpadStorage(cloud azure.Cloud, backendConf landingzone.StateBackendConfig, level string, cafEnv string, stateSub string) string {
+	storageID, err := landingzone.FindLaunchpadStorage(cloud, backendConf, level, cafEnv, stateSub)
+	if err != nil {
+		console.Errorf("Unable to locate the launchpad storage account for level '%s' and env '%s'\n", level, cafEnv)
+		cobra.CheckErr(err)
+	}
+	console.Successf("Located launchpad storage account:\n%s\n", storageID)
+	return storageID
+}
+
+// findWorkspaceStorage locates the launchpad storage account for the level & environment flags
+// The session it returns is used for the rest of the command, with the backend config
+func findWorkspaceStorage(cmd *cobra.Command) (azure.Cloud, string, landingzone.StateBackendConfig) {
+	level, _ := cmd.Flags().GetString("level")
+	cafEnv, _ := cmd.Flags().GetString("environment")
+	stateSub, _ := cmd.Flags().GetString("state-sub")
+	backendConf := setStorageBackend(cmd)
+
+	cloud := azure.NewSession(backendConf.UsesAzureADAuth())
+	if stateSub == "" {
+		sub, err := cloud.GetSubscription()
+		cobra.CheckErr(err)
+		stateSub = sub.ID
+	}
+
+	return cloud, findLaunchpadStorage(cloud, backendConf, level, cafEnv, stateSub), backendConf
+}
+
+func init() {
+	workspaceCmd.PersistentFlags().StringP("level", "l", "level0", "CAF level name")
+	workspaceCmd.PersistentFlags().StringP("environment", "e", "sandpit", "Name of CAF environment")
+	workspaceCmd.PersistentFlags().String("state-sub", "", "Azure subscription ID where state is held")
+	workspaceCmd.PersistentFlags().Bool("azuread-auth", false, "Access state with the signed in identity rather than storage account keys")
+	workspaceCmd.PersistentFlags().StringToString("launchpad-tag", map[string]string{}, "Tag the launchpad storage account must have as name=value, can be repeated")
+	workspaceCmd.PersistentFlags().String("state-storage-id", "", "Resource ID of the launchpad storage account, rather than finding it by its tags")
+	wsDeleteCmd.Flags().Bool("force", false, "Delete the workspace even if it holds state, a copy of the state is saved in the rover home")
+	wsCopyCmd.Flags().Bool("force", false, "Replace state in the destination even if it is newer or from a different lineage")
+
+	workspaceCmd.AddCommand(wsListCmd)
+	workspaceCmd.AddCommand(wsCreateCmd)
+	workspaceCmd.AddCommand(wsDeleteCmd)
+	workspaceCmd.AddCommand(wsCopyCmd)
+	rootCmd.AddCommand(workspaceCmd)
+}
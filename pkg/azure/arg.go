@@ -8,13 +8,21 @@ package azure
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/resourcegraph/mgmt/2019-04-01/resourcegraph"
 )
 
 // RunQuery against the Azure resource graph
 func RunQuery(query string, subID string) (interface{}, error) {
-	argClient := resourcegraph.New()
+	baseURI, err := resourceManagerBaseURI()
+	if err != nil {
+		return nil, err
+	}
+	argClient := resourcegraph.NewWithBaseURI(baseURI)
 	authorizer, err := GetAuthorizer()
 	if err != nil {
 		return nil, err
@@ -39,3 +47,83 @@ func RunQuery(query string, subID string) (interface{}, error) {
 
 	return results.Data, nil
 }
+
+// ErrLaunchpadNotFound is returned when no launchpad resource has the tags, it's expected before the launchpad is deployed
+var ErrLaunchpadNotFound = errors.New("launchpad not found")
+
+// ErrLaunchpadAmbiguous is returned when several launchpad resources have the tags
+var ErrLaunchpadAmbiguous = errors.New("launchpad is ambiguous")
+
+// IsLaunchpadNotFound is true when a launchpad lookup found nothing
+func IsLaunchpadNotFound(err error) bool {
+	return errors.Is(err, ErrLaunchpadNotFound)
+}
+
+// findTaggedResource returns the id of the one resource of the type with all the tags, kind names it in errors
+func findTaggedResource(kind string, resourceType string, tags map[string]string, subID string) (string, error) {
+	queryResults, err := RunQuery(taggedResourceQuery(resourceType, tags), subID)
+	if err != nil {
+		return "", err
+	}
+
+	resSlice, ok := queryResults.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("finding %s: failed to parse query results", kind)
+	}
+	ids := []string{}
+	for _, res := range resSlice {
+		resMap, ok := res.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("finding %s: failed to parse query results", kind)
+		}
+		id, _ := resMap["id"].(string)
+		ids = append(ids, id)
+	}
+	return SingleTaggedResource(kind, tags, ids)
+}
+
+// taggedResourceQuery finds resources of the type with all the tags, tag names are quoted as they may hold any character
+func taggedResourceQuery(resourceType string, tags map[string]string) string {
+	lines := []string{"Resources", fmt.Sprintf("| where type == %s", kqlString(resourceType))}
+	for _, name := range sortedKeys(tags) {
+		lines = append(lines, fmt.Sprintf("| where tags[%s] == %s", kqlString(name), kqlString(tags[name])))
+	}
+	lines = append(lines, "| project id", "| order by id asc")
+	return strings.Join(lines, "\n")
+}
+
+// SingleTaggedResource checks exactly one resource was found by a tag search, listing the candidates when there are several
+func SingleTaggedResource(kind string, tags map[string]string, ids []string) (string, error) {
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%w, no %s has tags %s", ErrLaunchpadNotFound, kind, DescribeTags(tags))
+	case 1:
+		return ids[0], nil
+	}
+	sort.Strings(ids)
+	return "", fmt.Errorf("%w, %d %ss have tags %s, choose one by resource ID or add tags to narrow the search:\n - %s",
+		ErrLaunchpadAmbiguous, len(ids), kind, DescribeTags(tags), strings.Join(ids, "\n - "))
+}
+
+// DescribeTags formats tags as name=value pairs for messages
+func DescribeTags(tags map[string]string) string {
+	pairs := []string{}
+	for _, name := range sortedKeys(tags) {
+		pairs = append(pairs, name+"="+tags[name])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// kqlString quotes a string literal for a resource graph query
+func kqlString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
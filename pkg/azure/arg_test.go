@@ -0,0 +1,39 @@
+//go:build unit
+// +build unit
+
+package azure
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Tagged_Resource_Query(t *testing.T) {
+	query := taggedResourceQuery("microsoft.storage/storageaccounts", map[string]string{"level": "level1", "caf_tfstate": "it's"})
+	assert.Equal(t, `Resources
+| where type == 'microsoft.storage/storageaccounts'
+| where tags['caf_tfstate'] == 'it\'s'
+| where tags['level'] == 'level1'
+| project id
+| order by id asc`, query)
+}
+
+func Test_Single_Tagged_Resource(t *testing.T) {
+	tags := map[string]string{"level": "level1", "environment": "sandpit"}
+
+	_, err := SingleTaggedResource("storage account", tags, []string{})
+	assert.True(t, IsLaunchpadNotFound(err))
+	assert.EqualError(t, err, "launchpad not found, no storage account has tags environment=sandpit, level=level1")
+
+	id, err := SingleTaggedResource("storage account", tags, []string{"/st1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "/st1", id)
+
+	_, err = SingleTaggedResource("storage account", tags, []string{"/st2", "/st1"})
+	assert.True(t, errors.Is(err, ErrLaunchpadAmbiguous))
+	assert.False(t, IsLaunchpadNotFound(err))
+	assert.Contains(t, err.Error(), "2 storage accounts have tags environment=sandpit, level=level1")
+	assert.Contains(t, err.Error(), "\n - /st1\n - /st2")
+}
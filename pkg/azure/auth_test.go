@@ -1,5 +1,5 @@
-//go:build integration || ignore || !unit
-// +build integration ignore !unit
+//go:build integration && !unit
+// +build integration,!unit
 
 package azure
 
@@ -12,6 +12,7 @@ import (
 )
 
 // NOTE. These tests use the Azure CLI currently to get details of the signed in user
+// They need an az login, so like the tests in test/integration they only run with -tags integration
 
 func Test_IsOwnerCLI(t *testing.T) {
 	// If you're not an owner on the subscription you are using with the az CLI this test will fail
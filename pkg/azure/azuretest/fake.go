@@ -0,0 +1,444 @@
+//
+// Rover - Fake Azure cloud
+// * An in-memory Cloud for tests, covering identity, resource graph lookups, key vault secrets and blob storage
+//
+
+package azuretest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/aztfmod/rover/pkg/azure"
+)
+
+const (
+	fakeStorageType  = "microsoft.storage/storageaccounts"
+	fakeKeyVaultType = "microsoft.keyvault/vaults"
+)
+
+// FakeCloud is an in-memory Cloud, set it up with the Add & Put methods before use
+type FakeCloud struct {
+	// Subscription is returned by GetSubscription, when its ID is empty rover is treated as not signed in
+	Subscription azure.Subscription
+	// Identity is the signed in user
+	Identity azure.Identity
+	// ServicePrincipals are looked up by client id
+	ServicePrincipals map[string]azure.Identity
+	// VM is the metadata of the VM rover runs on, nil when not on a VM
+	VM           *azure.Metadata
+	VMIdentities []azure.Identity
+
+	mu        sync.Mutex
+	owners    map[string]bool
+	resources []fakeResource
+	secrets   map[string]map[string]string
+	accounts  map[string]map[string]map[string]*fakeBlob
+	etag      int
+}
+
+type fakeResource struct {
+	id           string
+	resourceType string
+	tags         map[string]string
+}
+
+type fakeBlob struct {
+	content      []byte
+	etag         string
+	lastModified time.Time
+	leased       bool
+	metadata     map[string]string
+}
+
+// NewFakeCloud returns a fake signed in to the subscription
+func NewFakeCloud(sub azure.Subscription) *FakeCloud {
+	return &FakeCloud{
+		Subscription:      sub,
+		ServicePrincipals: map[string]azure.Identity{},
+		owners:            map[string]bool{},
+		secrets:           map[string]map[string]string{},
+		accounts:          map[string]map[string]map[string]*fakeBlob{},
+	}
+}
+
+// AddOwner assigns the identity the Owner role on the subscription
+func (f *FakeCloud) AddOwner(objectID string, subID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.owners[objectID+"/"+subID] = true
+}
+
+// AddStorageAccount adds a storage account tagged with the level & environment, it has no containers
+func (f *FakeCloud) AddStorageAccount(id string, level string, environment string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resources = append(f.resources, fakeResource{id: id, resourceType: fakeStorageType, tags: map[string]string{"level": level, "environment": environment}})
+	f.accounts[id] = map[string]map[string]*fakeBlob{}
+}
+
+// AddKeyVault adds a key vault tagged with the level & environment, holding the secrets
+func (f *FakeCloud) AddKeyVault(id string, level string, environment string, secrets map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resources = append(f.resources, fakeResource{id: id, resourceType: fakeKeyVaultType, tags: map[string]string{"level": level, "environment": environment}})
+	f.secrets[id] = secrets
+}
+
+// SetTags replaces the tags on a storage account or key vault, e.g. for a different tagging scheme
+func (f *FakeCloud) SetTags(id string, tags map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.resources {
+		if f.resources[i].id == id {
+			f.resources[i].tags = tags
+		}
+	}
+}
+
+// PutBlob writes a blob, creating the container if needed
+func (f *FakeCloud) PutBlob(storageAcctID string, blobContainer string, blobName string, content []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	account, ok := f.accounts[storageAcctID]
+	if !ok {
+		return fmt.Errorf("storage account '%s' not found", storageAcctID)
+	}
+	if account[blobContainer] == nil {
+		account[blobContainer] = map[string]*fakeBlob{}
+	}
+	f.putBlob(account[blobContainer], blobName, content)
+	return nil
+}
+
+// GetBlob returns the content of a blob, false when it doesn't exist
+func (f *FakeCloud) GetBlob(storageAcctID string, blobContainer string, blobName string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	if err != nil {
+		return nil, false
+	}
+	return blob.content, true
+}
+
+// LeaseBlob takes an infinite lease on a blob and sets its metadata, as terraform does when locking state
+// Setting the metadata gives the blob a new ETag
+func (f *FakeCloud) LeaseBlob(storageAcctID string, blobContainer string, blobName string, metadata map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	if err != nil {
+		return err
+	}
+	blob.leased = true
+	blob.metadata = metadata
+	blob.etag = f.nextETag()
+	return nil
+}
+
+func (f *FakeCloud) GetSubscription() (*azure.Subscription, error) {
+	if f.Subscription.ID == "" {
+		return nil, errors.New("not signed in to Azure")
+	}
+	sub := f.Subscription
+	return &sub, nil
+}
+
+func (f *FakeCloud) GetSignedInIdentity() (*azure.Identity, error) {
+	if f.Identity.ObjectID == "" {
+		return nil, errors.New("no signed in user")
+	}
+	ident := f.Identity
+	return &ident, nil
+}
+
+func (f *FakeCloud) GetServicePrincipalIdentity(clientID string) (*azure.Identity, error) {
+	ident, ok := f.ServicePrincipals[clientID]
+	if !ok {
+		return nil, fmt.Errorf("service principal '%s' not found", clientID)
+	}
+	return &ident, nil
+}
+
+func (f *FakeCloud) VMInstanceMetadataService() *azure.Metadata {
+	return f.VM
+}
+
+func (f *FakeCloud) GetVMIdentities(subID string, resourceGroupName string, vmName string) ([]azure.Identity, error) {
+	if f.VM == nil || f.VM.Compute.Name != vmName || f.VM.Compute.ResourceGroupName != resourceGroupName {
+		return nil, fmt.Errorf("virtual machine '%s' not found", vmName)
+	}
+	return f.VMIdentities, nil
+}
+
+func (f *FakeCloud) CheckIsOwner(objectID string, subID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.owners[objectID+"/"+subID], nil
+}
+
+func (f *FakeCloud) FindStorageAccount(tags map[string]string, subID string) (string, error) {
+	return azure.SingleTaggedResource("storage account", tags, f.findResources(fakeStorageType, tags, subID))
+}
+
+func (f *FakeCloud) FindKeyVault(tags map[string]string, subID string) (string, error) {
+	return azure.SingleTaggedResource("key vault", tags, f.findResources(fakeKeyVaultType, tags, subID))
+}
+
+func (f *FakeCloud) GetKeyVaultSecret(keyVaultID string, secretName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, ok := f.secrets[keyVaultID]
+	if !ok {
+		return "", fmt.Errorf("key vault '%s' not found", keyVaultID)
+	}
+	secret, ok := secrets[secretName]
+	if !ok {
+		return "", fmt.Errorf("secret '%s' not found", secretName)
+	}
+	return secret, nil
+}
+
+func (f *FakeCloud) GetAccountKey(subID string, accountName string, resGrp string) (string, error) {
+	return "fake-key-" + accountName, nil
+}
+
+func (f *FakeCloud) ListContainers(storageAcctID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	account, ok := f.accounts[storageAcctID]
+	if !ok {
+		return nil, fmt.Errorf("storage account '%s' not found", storageAcctID)
+	}
+	names := []string{}
+	for name := range account {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *FakeCloud) CreateContainer(storageAcctID string, blobContainer string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	account, ok := f.accounts[storageAcctID]
+	if !ok {
+		return fmt.Errorf("storage account '%s' not found", storageAcctID)
+	}
+	if _, exists := account[blobContainer]; exists {
+		return fmt.Errorf("container '%s' already exists", blobContainer)
+	}
+	account[blobContainer] = map[string]*fakeBlob{}
+	return nil
+}
+
+func (f *FakeCloud) DeleteContainer(storageAcctID string, blobContainer string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.container(storageAcctID, blobContainer)
+	if err != nil {
+		return err
+	}
+	delete(f.accounts[storageAcctID], blobContainer)
+	return nil
+}
+
+func (f *FakeCloud) ListBlobs(storageAcctID string, blobContainer string) ([]azblob.BlobItemInternal, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	container, err := f.container(storageAcctID, blobContainer)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := []azblob.BlobItemInternal{}
+	for name, blob := range container {
+		size := int64(len(blob.content))
+		blobs = append(blobs, azblob.BlobItemInternal{
+			Name: name,
+			Properties: azblob.BlobProperties{
+				LastModified:  blob.lastModified,
+				Etag:          azblob.ETag(blob.etag),
+				ContentLength: &size,
+			},
+		})
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Name < blobs[j].Name })
+	return blobs, nil
+}
+
+func (f *FakeCloud) UploadFileToBlobIfMatch(storageAcctID string, blobContainer string, blobName string, filePath string, etag string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	container, err := f.container(storageAcctID, blobContainer)
+	if err != nil {
+		return err
+	}
+	existing := container[blobName]
+	if existing != nil && existing.leased {
+		return fmt.Errorf("blob %s/%s is leased", blobContainer, blobName)
+	}
+	if etag != azure.BlobETagAny {
+		current := azure.BlobETagNone
+		if existing != nil {
+			current = existing.etag
+		}
+		if current != etag {
+			return fmt.Errorf("unable to upload %s/%s: %w", blobContainer, blobName, azure.ErrBlobChanged)
+		}
+	}
+	f.putBlob(container, blobName, content)
+	return nil
+}
+
+func (f *FakeCloud) DownloadFileFromBlob(storageAcctID string, blobContainer string, blobName string, filePath string) (string, error) {
+	f.mu.Lock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	f.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return blob.etag, os.WriteFile(filePath, blob.content, 0644)
+}
+
+func (f *FakeCloud) CopyBlob(storageAcctID string, srcContainer string, srcBlob string, destContainer string, destBlob string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, srcContainer, srcBlob)
+	if err != nil {
+		return err
+	}
+	container, err := f.container(storageAcctID, destContainer)
+	if err != nil {
+		return err
+	}
+	f.putBlob(container, destBlob, blob.content)
+	return nil
+}
+
+func (f *FakeCloud) DeleteBlobIfMatch(storageAcctID string, blobContainer string, blobName string, etag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	if err != nil {
+		return err
+	}
+	if etag != azure.BlobETagAny && blob.etag != etag {
+		return fmt.Errorf("unable to delete %s/%s: %w", blobContainer, blobName, azure.ErrBlobChanged)
+	}
+	if blob.leased {
+		return fmt.Errorf("blob %s/%s is leased", blobContainer, blobName)
+	}
+	delete(f.accounts[storageAcctID][blobContainer], blobName)
+	return nil
+}
+
+func (f *FakeCloud) GetBlobLease(storageAcctID string, blobContainer string, blobName string) (*azure.BlobLease, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	if errors.Is(err, azure.ErrBlobNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !blob.leased {
+		return &azure.BlobLease{State: "available", Metadata: blob.metadata, ETag: blob.etag}, nil
+	}
+	return &azure.BlobLease{State: "leased", Duration: "infinite", Metadata: blob.metadata, ETag: blob.etag}, nil
+}
+
+func (f *FakeCloud) BreakBlobLease(storageAcctID string, blobContainer string, blobName string, metadataKey string, etag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	blob, err := f.blob(storageAcctID, blobContainer, blobName)
+	if err != nil {
+		return err
+	}
+	if blob.etag != etag {
+		return fmt.Errorf("%s/%s: %w", blobContainer, blobName, azure.ErrBlobChanged)
+	}
+	blob.leased = false
+	for key := range blob.metadata {
+		if strings.EqualFold(key, metadataKey) {
+			delete(blob.metadata, key)
+		}
+	}
+	blob.etag = f.nextETag()
+	return nil
+}
+
+// findResource matches resources in the subscription by type & tags, like the resource graph queries
+func (f *FakeCloud) findResources(resourceType string, tags map[string]string, subID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := []string{}
+	for _, res := range f.resources {
+		resSubID, _, _, err := azure.ParseResourceID(res.id)
+		if err != nil || resSubID != subID || res.resourceType != resourceType {
+			continue
+		}
+		matches := true
+		for name, value := range tags {
+			if res.tags[name] != value {
+				matches = false
+			}
+		}
+		if matches {
+			ids = append(ids, res.id)
+		}
+	}
+	return ids
+}
+
+func (f *FakeCloud) container(storageAcctID string, blobContainer string) (map[string]*fakeBlob, error) {
+	account, ok := f.accounts[storageAcctID]
+	if !ok {
+		return nil, fmt.Errorf("storage account '%s' not found", storageAcctID)
+	}
+	container, ok := account[blobContainer]
+	if !ok {
+		return nil, fmt.Errorf("container '%s' not found", blobContainer)
+	}
+	return container, nil
+}
+
+func (f *FakeCloud) blob(storageAcctID string, blobContainer string, blobName string) (*fakeBlob, error) {
+	container, err := f.container(storageAcctID, blobContainer)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := container[blobName]
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: %w", blobContainer, blobName, azure.ErrBlobNotFound)
+	}
+	return blob, nil
+}
+
+// putBlob replaces a blob, giving it a new ETag
+func (f *FakeCloud) putBlob(container map[string]*fakeBlob, blobName string, content []byte) {
+	container[blobName] = &fakeBlob{
+		content:      append([]byte{}, content...),
+		etag:         f.nextETag(),
+		lastModified: time.Now(),
+		metadata:     map[string]string{},
+	}
+}
+
+func (f *FakeCloud) nextETag() string {
+	f.etag++
+	return fmt.Sprintf("\"0x%X\"", f.etag)
+}
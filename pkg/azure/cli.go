@@ -30,6 +30,10 @@ type Subscription struct {
 	Name            string
 	ID              string
 	User            AccountUser
+	// Credential is how rover signed in, one of the Credential constants
+	Credential string `json:"-"`
+	// TerraformEnv are the ARM_ variables which have Terraform sign in the same way as rover
+	TerraformEnv map[string]string `json:"-"`
 }
 
 // Identity holds an Azure AD identity; user
@@ -64,21 +68,14 @@ type Identity struct {
 	ClientID    string
 }
 
-// GetSubscription gets the current logged in details from the Azure CLI
-// Will fail and exit if they aren't found
+// GetSubscription gets the current logged in details, from the Azure CLI or the credential rover signed in with
 func GetSubscription() (*Subscription, error) {
-	err := command.CheckCommand("az")
-	if err != nil {
-		return nil, err
-	}
-
-	cmdRes, err := command.QuickRun("az", "account", "show", "-o=json")
+	cred, err := getCredential()
 	if err != nil {
 		return nil, err
 	}
 
-	sub := &Subscription{}
-	err = json.Unmarshal([]byte(cmdRes), sub)
+	sub, err := cred.account()
 	if err != nil {
 		return nil, err
 	}
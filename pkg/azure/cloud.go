@@ -0,0 +1,120 @@
+//
+// Rover - Azure cloud access
+// * Everything rover needs from Azure, behind one interface so it can be swapped for azuretest.FakeCloud in tests
+//
+
+package azure
+
+import (
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// Cloud is the Azure access rover depends on, SDKCloud is the real implementation
+type Cloud interface {
+	// GetSubscription returns the signed in account & subscription
+	GetSubscription() (*Subscription, error)
+	// GetSignedInIdentity returns the signed in user
+	GetSignedInIdentity() (*Identity, error)
+	// GetServicePrincipalIdentity looks up a service principal by client id
+	GetServicePrincipalIdentity(clientID string) (*Identity, error)
+	// VMInstanceMetadataService returns the metadata of the VM rover is running on, nil when not on a VM
+	VMInstanceMetadataService() *Metadata
+	// GetVMIdentities returns the managed identities assigned to a VM
+	GetVMIdentities(subID string, resourceGroupName string, vmName string) ([]Identity, error)
+	// CheckIsOwner is true when the identity has the Owner role on the subscription
+	CheckIsOwner(objectID string, subID string) (bool, error)
+
+	// FindStorageAccount returns the resource id of the one storage account with all the tags, e.g. the level & environment
+	// Errors are ErrLaunchpadNotFound when there's none, or ErrLaunchpadAmbiguous when there are several
+	FindStorageAccount(tags map[string]string, subID string) (string, error)
+	// FindKeyVault returns the resource id of the one key vault with all the tags, errors as for FindStorageAccount
+	FindKeyVault(tags map[string]string, subID string) (string, error)
+	// GetKeyVaultSecret reads a secret from a key vault, given its resource id
+	GetKeyVaultSecret(keyVaultID string, secretName string) (string, error)
+
+	GetAccountKey(subID string, accountName string, resGrp string) (string, error)
+	ListContainers(storageAcctID string) ([]string, error)
+	CreateContainer(storageAcctID string, blobContainer string) error
+	DeleteContainer(storageAcctID string, blobContainer string) error
+	ListBlobs(storageAcctID string, blobContainer string) ([]azblob.BlobItemInternal, error)
+	UploadFileToBlobIfMatch(storageAcctID string, blobContainer string, blobName string, filePath string, etag string) error
+	DownloadFileFromBlob(storageAcctID string, blobContainer string, blobName string, filePath string) (string, error)
+	CopyBlob(storageAcctID string, srcContainer string, srcBlob string, destContainer string, destBlob string) error
+	DeleteBlobIfMatch(storageAcctID string, blobContainer string, blobName string, etag string) error
+	GetBlobLease(storageAcctID string, blobContainer string, blobName string) (*BlobLease, error)
+	// BreakBlobLease only breaks the lease while the blob still has the ETag it was read with, errors are ErrBlobChanged if not
+	BreakBlobLease(storageAcctID string, blobContainer string, blobName string, metadataKey string, etag string) error
+}
+
+// SDKCloud is the real Cloud, using the Azure SDK and the signed in credential
+// The blob operations are in storage.go
+type SDKCloud struct {
+	// session answers the lookups made inside other operations from its cache, nil outside a session
+	session *Session
+	// AzureADAuth has blob operations use Azure AD tokens for the signed in identity, rather than storage account keys
+	AzureADAuth bool
+}
+
+// lookups is where the subscription & account keys needed inside other operations come from
+func (c SDKCloud) lookups() Cloud {
+	if c.session != nil {
+		return c.session
+	}
+	return c
+}
+
+func (SDKCloud) GetSubscription() (*Subscription, error) {
+	return GetSubscription()
+}
+
+func (SDKCloud) GetSignedInIdentity() (*Identity, error) {
+	return GetSignedInIdentity()
+}
+
+func (SDKCloud) GetServicePrincipalIdentity(clientID string) (*Identity, error) {
+	return GetServicePrincipalIdentity(clientID)
+}
+
+func (SDKCloud) VMInstanceMetadataService() *Metadata {
+	return VMInstanceMetadataService()
+}
+
+func (SDKCloud) GetVMIdentities(subID string, resourceGroupName string, vmName string) ([]Identity, error) {
+	return GetVMIdentities(subID, resourceGroupName, vmName)
+}
+
+func (SDKCloud) CheckIsOwner(objectID string, subID string) (bool, error) {
+	return CheckIsOwner(objectID, subID)
+}
+
+func (SDKCloud) FindStorageAccount(tags map[string]string, subID string) (string, error) {
+	return FindStorageAccount(tags, subID)
+}
+
+func (SDKCloud) FindKeyVault(tags map[string]string, subID string) (string, error) {
+	return FindKeyVault(tags, subID)
+}
+
+func (c SDKCloud) GetKeyVaultSecret(keyVaultID string, secretName string) (string, error) {
+	_, _, keyVaultName, err := ParseResourceID(keyVaultID)
+	if err != nil {
+		return "", err
+	}
+	sub, err := c.lookups().GetSubscription()
+	if err != nil {
+		return "", err
+	}
+	cloud, err := CloudForEnvironment(sub.EnvironmentName)
+	if err != nil {
+		return "", err
+	}
+	kvClient, err := NewKVClient(cloud.KeyvaultDNS, keyVaultName)
+	if err != nil {
+		return "", err
+	}
+	return kvClient.GetSecret(secretName)
+}
+
+func (SDKCloud) GetAccountKey(subID string, accountName string, resGrp string) (string, error) {
+	return GetAccountKey(subID, accountName, resGrp)
+}
@@ -0,0 +1,349 @@
+//
+// Rover - Azure clouds
+// * The endpoints of the public, sovereign & custom Azure clouds, such as Azure Stack Hub
+// * Custom clouds come from the rover config file or an ARM metadata endpoint, as with 'az cloud register'
+//
+
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aztfmod/rover/pkg/command"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+)
+
+// ARM metadata API version which lists the endpoints & suffixes of every cloud it knows
+const metadataAPIVersion = "2022-09-01"
+
+// CloudDefinition holds the endpoints for an Azure cloud
+type CloudDefinition struct {
+	// Name is the Azure name of the cloud, as used by the Azure CLI
+	Name string
+	// TerraformName is the azurerm provider & backend environment
+	TerraformName string
+	// MetadataHost is set for clouds loaded from ARM metadata, Terraform is pointed at it to load the cloud the same way
+	MetadataHost       string
+	KeyvaultDNS        string
+	StorageEndpoint    string
+	ActiveDirectory    string
+	ResourceManagerURL string
+	// ManagementAudience is the resource for ARM tokens, it's only different to ResourceManagerURL on Azure Stack Hub
+	ManagementAudience string
+	// GraphEndpoint is Microsoft Graph, it's empty for clouds without it such as Azure Stack Hub with ADFS
+	GraphEndpoint string
+}
+
+var wellKnownClouds = []CloudDefinition{
+	{
+		Name: "AzureCloud", TerraformName: "public", KeyvaultDNS: "vault.azure.net", StorageEndpoint: "core.windows.net",
+		ActiveDirectory: "https://login.microsoftonline.com/", ResourceManagerURL: "https://management.azure.com/", GraphEndpoint: "https://graph.microsoft.com/",
+	},
+	{
+		Name: "AzurePublicCloud", TerraformName: "public", KeyvaultDNS: "vault.azure.net", StorageEndpoint: "core.windows.net",
+		ActiveDirectory: "https://login.microsoftonline.com/", ResourceManagerURL: "https://management.azure.com/", GraphEndpoint: "https://graph.microsoft.com/",
+	},
+	{
+		Name: "AzureChinaCloud", TerraformName: "china", KeyvaultDNS: "vault.azure.cn", StorageEndpoint: "core.chinacloudapi.cn",
+		ActiveDirectory: "https://login.chinacloudapi.cn/", ResourceManagerURL: "https://management.chinacloudapi.cn/", GraphEndpoint: "https://microsoftgraph.chinacloudapi.cn/",
+	},
+	{
+		Name: "AzureGermanCloud", TerraformName: "german", KeyvaultDNS: "vault.microsoftazure.de", StorageEndpoint: "core.cloudapi.de",
+		ActiveDirectory: "https://login.microsoftonline.de/", ResourceManagerURL: "https://management.microsoftazure.de/", GraphEndpoint: "https://graph.microsoft.de/",
+	},
+	{
+		Name: "AzureUSGovernment", TerraformName: "usgovernment", KeyvaultDNS: "vault.usgovcloudapi.net", StorageEndpoint: "core.usgovcloudapi.net",
+		ActiveDirectory: "https://login.microsoftonline.us/", ResourceManagerURL: "https://management.usgovcloudapi.net/", GraphEndpoint: "https://graph.microsoft.us/",
+	},
+}
+
+// metadataClient fetches ARM metadata, tests swap it for one trusting their server
+var metadataClient = http.DefaultClient
+
+var (
+	currentCloudOnce sync.Once
+	currentCloudDef  *CloudDefinition
+	currentCloudErr  error
+)
+
+// currentCloud is the cloud rover signs in to, it's worked out once per run, see resolveCloud
+// The credential is chosen at the same time, as signed in with the Azure CLI the cloud comes from the CLI, see cliCloud
+func currentCloud() (*CloudDefinition, error) {
+	currentCloudOnce.Do(func() {
+		conf, err := rover.LoadConfig()
+		if err != nil {
+			currentCloudErr = err
+			return
+		}
+		cloud, err := resolveCloud(conf.Cloud)
+		if err != nil {
+			currentCloudErr = err
+			return
+		}
+
+		chosenCredential, chosenCredentialErr = chooseCredential(*cloud)
+		if chosenCredentialErr == nil {
+			console.Debugf("Signing into Azure with the %s credential\n", chosenCredential.name())
+			if chosenCredential.name() == CredentialCLI {
+				cloud, err = cliCloud(cloud, conf.Cloud != nil || envValue("ARM_METADATA_HOSTNAME", "ARM_METADATA_HOST", "ARM_ENVIRONMENT", "AZURE_ENVIRONMENT") != "")
+				if err != nil {
+					currentCloudErr = err
+					return
+				}
+			}
+		}
+		currentCloudDef = cloud
+		console.Debugf("Using the %s cloud, resource manager %s\n", currentCloudDef.Name, currentCloudDef.ResourceManagerURL)
+	})
+	return currentCloudDef, currentCloudErr
+}
+
+// cliCloudName is the cloud the Azure CLI is set to, the environmentName of its account, swapped in tests
+var cliCloudName = func() (string, error) {
+	name, err := command.QuickRun("az", "cloud", "show", "--query", "name", "-o", "tsv")
+	return strings.TrimSpace(name), err
+}
+
+// cliCloud makes sure the cloud matches the one the Azure CLI is signed in to, so every call goes to the same cloud
+// When no cloud was chosen explicitly the CLI's cloud is used, otherwise they must agree
+func cliCloud(cloud *CloudDefinition, explicit bool) (*CloudDefinition, error) {
+	name, err := cliCloudName()
+	if err != nil {
+		console.Debugf("Unable to find the cloud the Azure CLI is set to: %s\n", err)
+		return cloud, nil
+	}
+	if name == "" || strings.EqualFold(name, cloud.Name) {
+		return cloud, nil
+	}
+	if explicit {
+		return nil, fmt.Errorf("the Azure CLI is set to cloud %s but rover is set to use %s, run 'az cloud set --name %s' and sign in again, "+
+			"or change ARM_ENVIRONMENT or the cloud section of the rover config file", name, cloud.Name, cloud.Name)
+	}
+	known := findWellKnownCloud(name)
+	if known == nil {
+		return nil, fmt.Errorf("the Azure CLI is set to cloud %s which rover doesn't know, define it in the cloud section of the rover config file", name)
+	}
+	return known, nil
+}
+
+// CloudForEnvironment returns the cloud a subscription is in, from the subscription's environment name
+// The Azure CLI reports the cloud it's signed in to, this is matched to the current cloud or a well known one
+func CloudForEnvironment(name string) (*CloudDefinition, error) {
+	current, err := currentCloud()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || strings.EqualFold(current.Name, name) {
+		return current, nil
+	}
+	if cloud := findWellKnownCloud(name); cloud != nil {
+		return cloud, nil
+	}
+	console.Warningf("Cloud %s is not known to rover, using %s, define it in the rover config file\n", name, current.Name)
+	return current, nil
+}
+
+// CloudNameToTerraform returns the azurerm environment for a cloud, for ARM_ENVIRONMENT & the backend
+func CloudNameToTerraform(name string) string {
+	cloud, err := CloudForEnvironment(name)
+	if err != nil {
+		return "public"
+	}
+	return cloud.TerraformName
+}
+
+func findWellKnownCloud(name string) *CloudDefinition {
+	for _, cloud := range wellKnownClouds {
+		if strings.EqualFold(cloud.Name, name) {
+			return &cloud
+		}
+	}
+	return nil
+}
+
+// environmentCloud is the cloud named in the environment, or the Azure public cloud
+// ARM_ENVIRONMENT takes the Terraform names (public, usgovernment, ...), AZURE_ENVIRONMENT the Azure names
+func environmentCloud() CloudDefinition {
+	tfName := os.Getenv("ARM_ENVIRONMENT")
+	name := os.Getenv("AZURE_ENVIRONMENT")
+	for _, cloud := range wellKnownClouds {
+		if (tfName != "" && strings.EqualFold(cloud.TerraformName, tfName)) || strings.EqualFold(cloud.Name, name) {
+			return cloud
+		}
+	}
+	return wellKnownClouds[0]
+}
+
+// resolveCloud works out the cloud from, in order:
+// 1. the cloud section of the rover config file
+// 2. ARM_METADATA_HOSTNAME, loading the cloud from ARM metadata as Terraform does
+// 3. ARM_ENVIRONMENT or AZURE_ENVIRONMENT naming a well known cloud
+// 4. the Azure public cloud
+// Signed in with the Azure CLI, the public cloud is then replaced with the CLI's, see cliCloud
+func resolveCloud(conf *rover.CloudConfig) (*CloudDefinition, error) {
+	if conf == nil {
+		conf = &rover.CloudConfig{MetadataHost: envValue("ARM_METADATA_HOSTNAME", "ARM_METADATA_HOST")}
+	}
+
+	var cloud CloudDefinition
+	switch {
+	case conf.MetadataHost != "":
+		loaded, err := loadCloudMetadata(conf.MetadataHost, conf.Name)
+		if err != nil {
+			return nil, err
+		}
+		cloud = *loaded
+	case conf.Name == "":
+		cloud = environmentCloud()
+	case findWellKnownCloud(conf.Name) != nil:
+		cloud = *findWellKnownCloud(conf.Name)
+	default:
+		cloud = CloudDefinition{Name: conf.Name}
+	}
+
+	override := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	override(&cloud.TerraformName, conf.TerraformName)
+	override(&cloud.ResourceManagerURL, withTrailingSlash(conf.ResourceManager))
+	override(&cloud.ActiveDirectory, withTrailingSlash(conf.ActiveDirectory))
+	override(&cloud.GraphEndpoint, withTrailingSlash(conf.Graph))
+	override(&cloud.KeyvaultDNS, conf.KeyVaultDNS)
+	override(&cloud.StorageEndpoint, conf.StorageSuffix)
+	if cloud.TerraformName == "" {
+		cloud.TerraformName = cloud.Name
+	}
+
+	missing := []string{}
+	for _, setting := range []struct{ name, value string }{
+		{"resourceManager", cloud.ResourceManagerURL},
+		{"activeDirectory", cloud.ActiveDirectory},
+		{"keyVaultDNS", cloud.KeyvaultDNS},
+		{"storageSuffix", cloud.StorageEndpoint},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("cloud '%s' is missing %s, set them in the cloud section of the rover config file", cloud.Name, strings.Join(missing, ", "))
+	}
+	return &cloud, nil
+}
+
+// cloudMetadata is a cloud returned by the ARM metadata endpoint
+type cloudMetadata struct {
+	Name                     string `json:"name"`
+	ResourceManager          string `json:"resourceManager"`
+	MicrosoftGraphResourceID string `json:"microsoftGraphResourceId"`
+	Authentication           struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+	Suffixes struct {
+		KeyVaultDNS string `json:"keyVaultDns"`
+		Storage     string `json:"storage"`
+	} `json:"suffixes"`
+}
+
+// loadCloudMetadata reads a cloud from https://<host>/metadata/endpoints, the same way as 'az cloud register' & Terraform
+// The endpoint may list several clouds, the one with the given name or served from the host is picked
+func loadCloudMetadata(host string, name string) (*CloudDefinition, error) {
+	urlString := fmt.Sprintf("https://%s/metadata/endpoints?api-version=%s", host, metadataAPIVersion)
+	console.Debugf("Loading cloud endpoints from %s\n", urlString)
+	resp, err := metadataClient.Get(urlString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load cloud metadata from %s: %s", host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to load cloud metadata from %s: %s", host, resp.Status)
+	}
+
+	// Older hosts return a single cloud rather than a list
+	var body json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud metadata from %s: %s", host, err)
+	}
+	clouds := []cloudMetadata{}
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "{") {
+		clouds = append(clouds, cloudMetadata{})
+		err = json.Unmarshal(body, &clouds[0])
+	} else {
+		err = json.Unmarshal(body, &clouds)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud metadata from %s: %s", host, err)
+	}
+
+	var found *cloudMetadata
+	names := []string{}
+	for i, cloud := range clouds {
+		names = append(names, cloud.Name)
+		rmURL, _ := url.Parse(cloud.ResourceManager)
+		if (name != "" && strings.EqualFold(cloud.Name, name)) || (name == "" && rmURL != nil && strings.EqualFold(rmURL.Host, host)) {
+			found = &clouds[i]
+			break
+		}
+	}
+	if found == nil && name == "" && len(clouds) == 1 {
+		found = &clouds[0]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("cloud metadata from %s has no cloud matching '%s', it has: %s", host, name, strings.Join(names, ", "))
+	}
+
+	cloud := &CloudDefinition{
+		Name:               found.Name,
+		TerraformName:      found.Name,
+		MetadataHost:       host,
+		KeyvaultDNS:        found.Suffixes.KeyVaultDNS,
+		StorageEndpoint:    found.Suffixes.Storage,
+		ActiveDirectory:    withTrailingSlash(found.Authentication.LoginEndpoint),
+		ResourceManagerURL: withTrailingSlash(found.ResourceManager),
+		GraphEndpoint:      withTrailingSlash(found.MicrosoftGraphResourceID),
+	}
+	if len(found.Authentication.Audiences) > 0 {
+		cloud.ManagementAudience = found.Authentication.Audiences[0]
+	}
+	// Well known clouds keep their Terraform names, Terraform can sign in to those without the metadata host
+	if known := findWellKnownCloud(found.Name); known != nil {
+		cloud.TerraformName = known.TerraformName
+		cloud.MetadataHost = ""
+	}
+	return cloud, nil
+}
+
+// managementAudience is the resource to ask for ARM tokens for
+func (c CloudDefinition) managementAudience() string {
+	if c.ManagementAudience != "" {
+		return c.ManagementAudience
+	}
+	return c.ResourceManagerURL
+}
+
+// resourceManagerBaseURI is the base URI for the Azure SDK clients, for the cloud rover is signed in to
+func resourceManagerBaseURI() (string, error) {
+	cloud, err := currentCloud()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cloud.ResourceManagerURL, "/"), nil
+}
+
+func withTrailingSlash(endpoint string) string {
+	if endpoint == "" || strings.HasSuffix(endpoint, "/") {
+		return endpoint
+	}
+	return endpoint + "/"
+}
@@ -0,0 +1,145 @@
+//go:build unit
+// +build unit
+
+package azure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+// setCloudEnv clears the env vars which choose the cloud, then sets the given ones
+func setCloudEnv(t *testing.T, env map[string]string) {
+	for _, name := range []string{"ARM_ENVIRONMENT", "AZURE_ENVIRONMENT", "ARM_METADATA_HOSTNAME", "ARM_METADATA_HOST"} {
+		name := name
+		saved, set := os.LookupEnv(name)
+		os.Unsetenv(name)
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, saved)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+}
+
+func Test_Resolve_Cloud(t *testing.T) {
+	setCloudEnv(t, nil)
+	cloud, err := resolveCloud(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "AzureCloud", cloud.Name)
+
+	setCloudEnv(t, map[string]string{"ARM_ENVIRONMENT": "usgovernment"})
+	cloud, err = resolveCloud(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "AzureUSGovernment", cloud.Name)
+	assert.Equal(t, "https://graph.microsoft.us/", cloud.GraphEndpoint)
+
+	// The config file wins over the environment, and can override single endpoints
+	cloud, err = resolveCloud(&rover.CloudConfig{Name: "AzureChinaCloud", Graph: "https://graph.example.cn"})
+	assert.Nil(t, err)
+	assert.Equal(t, "china", cloud.TerraformName)
+	assert.Equal(t, "vault.azure.cn", cloud.KeyvaultDNS)
+	assert.Equal(t, "https://graph.example.cn/", cloud.GraphEndpoint)
+
+	// A custom cloud has to give all its endpoints
+	_, err = resolveCloud(&rover.CloudConfig{Name: "Private", ResourceManager: "https://arm.private"})
+	assert.EqualError(t, err, "cloud 'Private' is missing activeDirectory, keyVaultDNS, storageSuffix, set them in the cloud section of the rover config file")
+	cloud, err = resolveCloud(&rover.CloudConfig{
+		Name:            "Private",
+		ResourceManager: "https://arm.private",
+		ActiveDirectory: "https://login.private",
+		KeyVaultDNS:     "vault.private",
+		StorageSuffix:   "storage.private",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "Private", cloud.TerraformName)
+	assert.Equal(t, "https://arm.private/", cloud.managementAudience())
+	assert.Equal(t, "", cloud.GraphEndpoint)
+}
+
+// Stands in for an ARM metadata endpoint, such as the one on an Azure Stack Hub
+func newMetadataServer(t *testing.T, body string) string {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/endpoints" || r.URL.Query().Get("api-version") != metadataAPIVersion {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(body, "HOST", r.Host)))
+	}))
+	saved := metadataClient
+	metadataClient = server.Client()
+	t.Cleanup(func() {
+		server.Close()
+		metadataClient = saved
+	})
+	return server.Listener.Addr().String()
+}
+
+func Test_Load_Cloud_Metadata(t *testing.T) {
+	host := newMetadataServer(t, `[{
+		"name": "AzureStackUser",
+		"resourceManager": "https://HOST/",
+		"authentication": {"loginEndpoint": "https://adfs.local.azurestack.external/adfs", "audiences": ["https://management.adfs.azurestack.local/1234"]},
+		"suffixes": {"keyVaultDns": "vault.local.azurestack.external", "storage": "local.azurestack.external"}
+	}, {
+		"name": "AzureCloud",
+		"resourceManager": "https://management.azure.com/",
+		"microsoftGraphResourceId": "https://graph.microsoft.com/",
+		"authentication": {"loginEndpoint": "https://login.microsoftonline.com"},
+		"suffixes": {"keyVaultDns": "vault.azure.net", "storage": "core.windows.net"}
+	}]`)
+
+	setCloudEnv(t, map[string]string{"ARM_METADATA_HOSTNAME": host})
+	cloud, err := resolveCloud(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "AzureStackUser", cloud.Name)
+	assert.Equal(t, host, cloud.MetadataHost)
+	assert.Equal(t, "https://adfs.local.azurestack.external/adfs/", cloud.ActiveDirectory)
+	assert.Equal(t, "https://management.adfs.azurestack.local/1234", cloud.managementAudience())
+	assert.Equal(t, "local.azurestack.external", cloud.StorageEndpoint)
+
+	// Terraform knows the well known clouds without the metadata host
+	cloud, err = resolveCloud(&rover.CloudConfig{Name: "AzureCloud", MetadataHost: host})
+	assert.Nil(t, err)
+	assert.Equal(t, "public", cloud.TerraformName)
+	assert.Equal(t, "", cloud.MetadataHost)
+	assert.Equal(t, "https://login.microsoftonline.com/", cloud.ActiveDirectory)
+
+	_, err = resolveCloud(&rover.CloudConfig{Name: "AzureChinaCloud", MetadataHost: host})
+	assert.EqualError(t, err, "cloud metadata from "+host+" has no cloud matching 'AzureChinaCloud', it has: AzureStackUser, AzureCloud")
+}
+
+func Test_CLI_Cloud(t *testing.T) {
+	saved := cliCloudName
+	t.Cleanup(func() { cliCloudName = saved })
+	cliCloudName = func() (string, error) { return "AzureChinaCloud", nil }
+	public := findWellKnownCloud("AzureCloud")
+
+	// Without a cloud chosen, the CLI's is used
+	cloud, err := cliCloud(public, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "AzureChinaCloud", cloud.Name)
+	assert.Equal(t, "https://management.chinacloudapi.cn/", cloud.ResourceManagerURL)
+
+	// A cloud chosen in the config or environment has to agree with the CLI
+	_, err = cliCloud(public, true)
+	assert.EqualError(t, err, "the Azure CLI is set to cloud AzureChinaCloud but rover is set to use AzureCloud, run 'az cloud set --name AzureCloud' and sign in again, or change ARM_ENVIRONMENT or the cloud section of the rover config file")
+	cloud, err = cliCloud(findWellKnownCloud("AzureChinaCloud"), true)
+	assert.Nil(t, err)
+	assert.Equal(t, "AzureChinaCloud", cloud.Name)
+
+	cliCloudName = func() (string, error) { return "MyStack", nil }
+	_, err = cliCloud(public, false)
+	assert.EqualError(t, err, "the Azure CLI is set to cloud MyStack which rover doesn't know, define it in the cloud section of the rover config file")
+}
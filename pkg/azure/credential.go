@@ -0,0 +1,499 @@
+//
+// Rover - Azure credentials
+// * Signs into Azure without needing the Azure CLI, the CLI login is only used as a fallback
+// * The credential is chosen once per run, trying the same sources as the azidentity DefaultAzureCredential
+//
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/Azure/go-autorest/autorest/azure/cli"
+	"github.com/aztfmod/rover/pkg/command"
+	"github.com/aztfmod/rover/pkg/console"
+)
+
+// Ways of signing in, held in Subscription.Credential
+const (
+	CredentialClientSecret     = "client secret"
+	CredentialClientCert       = "client certificate"
+	CredentialWorkloadIdentity = "workload identity"
+	CredentialManagedIdentity  = "managed identity"
+	CredentialCLI              = "Azure CLI"
+)
+
+// accessToken is a bearer token for one Azure AD resource
+type accessToken struct {
+	Token     string
+	ExpiresOn time.Time
+}
+
+// credential signs into Azure one way, and knows the account that gives
+type credential interface {
+	name() string
+	token(resource string) (accessToken, error)
+	account() (*Subscription, error)
+	// terraformEnv are the ARM_ variables which have Terraform sign in the same way
+	terraformEnv() map[string]string
+}
+
+// Detection of the sign in methods which can't be found from environment variables, swapped in tests
+var managedIdentityAvailable = func() bool {
+	_, err := adal.GetMSIEndpoint()
+	return err == nil
+}
+var cliAvailable = func() bool {
+	return command.CheckCommand("az") == nil
+}
+
+// The credential for this run, chosen along with the current cloud
+var chosenCredential credential
+var chosenCredentialErr error
+
+// getCredential returns the credential for this run, chosen the first time it's needed
+func getCredential() (credential, error) {
+	_, err := currentCloud()
+	if err != nil {
+		return nil, err
+	}
+	return chosenCredential, chosenCredentialErr
+}
+
+// chooseCredential picks the first sign in method that is configured:
+// 1. client secret or certificate, from ARM_ or AZURE_ environment variables
+// 2. workload identity, an OIDC token from a file, ARM_OIDC_TOKEN, GitHub Actions or Azure DevOps
+// 3. managed identity, when asked for with ARM_USE_MSI
+// 4. the Azure CLI login
+// 5. managed identity, when there is no Azure CLI and one is available
+// Managed identity is only picked up automatically without the CLI, so 'az login' still wins on dev VMs
+func chooseCredential(cloud CloudDefinition) (credential, error) {
+	clientID := envValue("ARM_CLIENT_ID", "AZURE_CLIENT_ID")
+	tenantID := envValue("ARM_TENANT_ID", "AZURE_TENANT_ID")
+
+	if clientID != "" && tenantID != "" {
+		if secret := envValue("ARM_CLIENT_SECRET", "AZURE_CLIENT_SECRET"); secret != "" {
+			return &clientSecretCredential{cloud: cloud, tenantID: tenantID, clientID: clientID, secret: secret}, nil
+		}
+		if certPath := envValue("ARM_CLIENT_CERTIFICATE_PATH", "AZURE_CLIENT_CERTIFICATE_PATH"); certPath != "" {
+			return &clientCertCredential{
+				cloud:        cloud,
+				tenantID:     tenantID,
+				clientID:     clientID,
+				certPath:     certPath,
+				certPassword: envValue("ARM_CLIENT_CERTIFICATE_PASSWORD", "AZURE_CLIENT_CERTIFICATE_PASSWORD"),
+			}, nil
+		}
+		if source := findOIDCTokenSource(); source != nil {
+			return &workloadIdentityCredential{cloud: cloud, tenantID: tenantID, clientID: clientID, source: source}, nil
+		}
+	}
+
+	if strings.EqualFold(os.Getenv("ARM_USE_MSI"), "true") {
+		return &managedIdentityCredential{cloud: cloud, clientID: clientID}, nil
+	}
+	if cliAvailable() {
+		return &cliCredential{}, nil
+	}
+	if managedIdentityAvailable() {
+		return &managedIdentityCredential{cloud: cloud, clientID: clientID}, nil
+	}
+
+	return nil, errors.New("no Azure credentials found, set ARM_CLIENT_ID & ARM_TENANT_ID with ARM_CLIENT_SECRET, ARM_CLIENT_CERTIFICATE_PATH or an OIDC token, " +
+		"set ARM_USE_MSI=true to use a managed identity, or sign in with the Azure CLI, see https://docs.microsoft.com/en-us/cli/azure/install-azure-cli")
+}
+
+// envValue returns the first of the environment variables which is set, Terraform's ARM_ names are checked before AZURE_ ones
+func envValue(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// GetAuthorizer used for Azure SDK access logs into Azure
+// This should always be called before any Azure SDK calls
+func GetAuthorizer() (autorest.Authorizer, error) {
+	cloud, err := currentCloud()
+	if err != nil {
+		return nil, err
+	}
+	return authorizerForResource(cloud.managementAudience())
+}
+
+// authorizerForResource gives the SDK clients tokens for a resource from the chosen credential
+func authorizerForResource(resource string) (autorest.Authorizer, error) {
+	cred, err := getCredential()
+	if err != nil {
+		return nil, err
+	}
+	return autorest.NewBearerAuthorizer(&tokenProvider{credential: cred, resource: resource}), nil
+}
+
+// getToken gets a token for a resource from the chosen credential
+func getToken(resource string) (accessToken, error) {
+	cred, err := getCredential()
+	if err != nil {
+		return accessToken{}, err
+	}
+	return cred.token(resource)
+}
+
+// tokenProvider lets the SDK clients use a credential, getting a new token when the last is about to expire
+// It implements adal.OAuthTokenProvider and adal.RefresherWithContext, which autorest's bearer authorizer checks for
+type tokenProvider struct {
+	credential credential
+	resource   string
+
+	mu    sync.Mutex
+	token accessToken
+}
+
+func (p *tokenProvider) OAuthToken() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.token.Token
+}
+
+func (p *tokenProvider) EnsureFreshWithContext(ctx context.Context) error {
+	p.mu.Lock()
+	fresh := time.Until(p.token.ExpiresOn) > tokenRefreshMargin
+	p.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	return p.RefreshWithContext(ctx)
+}
+
+func (p *tokenProvider) RefreshWithContext(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token, err := p.credential.token(p.resource)
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *tokenProvider) RefreshExchangeWithContext(ctx context.Context, resource string) error {
+	p.mu.Lock()
+	p.resource = resource
+	p.mu.Unlock()
+	return p.RefreshWithContext(ctx)
+}
+
+// adalToken gets the token from an adal service principal token, as built by the auth package configs
+func adalToken(spt *adal.ServicePrincipalToken, err error) (accessToken, error) {
+	if err != nil {
+		return accessToken{}, err
+	}
+	err = spt.EnsureFresh()
+	if err != nil {
+		return accessToken{}, err
+	}
+	token := spt.Token()
+	return accessToken{Token: token.AccessToken, ExpiresOn: token.Expires()}, nil
+}
+
+// clientSecretCredential signs in as a service principal with a secret
+type clientSecretCredential struct {
+	cloud    CloudDefinition
+	tenantID string
+	clientID string
+	secret   string
+	acct     accountOnce
+}
+
+func (c *clientSecretCredential) name() string {
+	return CredentialClientSecret
+}
+
+func (c *clientSecretCredential) token(resource string) (accessToken, error) {
+	config := auth.NewClientCredentialsConfig(c.clientID, c.secret, c.tenantID)
+	config.AADEndpoint = c.cloud.ActiveDirectory
+	config.Resource = resource
+	return adalToken(config.ServicePrincipalToken())
+}
+
+func (c *clientSecretCredential) account() (*Subscription, error) {
+	return c.acct.get(c, c.cloud, AccountUser{Name: c.clientID, Usertype: "servicePrincipal"})
+}
+
+func (c *clientSecretCredential) terraformEnv() map[string]string {
+	// The secret may have come from AZURE_CLIENT_SECRET, which Terraform doesn't read
+	return map[string]string{"ARM_CLIENT_SECRET": c.secret}
+}
+
+// clientCertCredential signs in as a service principal with a PFX certificate
+type clientCertCredential struct {
+	cloud        CloudDefinition
+	tenantID     string
+	clientID     string
+	certPath     string
+	certPassword string
+	acct         accountOnce
+}
+
+func (c *clientCertCredential) name() string {
+	return CredentialClientCert
+}
+
+func (c *clientCertCredential) token(resource string) (accessToken, error) {
+	config := auth.NewClientCertificateConfig(c.certPath, c.certPassword, c.clientID, c.tenantID)
+	config.AADEndpoint = c.cloud.ActiveDirectory
+	config.Resource = resource
+	return adalToken(config.ServicePrincipalToken())
+}
+
+func (c *clientCertCredential) account() (*Subscription, error) {
+	return c.acct.get(c, c.cloud, AccountUser{Name: c.clientID, Usertype: "servicePrincipal"})
+}
+
+func (c *clientCertCredential) terraformEnv() map[string]string {
+	return map[string]string{"ARM_CLIENT_CERTIFICATE_PATH": c.certPath, "ARM_CLIENT_CERTIFICATE_PASSWORD": c.certPassword}
+}
+
+// workloadIdentityCredential exchanges an OIDC token from another identity provider, e.g. a CI system, for an Azure AD token
+type workloadIdentityCredential struct {
+	cloud    CloudDefinition
+	tenantID string
+	clientID string
+	source   oidcTokenSource
+	acct     accountOnce
+}
+
+func (c *workloadIdentityCredential) name() string {
+	return CredentialWorkloadIdentity
+}
+
+func (c *workloadIdentityCredential) token(resource string) (accessToken, error) {
+	// A new OIDC token is fetched each time, they are short lived
+	assertion, err := c.source.oidcToken()
+	if err != nil {
+		return accessToken{}, fmt.Errorf("unable to get an OIDC token from %s: %s", c.source.name(), err)
+	}
+	return exchangeClientAssertion(c.cloud.ActiveDirectory, c.tenantID, c.clientID, assertion, resource)
+}
+
+func (c *workloadIdentityCredential) account() (*Subscription, error) {
+	return c.acct.get(c, c.cloud, AccountUser{Name: c.clientID, Usertype: "servicePrincipal"})
+}
+
+func (c *workloadIdentityCredential) terraformEnv() map[string]string {
+	env := map[string]string{"ARM_USE_OIDC": "true"}
+	for name, value := range c.source.terraformEnv() {
+		env[name] = value
+	}
+	return env
+}
+
+// exchangeClientAssertion swaps a signed token from a trusted identity provider for an Azure AD token, using the client credentials grant
+func exchangeClientAssertion(authority string, tenantID string, clientID string, assertion string, resource string) (accessToken, error) {
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {clientID},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+		"scope":                 {strings.TrimSuffix(resource, "/") + "/.default"},
+	}
+	tokenURL := fmt.Sprintf("%s%s/oauth2/v2.0/token", authority, tenantID)
+	console.Debugf("Exchanging a federated token at %s\n", tokenURL)
+	resp, err := http.PostForm(tokenURL, form)
+	if err != nil {
+		return accessToken{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return accessToken{}, err
+	}
+
+	result := struct {
+		AccessToken      string      `json:"access_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		ErrorDescription string      `json:"error_description"`
+	}{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return accessToken{}, fmt.Errorf("unable to read the token response, %s: %s", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return accessToken{}, fmt.Errorf("federated token exchange failed, %s: %s", resp.Status, result.ErrorDescription)
+	}
+	expiresIn, err := result.ExpiresIn.Int64()
+	if err != nil {
+		return accessToken{}, fmt.Errorf("unable to read the token expiry: %s", err)
+	}
+	return accessToken{Token: result.AccessToken, ExpiresOn: time.Now().Add(time.Duration(expiresIn) * time.Second)}, nil
+}
+
+// managedIdentityCredential uses the system assigned managed identity, or a user assigned one when a client id is given
+type managedIdentityCredential struct {
+	cloud    CloudDefinition
+	clientID string
+	acct     accountOnce
+}
+
+func (c *managedIdentityCredential) name() string {
+	return CredentialManagedIdentity
+}
+
+func (c *managedIdentityCredential) token(resource string) (accessToken, error) {
+	config := auth.NewMSIConfig()
+	config.ClientID = c.clientID
+	config.Resource = resource
+	return adalToken(config.ServicePrincipalToken())
+}
+
+func (c *managedIdentityCredential) account() (*Subscription, error) {
+	// These match what 'az login --identity' reports, which is how the identity is found later
+	user := AccountUser{AssignedIdentityInfo: "MSI", Name: "systemAssignedIdentity", Usertype: "servicePrincipal"}
+	if c.clientID != "" {
+		user = AccountUser{AssignedIdentityInfo: "MSIClient-" + c.clientID, Name: "userAssignedIdentity", Usertype: "servicePrincipal"}
+	}
+	return c.acct.get(c, c.cloud, user)
+}
+
+func (c *managedIdentityCredential) terraformEnv() map[string]string {
+	// Covered by ARM_USE_MSI, which is also set when the CLI is signed in with a managed identity
+	return nil
+}
+
+// cliCredential uses whatever 'az login' signed in as
+type cliCredential struct{}
+
+func (c *cliCredential) name() string {
+	return CredentialCLI
+}
+
+func (c *cliCredential) token(resource string) (accessToken, error) {
+	token, err := cli.GetTokenFromCLI(resource)
+	if err != nil {
+		return accessToken{}, err
+	}
+	expiresOn, err := cli.ParseExpirationDate(token.ExpiresOn)
+	if err != nil {
+		return accessToken{}, err
+	}
+	return accessToken{Token: token.AccessToken, ExpiresOn: *expiresOn}, nil
+}
+
+func (c *cliCredential) account() (*Subscription, error) {
+	cmdRes, err := command.QuickRun("az", "account", "show", "-o=json")
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{}
+	err = json.Unmarshal([]byte(cmdRes), sub)
+	if err != nil {
+		return nil, err
+	}
+	sub.Credential = CredentialCLI
+	return sub, nil
+}
+
+func (c *cliCredential) terraformEnv() map[string]string {
+	// Terraform uses the CLI login itself
+	return nil
+}
+
+// Management API version used to read subscriptions
+const subscriptionsAPIVersion = "2020-01-01"
+
+type subscriptionResult struct {
+	SubscriptionID string `json:"subscriptionId"`
+	TenantID       string `json:"tenantId"`
+	DisplayName    string `json:"displayName"`
+}
+
+// accountOnce looks up the account for a credential once, the subscription env vars are changed by SetupEnvironment later
+type accountOnce struct {
+	once sync.Once
+	sub  *Subscription
+	err  error
+}
+
+func (a *accountOnce) get(cred credential, cloud CloudDefinition, user AccountUser) (*Subscription, error) {
+	a.once.Do(func() {
+		a.sub, a.err = lookupAccount(cred, cloud, user)
+	})
+	return a.sub, a.err
+}
+
+// lookupAccount finds the subscription for a credential, from ARM_SUBSCRIPTION_ID or the only subscription it can access
+// The tenant comes from the subscription, so a managed identity doesn't need it to be given
+func lookupAccount(cred credential, cloud CloudDefinition, user AccountUser) (*Subscription, error) {
+	token, err := cred.token(cloud.managementAudience())
+	if err != nil {
+		return nil, err
+	}
+
+	subID := envValue("ARM_SUBSCRIPTION_ID", "AZURE_SUBSCRIPTION_ID")
+	var sub subscriptionResult
+	if subID != "" {
+		err = managementGet(cloud, token, fmt.Sprintf("subscriptions/%s", subID), &sub)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		list := struct {
+			Value []subscriptionResult `json:"value"`
+		}{}
+		err = managementGet(cloud, token, "subscriptions", &list)
+		if err != nil {
+			return nil, err
+		}
+		if len(list.Value) != 1 {
+			return nil, fmt.Errorf("the %s credential can access %d subscriptions, set ARM_SUBSCRIPTION_ID to choose one", cred.name(), len(list.Value))
+		}
+		sub = list.Value[0]
+	}
+
+	return &Subscription{
+		EnvironmentName: cloud.Name,
+		TenantID:        sub.TenantID,
+		Name:            sub.DisplayName,
+		ID:              sub.SubscriptionID,
+		User:            user,
+		Credential:      cred.name(),
+		TerraformEnv:    cred.terraformEnv(),
+	}, nil
+}
+
+func managementGet(cloud CloudDefinition, token accessToken, path string, result interface{}) error {
+	urlString := fmt.Sprintf("%s%s?api-version=%s", cloud.ResourceManagerURL, path, subscriptionsAPIVersion)
+	req, err := http.NewRequest("GET", urlString, nil)
+	if err != nil {
+		return err
+	}
+	console.Debugf("Making API call to %s\n", urlString)
+
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	req.Header.Add("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Azure management API error reading %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var credentialEnvVars = []string{
+	"ARM_CLIENT_ID", "AZURE_CLIENT_ID", "ARM_TENANT_ID", "AZURE_TENANT_ID",
+	"ARM_CLIENT_SECRET", "AZURE_CLIENT_SECRET", "ARM_CLIENT_CERTIFICATE_PATH", "AZURE_CLIENT_CERTIFICATE_PATH",
+	"AZURE_FEDERATED_TOKEN_FILE", "ARM_OIDC_TOKEN_FILE_PATH", "ARM_OIDC_TOKEN", "ARM_OIDC_REQUEST_URL", "ARM_OIDC_REQUEST_TOKEN",
+	"ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN", "SYSTEM_OIDCREQUESTURI", "SYSTEM_ACCESSTOKEN",
+	"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID", "ARM_OIDC_AZURE_SERVICE_CONNECTION_ID",
+	"ARM_USE_MSI", "ARM_SUBSCRIPTION_ID", "AZURE_SUBSCRIPTION_ID",
+}
+
+// setCredentialEnv clears the credential env vars, sets the given ones & stubs out CLI and managed identity detection
+func setCredentialEnv(t *testing.T, env map[string]string, cli bool, msi bool) {
+	saved := map[string]string{}
+	for _, name := range credentialEnvVars {
+		saved[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	savedCLI, savedMSI := cliAvailable, managedIdentityAvailable
+	cliAvailable = func() bool { return cli }
+	managedIdentityAvailable = func() bool { return msi }
+
+	t.Cleanup(func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+			if value == "" {
+				os.Unsetenv(name)
+			}
+		}
+		cliAvailable, managedIdentityAvailable = savedCLI, savedMSI
+	})
+}
+
+func Test_Choose_Credential(t *testing.T) {
+	sp := map[string]string{"ARM_CLIENT_ID": "client", "ARM_TENANT_ID": "tenant"}
+	with := func(extra map[string]string) map[string]string {
+		env := map[string]string{}
+		for k, v := range sp {
+			env[k] = v
+		}
+		for k, v := range extra {
+			env[k] = v
+		}
+		return env
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		cli  bool
+		msi  bool
+		want string
+	}{
+		{"secret", with(map[string]string{"ARM_CLIENT_SECRET": "s"}), true, true, CredentialClientSecret},
+		{"azure secret", map[string]string{"AZURE_CLIENT_ID": "c", "AZURE_TENANT_ID": "t", "AZURE_CLIENT_SECRET": "s"}, false, false, CredentialClientSecret},
+		{"certificate", with(map[string]string{"ARM_CLIENT_CERTIFICATE_PATH": "sp.pfx"}), true, false, CredentialClientCert},
+		{"workload identity", with(map[string]string{"AZURE_FEDERATED_TOKEN_FILE": "token"}), true, true, CredentialWorkloadIdentity},
+		{"oidc token", with(map[string]string{"ARM_OIDC_TOKEN": "token"}), true, false, CredentialWorkloadIdentity},
+		{"oidc needs client", map[string]string{"ARM_OIDC_TOKEN": "token"}, true, false, CredentialCLI},
+		{"secret needs tenant", map[string]string{"ARM_CLIENT_ID": "c", "ARM_CLIENT_SECRET": "s"}, true, false, CredentialCLI},
+		{"msi asked for", map[string]string{"ARM_USE_MSI": "true"}, true, true, CredentialManagedIdentity},
+		{"cli before detected msi", nil, true, true, CredentialCLI},
+		{"msi without cli", nil, false, true, CredentialManagedIdentity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCredentialEnv(t, tt.env, tt.cli, tt.msi)
+			cred, err := chooseCredential(wellKnownClouds[0])
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, cred.name())
+		})
+	}
+
+	setCredentialEnv(t, nil, false, false)
+	_, err := chooseCredential(wellKnownClouds[0])
+	assert.NotNil(t, err)
+}
+
+func Test_Workload_Identity_Account(t *testing.T) {
+	var assertion, scope string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		assertion = r.FormValue("client_assertion")
+		scope = r.FormValue("scope")
+		w.Write([]byte(`{"access_token":"azure-token","expires_in":3600}`))
+	})
+	mux.HandleFunc("/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer azure-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"value": []subscriptionResult{{SubscriptionID: "sub", TenantID: "tenant", DisplayName: "CI"}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, os.WriteFile(tokenFile, []byte("federated-token\n"), 0600))
+	setCredentialEnv(t, nil, false, false)
+
+	cloud := wellKnownClouds[0]
+	cloud.ActiveDirectory = server.URL + "/"
+	cloud.ResourceManagerURL = server.URL + "/"
+	cred := &workloadIdentityCredential{cloud: cloud, tenantID: "tenant", clientID: "client", source: &oidcTokenFile{path: tokenFile}}
+
+	sub, err := cred.account()
+	assert.Nil(t, err)
+	assert.Equal(t, "federated-token", assertion)
+	assert.Equal(t, server.URL+"/.default", scope)
+	assert.Equal(t, "sub", sub.ID)
+	assert.Equal(t, "tenant", sub.TenantID)
+	assert.Equal(t, "client", sub.User.Name)
+	assert.Equal(t, "servicePrincipal", sub.User.Usertype)
+	assert.Equal(t, CredentialWorkloadIdentity, sub.Credential)
+	assert.Equal(t, map[string]string{"ARM_USE_OIDC": "true", "ARM_OIDC_TOKEN_FILE_PATH": tokenFile}, sub.TerraformEnv)
+}
@@ -6,13 +6,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 
-	"github.com/Azure/go-autorest/autorest/azure/cli"
 	"github.com/aztfmod/rover/pkg/console"
 )
 
-const graphAPIEndpoint = "https://graph.microsoft.com"
-
 type graphResult struct {
 	Value []struct {
 		AppDisplayName string
@@ -22,7 +20,16 @@ type graphResult struct {
 }
 
 func GetServicePrincipalIdentity(clientID string) (*Identity, error) {
-	token, err := cli.GetTokenFromCLI(graphAPIEndpoint)
+	cloud, err := currentCloud()
+	if err != nil {
+		return nil, err
+	}
+	if cloud.GraphEndpoint == "" {
+		return nil, fmt.Errorf("the %s cloud has no Microsoft Graph endpoint, set graph in the cloud section of the rover config file", cloud.Name)
+	}
+	graphAPIEndpoint := strings.TrimSuffix(cloud.GraphEndpoint, "/")
+
+	token, err := getToken(graphAPIEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +45,7 @@ func GetServicePrincipalIdentity(clientID string) (*Identity, error) {
 	}
 	console.Debugf("Making API call to %s\n", urlString)
 
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
 	req.Header.Add("Accept", "application/json")
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -8,11 +8,9 @@ package azure
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/services/keyvault/v7.1/keyvault"
-	"github.com/Azure/go-autorest/autorest/azure/auth"
 )
 
 type SdkKVClient struct {
@@ -28,7 +26,7 @@ func NewKVClient(audienceHostName string, vaultName string) (*SdkKVClient, error
 	kvc.VaultName = vaultName
 
 	kvc.Client = keyvault.New()
-	authorizer, err := auth.NewAuthorizerFromCLIWithResource(kvc.Audience())
+	authorizer, err := authorizerForResource(kvc.Audience())
 	if err != nil {
 		return nil, err
 	}
@@ -57,33 +55,7 @@ func (kvc *SdkKVClient) GetSecret(secretName string) (string, error) {
 	return *secretValue, nil
 }
 
-// FindKeyVault returns resource id for a CAF tagged keyvault
-func FindKeyVault(level string, environment string, subID string) (string, error) {
-	query := fmt.Sprintf(`Resources 
-		| where type == 'microsoft.keyvault/vaults' 
-		| where tags.level == '%s'
-		| where tags.environment == '%s'
-		| limit 1
-		| project id`, level, environment)
-
-	queryResults, err := RunQuery(query, subID)
-	if err != nil {
-		return "", err
-	}
-
-	resSlice, ok := queryResults.([]interface{})
-	if !ok {
-		return "", errors.New("findKeyVault: error asserting query results")
-	}
-
-	if len(resSlice) <= 0 {
-		return "", errors.New("no keyvault found")
-	}
-
-	resMap, ok := resSlice[0].(map[string]interface{})
-	if !ok {
-		return "", errors.New("findKeyVault: Failed to parse query results")
-	}
-
-	return resMap["id"].(string), nil
+// FindKeyVault returns the resource id of the one key vault with all the tags
+func FindKeyVault(tags map[string]string, subID string) (string, error) {
+	return findTaggedResource("key vault", "microsoft.keyvault/vaults", tags, subID)
 }
@@ -0,0 +1,195 @@
+//
+// Rover - OIDC token sources
+// * CI systems & Kubernetes issue OIDC tokens which Azure AD accepts for a service principal with a federated credential
+// * Each source is also described to Terraform, so it can fetch fresh tokens for itself during long runs
+//
+
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/console"
+)
+
+// Audience Azure AD expects in federated tokens
+const oidcAudience = "api://AzureADTokenExchange"
+
+// oidcTokenSource gets an OIDC token to exchange for an Azure AD token
+type oidcTokenSource interface {
+	name() string
+	oidcToken() (string, error)
+	// terraformEnv are the ARM_ variables which have the azurerm provider & backend use the same source
+	terraformEnv() map[string]string
+}
+
+// findOIDCTokenSource returns the first OIDC token source which is configured, nil when there are none
+func findOIDCTokenSource() oidcTokenSource {
+	if path := envValue("AZURE_FEDERATED_TOKEN_FILE", "ARM_OIDC_TOKEN_FILE_PATH"); path != "" {
+		return &oidcTokenFile{path: path}
+	}
+	if token := envValue("ARM_OIDC_TOKEN"); token != "" {
+		return &oidcTokenValue{token: token}
+	}
+
+	requestURL := envValue("ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := envValue("ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	// Azure DevOps sets SYSTEM_OIDCREQUESTURI in every pipeline, only a service connection means OIDC is wanted
+	serviceConnectionID := envValue("ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID", "ARM_OIDC_AZURE_SERVICE_CONNECTION_ID")
+	if serviceConnectionID != "" {
+		requestURL = envValue("ARM_OIDC_REQUEST_URL", "SYSTEM_OIDCREQUESTURI")
+		requestToken = envValue("ARM_OIDC_REQUEST_TOKEN", "SYSTEM_ACCESSTOKEN")
+		if requestURL != "" && requestToken != "" {
+			return &azureDevOpsOIDC{requestURL: requestURL, requestToken: requestToken, serviceConnectionID: serviceConnectionID}
+		}
+		return nil
+	}
+	if requestURL != "" && requestToken != "" {
+		return &githubOIDC{requestURL: requestURL, requestToken: requestToken}
+	}
+	return nil
+}
+
+// oidcTokenFile reads a token from a file, kept up to date by whatever projects it, e.g. AKS workload identity
+type oidcTokenFile struct {
+	path string
+}
+
+func (s *oidcTokenFile) name() string {
+	return "token file " + s.path
+}
+
+func (s *oidcTokenFile) oidcToken() (string, error) {
+	// The file is re-read each time, as it's rotated
+	token, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the federated token: %s", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+func (s *oidcTokenFile) terraformEnv() map[string]string {
+	return map[string]string{"ARM_OIDC_TOKEN_FILE_PATH": s.path}
+}
+
+// oidcTokenValue is a token passed in ARM_OIDC_TOKEN, it can't be refreshed
+type oidcTokenValue struct {
+	token string
+}
+
+func (s *oidcTokenValue) name() string {
+	return "ARM_OIDC_TOKEN"
+}
+
+func (s *oidcTokenValue) oidcToken() (string, error) {
+	return s.token, nil
+}
+
+func (s *oidcTokenValue) terraformEnv() map[string]string {
+	return map[string]string{"ARM_OIDC_TOKEN": s.token}
+}
+
+// githubOIDC requests tokens from GitHub Actions, the workflow needs the 'id-token: write' permission
+type githubOIDC struct {
+	requestURL   string
+	requestToken string
+}
+
+func (s *githubOIDC) name() string {
+	return "GitHub Actions"
+}
+
+func (s *githubOIDC) oidcToken() (string, error) {
+	requestURL, err := url.Parse(s.requestURL)
+	if err != nil {
+		return "", err
+	}
+	query := requestURL.Query()
+	query.Set("audience", oidcAudience)
+	requestURL.RawQuery = query.Encode()
+
+	result := struct {
+		Value string `json:"value"`
+	}{}
+	err = requestOIDCToken("GET", requestURL.String(), s.requestToken, &result)
+	if err != nil {
+		return "", err
+	}
+	if result.Value == "" {
+		return "", errors.New("GitHub Actions returned an empty OIDC token")
+	}
+	return result.Value, nil
+}
+
+func (s *githubOIDC) terraformEnv() map[string]string {
+	return map[string]string{"ARM_OIDC_REQUEST_URL": s.requestURL, "ARM_OIDC_REQUEST_TOKEN": s.requestToken}
+}
+
+// azureDevOpsOIDC requests tokens for a workload identity federation service connection from Azure Pipelines
+// SYSTEM_ACCESSTOKEN has to be mapped into the step's environment
+type azureDevOpsOIDC struct {
+	requestURL          string
+	requestToken        string
+	serviceConnectionID string
+}
+
+func (s *azureDevOpsOIDC) name() string {
+	return "Azure DevOps service connection " + s.serviceConnectionID
+}
+
+func (s *azureDevOpsOIDC) oidcToken() (string, error) {
+	requestURL, err := url.Parse(s.requestURL)
+	if err != nil {
+		return "", err
+	}
+	query := requestURL.Query()
+	query.Set("api-version", "7.1")
+	query.Set("serviceConnectionId", s.serviceConnectionID)
+	requestURL.RawQuery = query.Encode()
+
+	result := struct {
+		OIDCToken string `json:"oidcToken"`
+	}{}
+	err = requestOIDCToken("POST", requestURL.String(), s.requestToken, &result)
+	if err != nil {
+		return "", err
+	}
+	if result.OIDCToken == "" {
+		return "", errors.New("Azure DevOps returned an empty OIDC token")
+	}
+	return result.OIDCToken, nil
+}
+
+func (s *azureDevOpsOIDC) terraformEnv() map[string]string {
+	return map[string]string{
+		"ARM_OIDC_REQUEST_URL":                   s.requestURL,
+		"ARM_OIDC_REQUEST_TOKEN":                 s.requestToken,
+		"ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID": s.serviceConnectionID,
+	}
+}
+
+func requestOIDCToken(method string, requestURL string, bearer string, result interface{}) error {
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	console.Debugf("Requesting an OIDC token from %s\n", requestURL)
+
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Add("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OIDC token request failed: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,88 @@
+//go:build unit
+// +build unit
+
+package azure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Find_OIDC_Token_Source(t *testing.T) {
+	setCredentialEnv(t, nil, false, false)
+	assert.Nil(t, findOIDCTokenSource())
+
+	setCredentialEnv(t, map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": "https://gh", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "t"}, false, false)
+	assert.IsType(t, &githubOIDC{}, findOIDCTokenSource())
+
+	// Every Azure DevOps pipeline has SYSTEM_OIDCREQUESTURI, it's only used with a service connection
+	setCredentialEnv(t, map[string]string{"SYSTEM_OIDCREQUESTURI": "https://ado", "SYSTEM_ACCESSTOKEN": "t"}, false, false)
+	assert.Nil(t, findOIDCTokenSource())
+	setCredentialEnv(t, map[string]string{"SYSTEM_OIDCREQUESTURI": "https://ado", "SYSTEM_ACCESSTOKEN": "t", "ARM_ADO_PIPELINE_SERVICE_CONNECTION_ID": "sc"}, false, false)
+	assert.IsType(t, &azureDevOpsOIDC{}, findOIDCTokenSource())
+
+	setCredentialEnv(t, map[string]string{"ARM_OIDC_TOKEN": "token", "ACTIONS_ID_TOKEN_REQUEST_URL": "https://gh", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "t"}, false, false)
+	assert.IsType(t, &oidcTokenValue{}, findOIDCTokenSource())
+}
+
+// Stands in for the CI system issuing OIDC tokens and Azure AD exchanging them
+func newOIDCServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/github", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != oidcAudience {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"value":"github-oidc"}`))
+	})
+	mux.HandleFunc("/ado", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Authorization") != "Bearer system-token" || r.URL.Query().Get("serviceConnectionId") != "sc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"oidcToken":"ado-oidc"}`))
+	})
+	mux.HandleFunc("/tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("client_assertion") {
+		case "github-oidc", "ado-oidc":
+			w.Write([]byte(`{"access_token":"azure-token","expires_in":"3600"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error_description":"AADSTS700024: Client assertion is not within its valid time range"}`))
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_OIDC_Token_Exchange(t *testing.T) {
+	server := newOIDCServer(t)
+	cloud := wellKnownClouds[0]
+	cloud.ActiveDirectory = server.URL + "/"
+
+	sources := []oidcTokenSource{
+		&githubOIDC{requestURL: server.URL + "/github?api-version=2.0", requestToken: "request-token"},
+		&azureDevOpsOIDC{requestURL: server.URL + "/ado", requestToken: "system-token", serviceConnectionID: "sc"},
+	}
+	for _, source := range sources {
+		t.Run(source.name(), func(t *testing.T) {
+			cred := &workloadIdentityCredential{cloud: cloud, tenantID: "tenant", clientID: "client", source: source}
+			token, err := cred.token(storageResource)
+			assert.Nil(t, err)
+			assert.Equal(t, "azure-token", token.Token)
+			assert.Equal(t, "true", cred.terraformEnv()["ARM_USE_OIDC"])
+		})
+	}
+
+	cred := &workloadIdentityCredential{cloud: cloud, tenantID: "tenant", clientID: "client", source: &oidcTokenValue{token: "expired"}}
+	_, err := cred.token(storageResource)
+	assert.Contains(t, err.Error(), "AADSTS700024")
+
+	cred.source = &githubOIDC{requestURL: server.URL + "/github", requestToken: "wrong"}
+	_, err = cred.token(storageResource)
+	assert.Contains(t, err.Error(), "403")
+}
@@ -0,0 +1,219 @@
+//
+// Rover - Azure session
+// * One session is shared by every stack in a rover run, so what's looked up in Azure is only looked up once
+// * Covers the subscription, identities, launchpad storage accounts & key vaults, secrets and storage account access
+//
+
+package azure
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// Session is a Cloud which remembers its lookups for the length of a run, blob operations are passed straight through
+// Failed lookups are not remembered, e.g. a launchpad may only be created part way through the run
+type Session struct {
+	base Cloud
+	// azureADAuth is how the blob service URLs authenticate, see NewSession
+	azureADAuth bool
+
+	mu          sync.Mutex
+	lookups     map[string]interface{}
+	serviceURLs map[string]*azblob.ServiceURL
+}
+
+// NewSession returns a session for a run, using the Azure SDK
+// azureADAuth is how blobs are accessed, it should match how terraform authenticates to state
+func NewSession(azureADAuth bool) *Session {
+	s := NewSessionFor(nil)
+	s.base = SDKCloud{session: s, AzureADAuth: azureADAuth}
+	s.azureADAuth = azureADAuth
+	return s
+}
+
+// NewSessionFor returns a session remembering the lookups made with another Cloud, e.g. an azuretest.FakeCloud in tests
+func NewSessionFor(base Cloud) *Session {
+	return &Session{
+		base:        base,
+		lookups:     map[string]interface{}{},
+		serviceURLs: map[string]*azblob.ServiceURL{},
+	}
+}
+
+// remember returns the result of an earlier lookup with the same key, or does the lookup and keeps the result if it worked
+func (s *Session) remember(key string, lookup func() (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	value, found := s.lookups[key]
+	s.mu.Unlock()
+	if found {
+		return value, nil
+	}
+
+	value, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.lookups[key] = value
+	s.mu.Unlock()
+	return value, nil
+}
+
+// serviceURL returns the blob service URL for a storage account, building it the first time, see SDKCloud.serviceURL
+func (s *Session) serviceURL(storageAcctID string) (*azblob.ServiceURL, error) {
+	s.mu.Lock()
+	svcURL, found := s.serviceURLs[storageAcctID]
+	s.mu.Unlock()
+	if found {
+		return svcURL, nil
+	}
+
+	svcURL, err := newServiceURL(s, storageAcctID, s.azureADAuth)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.serviceURLs[storageAcctID] = svcURL
+	s.mu.Unlock()
+	return svcURL, nil
+}
+
+func (s *Session) GetSubscription() (*Subscription, error) {
+	value, err := s.remember("subscription", func() (interface{}, error) {
+		return s.base.GetSubscription()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Subscription), nil
+}
+
+func (s *Session) GetSignedInIdentity() (*Identity, error) {
+	value, err := s.remember("identity", func() (interface{}, error) {
+		return s.base.GetSignedInIdentity()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Identity), nil
+}
+
+func (s *Session) GetServicePrincipalIdentity(clientID string) (*Identity, error) {
+	value, err := s.remember("sp|"+clientID, func() (interface{}, error) {
+		return s.base.GetServicePrincipalIdentity(clientID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Identity), nil
+}
+
+func (s *Session) VMInstanceMetadataService() *Metadata {
+	value, _ := s.remember("vm", func() (interface{}, error) {
+		return s.base.VMInstanceMetadataService(), nil
+	})
+	return value.(*Metadata)
+}
+
+func (s *Session) GetVMIdentities(subID string, resourceGroupName string, vmName string) ([]Identity, error) {
+	value, err := s.remember(fmt.Sprintf("vm-identities|%s|%s|%s", subID, resourceGroupName, vmName), func() (interface{}, error) {
+		return s.base.GetVMIdentities(subID, resourceGroupName, vmName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Identity), nil
+}
+
+func (s *Session) CheckIsOwner(objectID string, subID string) (bool, error) {
+	value, err := s.remember(fmt.Sprintf("owner|%s|%s", objectID, subID), func() (interface{}, error) {
+		return s.base.CheckIsOwner(objectID, subID)
+	})
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+func (s *Session) FindStorageAccount(tags map[string]string, subID string) (string, error) {
+	value, err := s.remember(fmt.Sprintf("storage|%s|%s", DescribeTags(tags), subID), func() (interface{}, error) {
+		return s.base.FindStorageAccount(tags, subID)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (s *Session) FindKeyVault(tags map[string]string, subID string) (string, error) {
+	value, err := s.remember(fmt.Sprintf("keyvault|%s|%s", DescribeTags(tags), subID), func() (interface{}, error) {
+		return s.base.FindKeyVault(tags, subID)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (s *Session) GetKeyVaultSecret(keyVaultID string, secretName string) (string, error) {
+	value, err := s.remember(fmt.Sprintf("secret|%s|%s", keyVaultID, secretName), func() (interface{}, error) {
+		return s.base.GetKeyVaultSecret(keyVaultID, secretName)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (s *Session) GetAccountKey(subID string, accountName string, resGrp string) (string, error) {
+	value, err := s.remember(fmt.Sprintf("account-key|%s|%s|%s", subID, resGrp, accountName), func() (interface{}, error) {
+		return s.base.GetAccountKey(subID, accountName, resGrp)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (s *Session) ListContainers(storageAcctID string) ([]string, error) {
+	return s.base.ListContainers(storageAcctID)
+}
+
+func (s *Session) CreateContainer(storageAcctID string, blobContainer string) error {
+	return s.base.CreateContainer(storageAcctID, blobContainer)
+}
+
+func (s *Session) DeleteContainer(storageAcctID string, blobContainer string) error {
+	return s.base.DeleteContainer(storageAcctID, blobContainer)
+}
+
+func (s *Session) ListBlobs(storageAcctID string, blobContainer string) ([]azblob.BlobItemInternal, error) {
+	return s.base.ListBlobs(storageAcctID, blobContainer)
+}
+
+func (s *Session) UploadFileToBlobIfMatch(storageAcctID string, blobContainer string, blobName string, filePath string, etag string) error {
+	return s.base.UploadFileToBlobIfMatch(storageAcctID, blobContainer, blobName, filePath, etag)
+}
+
+func (s *Session) DownloadFileFromBlob(storageAcctID string, blobContainer string, blobName string, filePath string) (string, error) {
+	return s.base.DownloadFileFromBlob(storageAcctID, blobContainer, blobName, filePath)
+}
+
+func (s *Session) CopyBlob(storageAcctID string, srcContainer string, srcBlob string, destContainer string, destBlob string) error {
+	return s.base.CopyBlob(storageAcctID, srcContainer, srcBlob, destContainer, destBlob)
+}
+
+func (s *Session) DeleteBlobIfMatch(storageAcctID string, blobContainer string, blobName string, etag string) error {
+	return s.base.DeleteBlobIfMatch(storageAcctID, blobContainer, blobName, etag)
+}
+
+func (s *Session) GetBlobLease(storageAcctID string, blobContainer string, blobName string) (*BlobLease, error) {
+	return s.base.GetBlobLease(storageAcctID, blobContainer, blobName)
+}
+
+func (s *Session) BreakBlobLease(storageAcctID string, blobContainer string, blobName string, metadataKey string, etag string) error {
+	return s.base.BreakBlobLease(storageAcctID, blobContainer, blobName, metadataKey, etag)
+}
@@ -0,0 +1,71 @@
+//go:build unit
+// +build unit
+
+package azure_test
+
+import (
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/azure/azuretest"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCloud counts the lookups which reach the FakeCloud underneath
+type countingCloud struct {
+	*azuretest.FakeCloud
+	calls map[string]int
+}
+
+func (c *countingCloud) GetSubscription() (*azure.Subscription, error) {
+	c.calls["GetSubscription"]++
+	return c.FakeCloud.GetSubscription()
+}
+
+func (c *countingCloud) FindStorageAccount(tags map[string]string, subID string) (string, error) {
+	c.calls["FindStorageAccount"]++
+	return c.FakeCloud.FindStorageAccount(tags, subID)
+}
+
+func (c *countingCloud) GetKeyVaultSecret(keyVaultID string, secretName string) (string, error) {
+	c.calls["GetKeyVaultSecret"]++
+	return c.FakeCloud.GetKeyVaultSecret(keyVaultID, secretName)
+}
+
+func Test_Session(t *testing.T) {
+	const storageID = "/subscriptions/sub-1/resourceGroups/rg-launchpad/providers/Microsoft.Storage/storageAccounts/stlevel0"
+	const vaultID = "/subscriptions/sub-1/resourceGroups/rg-launchpad/providers/Microsoft.KeyVault/vaults/kv-level0"
+	base := &countingCloud{FakeCloud: azuretest.NewFakeCloud(azure.Subscription{ID: "sub-1"}), calls: map[string]int{}}
+	base.AddKeyVault(vaultID, "level0", "sandpit", map[string]string{"tenant-id": "tenant-1"})
+	session := azure.NewSessionFor(base)
+
+	for i := 0; i < 3; i++ {
+		sub, err := session.GetSubscription()
+		assert.Nil(t, err)
+		assert.Equal(t, "sub-1", sub.ID)
+		secret, err := session.GetKeyVaultSecret(vaultID, "tenant-id")
+		assert.Nil(t, err)
+		assert.Equal(t, "tenant-1", secret)
+	}
+	assert.Equal(t, 1, base.calls["GetSubscription"])
+	assert.Equal(t, 1, base.calls["GetKeyVaultSecret"])
+
+	// The launchpad isn't there until it's deployed part way through the run, so not finding it isn't remembered
+	tags := map[string]string{"level": "level0", "environment": "sandpit"}
+	_, err := session.FindStorageAccount(tags, "sub-1")
+	assert.True(t, azure.IsLaunchpadNotFound(err))
+	base.AddStorageAccount(storageID, "level0", "sandpit")
+	for i := 0; i < 2; i++ {
+		id, err := session.FindStorageAccount(tags, "sub-1")
+		assert.Nil(t, err)
+		assert.Equal(t, storageID, id)
+	}
+	assert.Equal(t, 2, base.calls["FindStorageAccount"])
+
+	// Blob operations always reach the cloud
+	assert.Nil(t, session.CreateContainer(storageID, "tfstate"))
+	assert.Nil(t, base.PutBlob(storageID, "tfstate", "web.tfstate", []byte("{}")))
+	blobs, err := session.ListBlobs(storageID, "tfstate")
+	assert.Nil(t, err)
+	assert.Len(t, blobs, 1)
+}
@@ -7,190 +7,467 @@
 package azure
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/aztfmod/rover/pkg/console"
 )
 
-// FindStorageAccount returns resource id for a CAF tagged storage account
-func FindStorageAccount(level string, environment string, subID string) (string, error) {
-	query := fmt.Sprintf(`Resources 
-		| where type == 'microsoft.storage/storageaccounts' 
-		| where tags.level == '%s'
-		| where tags.environment == '%s'
-		| limit 1
-		| project id`, level, environment)
+// FindStorageAccount returns the resource id of the one storage account with all the tags
+func FindStorageAccount(tags map[string]string, subID string) (string, error) {
+	return findTaggedResource("storage account", "microsoft.storage/storageaccounts", tags, subID)
+}
 
-	queryResults, err := RunQuery(query, subID)
+// GetAccountKey fetches the access key for a storage account
+func GetAccountKey(subID string, accountName string, resGrp string) (string, error) {
+	baseURI, err := resourceManagerBaseURI()
 	if err != nil {
 		return "", err
 	}
-
-	resSlice, ok := queryResults.([]interface{})
-	if !ok {
-		return "", errors.New("FindStorageAccount: Failed to parse query results")
+	client := storage.NewAccountsClientWithBaseURI(baseURI, subID)
+	authorizer, err := GetAuthorizer()
+	if err != nil {
+		return "", err
 	}
+	client.Authorizer = authorizer
 
-	if len(resSlice) <= 0 {
-		return "", errors.New("No storage account found")
+	keysRes, err := client.ListKeys(context.Background(), resGrp, accountName, storage.Kerb)
+	if err != nil {
+		return "", err
 	}
 
-	resMap, ok := resSlice[0].(map[string]interface{})
-	if !ok {
-		return "", errors.New("FindStorageAccount: Failed to parse query results")
-	}
+	return *(*keysRes.Keys)[0].Value, nil
+}
+
+// AAD resource for blob data access, how long before a token expires it is refreshed, and how soon a failed refresh is retried
+const storageResource = "https://storage.azure.com/"
+const tokenRefreshMargin = 5 * time.Minute
+const tokenRetryInterval = time.Minute
 
-	return resMap["id"].(string), nil
+// storageCredential is the one blob storage token credential for the run, each credential has its own refresh timer
+// The token is for the signed in identity, so it's the same for every storage account
+var storageCredential struct {
+	sync.Mutex
+	credential azblob.TokenCredential
 }
 
-// GetAccountKey fetches the access key for a storage account
-func GetAccountKey(subID string, accountName string, resGrp string) (string, error) {
-	client := storage.NewAccountsClient(subID)
-	authorizer, err := GetAuthorizer()
+// storageTokenCredential gets the blob storage token credential for the signed in identity, refreshing it before it expires
+func storageTokenCredential() (azblob.TokenCredential, error) {
+	storageCredential.Lock()
+	defer storageCredential.Unlock()
+	if storageCredential.credential != nil {
+		return storageCredential.credential, nil
+	}
+
+	token, expiresOn, err := storageToken()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	client.Authorizer = authorizer
 
-	keysRes, err := client.ListKeys(context.Background(), resGrp, accountName, storage.Kerb)
+	storageCredential.credential = azblob.NewTokenCredential(token, func(credential azblob.TokenCredential) time.Duration {
+		// This is called straight away too, when the token will still be fresh
+		if time.Until(expiresOn) > tokenRefreshMargin {
+			return time.Until(expiresOn) - tokenRefreshMargin
+		}
+
+		token, newExpiresOn, err := storageToken()
+		if err != nil {
+			// Returning 0 would stop the refreshes for good, and the credential is shared by the rest of the run
+			console.Warningf("Unable to refresh the storage token, retrying in %s: %s\n", tokenRetryInterval, err)
+			return tokenRetryInterval
+		}
+		credential.SetToken(token)
+		expiresOn = newExpiresOn
+		return time.Until(expiresOn) - tokenRefreshMargin
+	})
+	return storageCredential.credential, nil
+}
+
+func storageToken() (string, time.Time, error) {
+	token, err := getToken(storageResource)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
+	return token.Token, token.ExpiresOn, nil
+}
 
-	return *(*keysRes.Keys)[0].Value, nil
+// serviceURL returns an authenticated blob service URL for a storage account, a session builds each one only once
+func (c SDKCloud) serviceURL(storageAcctID string) (*azblob.ServiceURL, error) {
+	if c.session != nil {
+		return c.session.serviceURL(storageAcctID)
+	}
+	return newServiceURL(c, storageAcctID, c.AzureADAuth)
 }
 
-// UploadFileToBlob does what you might expect it to
-func UploadFileToBlob(storageAcctID string, blobContainer string, blobName string, filePath string) error {
+// newServiceURL builds an authenticated blob service URL for a storage account
+// The account key & storage endpoint come from lookups, which is a Session when there is one
+// With azureADAuth the signed in identity's token is used rather than the account key
+func newServiceURL(lookups Cloud, storageAcctID string, azureADAuth bool) (*azblob.ServiceURL, error) {
 	subID, resGrp, accountName, err := ParseResourceID(storageAcctID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	console.Debugf("Using storage account '%s' in res grp '%s' and subscription '%s'\n", accountName, resGrp, subID)
+
+	var credential azblob.Credential
+	if azureADAuth {
+		// Works when shared key access is disabled on the account, the identity needs a Storage Blob Data role
+		credential, err = storageTokenCredential()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		accountKey, err := lookups.GetAccountKey(subID, accountName, resGrp)
+		if err != nil {
+			return nil, err
+		}
+		credential, err = azblob.NewSharedKeyCredential(accountName, accountKey)
+		if err != nil {
+			return nil, err
+		}
 	}
-	console.Debugf("Uploading to storage account '%s' in res grp '%s' and subscription '%s'\n", accountName, resGrp, subID)
-	console.Debugf("Will upload file '%s' to container '%s' to blob '%s'\n", filePath, blobContainer, blobName)
 
-	accountKey, err := GetAccountKey(subID, accountName, resGrp)
+	// Create a default request pipeline using the credential
+	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+
+	sub, err := lookups.GetSubscription()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	cloud, err := CloudForEnvironment(sub.EnvironmentName)
+	if err != nil {
+		return nil, err
 	}
+	accountURL, err := url.Parse(fmt.Sprintf("https://%s.blob.%s", accountName, cloud.StorageEndpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	svcURL := azblob.NewServiceURL(*accountURL, pipeline)
+	return &svcURL, nil
+}
 
-	// Create a default request pipeline using your storage account name and account key.
-	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+// containerURL builds an authenticated URL for a blob container in a storage account
+func (c SDKCloud) containerURL(storageAcctID string, blobContainer string) (*azblob.ContainerURL, error) {
+	svcURL, err := c.serviceURL(storageAcctID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	contURL := svcURL.NewContainerURL(blobContainer)
+	return &contURL, nil
+}
+
+// ErrBlobChanged is returned by a conditional upload when the blob has changed since its ETag was read
+var ErrBlobChanged = errors.New("blob has changed since it was read")
 
-	endpoint, err := StorageEndpointForSubscription()
+// Special ETags for UploadFileToBlobIfMatch
+const (
+	// BlobETagAny replaces the blob whatever its ETag, or creates it
+	BlobETagAny = "*"
+	// BlobETagNone only creates the blob, it must not exist yet
+	BlobETagNone = ""
+)
+
+// UploadFileToBlobIfMatch uploads a file, only replacing the blob if its ETag still matches, returning ErrBlobChanged if not
+// The MD5 of the file is sent with it and checked against the MD5 the service stores
+func (c SDKCloud) UploadFileToBlobIfMatch(storageAcctID string, blobContainer string, blobName string, filePath string, etag string) error {
+	console.Debugf("Will upload file '%s' to container '%s' to blob '%s'\n", filePath, blobContainer, blobName)
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
 	if err != nil {
 		return err
 	}
-	containerURL, _ := url.Parse(
-		fmt.Sprintf("https://%s.blob.%s/%s", accountName, endpoint, blobContainer))
 
-	blobContainerURL := azblob.NewContainerURL(*containerURL, pipeline)
 	blobURL := blobContainerURL.NewBlockBlobURL(blobName)
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	uploadResp, err := azblob.UploadFileToBlockBlob(context.Background(), file, blobURL, azblob.UploadToBlockBlobOptions{})
-	if uploadResp.Response().StatusCode > 201 {
-		return fmt.Errorf("UploadFileToBlob failed with status %d to upload file '%s' to %s/%s", uploadResp.Response().StatusCode, filePath, blobContainer, blobName)
+	hash := md5.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return err
 	}
+	fileMD5 := hash.Sum(nil)
+	_, err = file.Seek(0, io.SeekStart)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-// DownloadFileFromBlob does what you might expect it to
-func DownloadFileFromBlob(storageAcctID string, blobContainer string, blobName string, filePath string) error {
-	subID, resGrp, accountName, err := ParseResourceID(storageAcctID)
+	conditions := azblob.BlobAccessConditions{}
+	switch etag {
+	case BlobETagAny:
+	case BlobETagNone:
+		conditions.ModifiedAccessConditions.IfNoneMatch = azblob.ETagAny
+	default:
+		conditions.ModifiedAccessConditions.IfMatch = azblob.ETag(etag)
+	}
+
+	uploadResp, err := blobURL.Upload(context.Background(), file, azblob.BlobHTTPHeaders{ContentMD5: fileMD5}, azblob.Metadata{}, conditions, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
+		if stgErr, ok := err.(azblob.StorageError); ok && (stgErr.ServiceCode() == azblob.ServiceCodeConditionNotMet || stgErr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists) {
+			return fmt.Errorf("unable to upload %s/%s: %w", blobContainer, blobName, ErrBlobChanged)
+		}
 		return err
 	}
-	console.Debugf("Downloading from storage account '%s' in res grp '%s' and subscription '%s'\n", accountName, resGrp, subID)
+	if uploadResp.Response().StatusCode > 201 {
+		return fmt.Errorf("UploadFileToBlob failed with status %d to upload file '%s' to %s/%s", uploadResp.Response().StatusCode, filePath, blobContainer, blobName)
+	}
+	if blobMD5 := uploadResp.ContentMD5(); len(blobMD5) > 0 && !bytes.Equal(blobMD5, fileMD5) {
+		return fmt.Errorf("MD5 of uploaded blob %s/%s does not match file '%s'", blobContainer, blobName, filePath)
+	}
+	return nil
+}
+
+// DownloadFileFromBlob does what you might expect it to, returning the ETag of the blob for a later conditional upload
+// When the blob has a content MD5 the downloaded file is checked against it
+func (c SDKCloud) DownloadFileFromBlob(storageAcctID string, blobContainer string, blobName string, filePath string) (string, error) {
 	console.Debugf("Will download blob '%s' from container '%s' to file '%s'\n", blobName, blobContainer, filePath)
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
+	if err != nil {
+		return "", err
+	}
 
-	accountKey, err := GetAccountKey(subID, accountName, resGrp)
+	blobURL := blobContainerURL.NewBlockBlobURL(blobName)
+	props, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Create a default request pipeline using your storage account name and account key.
-	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	file, err := os.Create(filePath)
 	if err != nil {
-		return err
+		return "", err
 	}
-	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	defer file.Close()
 
-	endpoint, err := StorageEndpointForSubscription()
+	// Only download the version of the blob the properties were read from, so the MD5 & ETag match the content
+	options := azblob.DownloadFromBlobOptions{
+		AccessConditions: azblob.BlobAccessConditions{ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()}},
+	}
+	err = azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, 0, 0, file, options)
+	if err != nil {
+		return "", err
+	}
+
+	if blobMD5 := props.ContentMD5(); len(blobMD5) > 0 {
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return "", err
+		}
+		hash := md5.New()
+		_, err = io.Copy(hash, file)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(blobMD5, hash.Sum(nil)) {
+			return "", fmt.Errorf("MD5 of downloaded file '%s' does not match blob %s/%s", filePath, blobContainer, blobName)
+		}
+	}
+	return string(props.ETag()), nil
+}
+
+// ListBlobs does what you might expect it to
+func (c SDKCloud) ListBlobs(storageAcctID string, blobContainer string) (blobs []azblob.BlobItemInternal, err error) {
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
+	if err != nil {
+		return nil, err
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		listBlob, err := blobContainerURL.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		marker = listBlob.NextMarker
+		blobs = append(blobs, listBlob.Segment.BlobItems...)
+	}
+
+	return blobs, nil
+}
+
+// CopyBlob copies a blob between containers in the same storage account
+// State files are small, so a download & upload is simpler than an async server side copy
+func (c SDKCloud) CopyBlob(storageAcctID string, srcContainer string, srcBlob string, destContainer string, destBlob string) error {
+	console.Debugf("Copying blob '%s/%s' to '%s/%s'\n", srcContainer, srcBlob, destContainer, destBlob)
+	svcURL, err := c.serviceURL(storageAcctID)
 	if err != nil {
 		return err
 	}
-	containerURL, _ := url.Parse(
-		fmt.Sprintf("https://%s.blob.%s/%s", accountName, endpoint, blobContainer))
 
-	blobContainerURL := azblob.NewContainerURL(*containerURL, pipeline)
-	blobURL := blobContainerURL.NewBlockBlobURL(blobName)
-	file, err := os.Create(filePath)
+	srcURL := svcURL.NewContainerURL(srcContainer).NewBlockBlobURL(srcBlob)
+	props, err := srcURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
 		return err
 	}
+	buf := make([]byte, props.ContentLength())
+	err = azblob.DownloadBlobToBuffer(context.Background(), srcURL.BlobURL, 0, 0, buf, azblob.DownloadFromBlobOptions{})
+	if err != nil {
+		return err
+	}
+
+	destURL := svcURL.NewContainerURL(destContainer).NewBlockBlobURL(destBlob)
+	_, err = azblob.UploadBufferToBlockBlob(context.Background(), buf, destURL, azblob.UploadToBlockBlobOptions{})
+	return err
+}
 
-	err = azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, 0, 0, file, azblob.DownloadFromBlobOptions{})
+// DeleteBlobIfMatch deletes a blob, only while its ETag still matches unless etag is BlobETagAny, returning ErrBlobChanged if not
+// It fails if the blob is leased
+func (c SDKCloud) DeleteBlobIfMatch(storageAcctID string, blobContainer string, blobName string, etag string) error {
+	console.Debugf("Deleting blob '%s' in container '%s'\n", blobName, blobContainer)
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	conditions := azblob.BlobAccessConditions{}
+	if etag != BlobETagAny {
+		conditions.ModifiedAccessConditions.IfMatch = azblob.ETag(etag)
+	}
+	_, err = blobContainerURL.NewBlobURL(blobName).Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, conditions)
+	return blobChangedError(err, blobContainer, blobName)
 }
 
-// ListBlobs does what you might expect it to
-func ListBlobs(storageAcctID string, blobContainer string) (blobs []azblob.BlobItemInternal, err error) {
-	subID, resGrp, accountName, err := ParseResourceID(storageAcctID)
+// ErrBlobNotFound is returned for a missing blob by Clouds other than SDKCloud, such as the fake in azuretest
+var ErrBlobNotFound = errors.New("blob not found")
+
+// IsBlobNotFound is true when err is the storage error for a missing blob
+func IsBlobNotFound(err error) bool {
+	if errors.Is(err, ErrBlobNotFound) {
+		return true
+	}
+	stgErr, ok := err.(azblob.StorageError)
+	return ok && stgErr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+}
+
+// BlobLease holds the lease on a blob along with its metadata, terraform uses both to lock state
+type BlobLease struct {
+	// State is one of available, leased, expired, breaking or broken
+	State string
+	// Duration is infinite or fixed, when leased
+	Duration string
+	Metadata map[string]string
+	// ETag identifies the lock, terraform sets the metadata when it takes the lease so the ETag changes with each lock
+	ETag string
+}
+
+// GetBlobLease returns the lease on a blob, it's nil when the blob doesn't exist
+func (c SDKCloud) GetBlobLease(storageAcctID string, blobContainer string, blobName string) (*BlobLease, error) {
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
 	if err != nil {
 		return nil, err
 	}
 
-	accountKey, err := GetAccountKey(subID, accountName, resGrp)
+	blobURL := blobContainerURL.NewBlobURL(blobName)
+	props, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
+		if IsBlobNotFound(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	// Create a default request pipeline using your storage account name and account key.
-	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	return &BlobLease{
+		State:    string(props.LeaseState()),
+		Duration: string(props.LeaseDuration()),
+		Metadata: props.NewMetadata(),
+		ETag:     string(props.ETag()),
+	}, nil
+}
+
+// BreakBlobLease breaks the lease on a blob immediately, then removes a key from the blob metadata
+// Both only happen while the blob has the ETag, so a lease taken since it was read is left alone
+// Breaking a lease doesn't change the ETag, but another run taking the lease & setting the metadata does
+func (c SDKCloud) BreakBlobLease(storageAcctID string, blobContainer string, blobName string, metadataKey string, etag string) error {
+	console.Debugf("Breaking lease on blob '%s' in container '%s'\n", blobName, blobContainer)
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
+	if err != nil {
+		return err
+	}
+
+	blobURL := blobContainerURL.NewBlobURL(blobName)
+	conditions := azblob.ModifiedAccessConditions{IfMatch: azblob.ETag(etag)}
+	_, err = blobURL.BreakLease(context.Background(), 0, conditions)
 	if err != nil {
-		return nil, err
+		return blobChangedError(err, blobContainer, blobName)
 	}
-	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
 
-	endpoint, err := StorageEndpointForSubscription()
+	props, err := blobURL.GetProperties(context.Background(), azblob.BlobAccessConditions{ModifiedAccessConditions: conditions}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
-		return nil, err
+		return blobChangedError(err, blobContainer, blobName)
+	}
+	metadata := props.NewMetadata()
+	for key := range metadata {
+		if strings.EqualFold(key, metadataKey) {
+			delete(metadata, key)
+		}
+	}
+	_, err = blobURL.SetMetadata(context.Background(), metadata, azblob.BlobAccessConditions{ModifiedAccessConditions: conditions}, azblob.ClientProvidedKeyOptions{})
+	return blobChangedError(err, blobContainer, blobName)
+}
+
+// blobChangedError wraps ErrBlobChanged when a conditional operation failed as the blob has a different ETag
+func blobChangedError(err error, blobContainer string, blobName string) error {
+	if stgErr, ok := err.(azblob.StorageError); ok && stgErr.Response() != nil && stgErr.Response().StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("%s/%s: %w", blobContainer, blobName, ErrBlobChanged)
 	}
-	containerURL, _ := url.Parse(
-		fmt.Sprintf("https://%s.blob.%s/%s", accountName, endpoint, blobContainer))
+	return err
+}
 
-	blobContainerURL := azblob.NewContainerURL(*containerURL, pipeline)
+// ListContainers returns the names of all blob containers in a storage account
+func (c SDKCloud) ListContainers(storageAcctID string) ([]string, error) {
+	svcURL, err := c.serviceURL(storageAcctID)
+	if err != nil {
+		return nil, err
+	}
 
+	names := []string{}
 	for marker := (azblob.Marker{}); marker.NotDone(); {
-		listBlob, err := blobContainerURL.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{})
+		listCont, err := svcURL.ListContainersSegment(context.Background(), marker, azblob.ListContainersSegmentOptions{})
 		if err != nil {
 			return nil, err
 		}
 
-		marker = listBlob.NextMarker
-		blobs = append(blobs, listBlob.Segment.BlobItems...)
+		marker = listCont.NextMarker
+		for _, cont := range listCont.ContainerItems {
+			names = append(names, cont.Name)
+		}
 	}
 
-	return blobs, nil
+	return names, nil
+}
+
+// CreateContainer creates a new private blob container in a storage account
+func (c SDKCloud) CreateContainer(storageAcctID string, blobContainer string) error {
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
+	if err != nil {
+		return err
+	}
+
+	_, err = blobContainerURL.Create(context.Background(), azblob.Metadata{}, azblob.PublicAccessNone)
+	return err
+}
+
+// DeleteContainer deletes a blob container and everything in it
+func (c SDKCloud) DeleteContainer(storageAcctID string, blobContainer string) error {
+	blobContainerURL, err := c.containerURL(storageAcctID, blobContainer)
+	if err != nil {
+		return err
+	}
+
+	_, err = blobContainerURL.Delete(context.Background(), azblob.ContainerAccessConditions{})
+	return err
 }
@@ -59,7 +59,11 @@ func VMInstanceMetadataService() *Metadata {
 
 // GetVMIdentities will get the MI details of an Azure VM, both system assigned and user assigned
 func GetVMIdentities(subID string, resourceGroupName string, vmName string) ([]Identity, error) {
-	client := compute.NewVirtualMachinesClient(subID)
+	baseURI, err := resourceManagerBaseURI()
+	if err != nil {
+		return nil, err
+	}
+	client := compute.NewVirtualMachinesClientWithBaseURI(baseURI, subID)
 	authorizer, err := GetAuthorizer()
 	if err != nil {
 		return nil, err
@@ -121,18 +121,12 @@ func CheckCommand(reqCmdName string) error {
 	return nil
 }
 
+// ValidateDependencies checks the external commands rover uses are installed
+// The Azure CLI isn't needed, it's only one of the ways rover can sign into Azure
 func ValidateDependencies() {
-	azErr := CheckCommand("az")
-	if azErr != nil {
-		console.Errorf("The %s.\nPlease install from https://docs.microsoft.com/en-us/cli/azure/install-azure-cli", azErr.Error())
-	}
-
+	// Not fatal, a pinned version of terraform can be installed into the rover home instead
 	tfErr := CheckCommand("terraform")
 	if tfErr != nil {
-		console.Errorf("The %s.\nPlease install from https://www.terraform.io/downloads.html \n", tfErr.Error())
-	}
-
-	if azErr != nil || tfErr != nil {
-		os.Exit(1)
+		console.Warningf("The %s.\nPlease install from https://www.terraform.io/downloads.html or use 'rover terraform install'\n", tfErr.Error())
 	}
 }
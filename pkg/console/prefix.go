@@ -0,0 +1,67 @@
+package console
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Shared by all PrefixWriters so lines from stacks running at the same time are never mixed together
+var lineLock sync.Mutex
+
+// PrefixWriter writes each line it is given to the underlying writer with a prefix, e.g. "[level1/web] "
+// Partial lines are held back until they are completed or Flush is called
+type PrefixWriter struct {
+	prefix []byte
+	out    io.Writer
+	buf    bytes.Buffer
+	mu     sync.Mutex
+}
+
+// NewPrefixWriter returns a writer which prefixes every line written to out
+func NewPrefixWriter(prefix string, out io.Writer) *PrefixWriter {
+	return &PrefixWriter{
+		prefix: []byte(prefix),
+		out:    out,
+	}
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(pw.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		if err := pw.writeLine(pw.buf.Next(idx + 1)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes out any partial line still held
+func (pw *PrefixWriter) Flush() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.buf.Len() == 0 {
+		return nil
+	}
+	line := append(pw.buf.Next(pw.buf.Len()), '\n')
+	return pw.writeLine(line)
+}
+
+func (pw *PrefixWriter) writeLine(line []byte) error {
+	lineLock.Lock()
+	defer lineLock.Unlock()
+
+	if _, err := pw.out.Write(pw.prefix); err != nil {
+		return err
+	}
+	_, err := pw.out.Write(line)
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build unit
+// +build unit
+
+package console
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Prefix_Writer_Lines(t *testing.T) {
+	out := bytes.Buffer{}
+	pw := NewPrefixWriter("[level1/web] ", &out)
+
+	_, _ = pw.Write([]byte("Plan: 1 to add"))
+	_, _ = pw.Write([]byte(", 0 to change\nApply"))
+	assert.Equal(t, "[level1/web] Plan: 1 to add, 0 to change\n", out.String())
+
+	_ = pw.Flush()
+	assert.Equal(t, "[level1/web] Plan: 1 to add, 0 to change\n[level1/web] Apply\n", out.String())
+}
@@ -2,6 +2,7 @@ package custom
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -154,12 +155,17 @@ func LoadCustomCommandsAndGroups() (commands []landingzone.Action, err error) {
 }
 
 // Execute runs this custom command by running the external executable
-func (a Action) Execute(o *landingzone.Options) error {
+func (a Action) Execute(ctx context.Context, o *landingzone.Options) error {
 	console.Successf("Running custom command: %s %s\n", a.GetName(), o.SourcePath)
 
 	for _, command := range a.Commands {
 		if a.Type == landingzone.GroupCommand {
-			err := actions.ActionMap[command.SubCommand].Execute(o)
+			err := actions.ActionMap[command.SubCommand].Execute(ctx, o)
+
+			// Leave reporting an interrupted or timed out run to the caller
+			if err != nil && landingzone.Stopped() {
+				return err
+			}
 
 			// NOTE: When running across multiple levels/stacks
 			// We will exit early when we hit first error, this could be improved
@@ -4,6 +4,7 @@
 package custom
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -402,7 +403,7 @@ func Test_Execute_CustomCommand(t *testing.T) {
 	validateAction := actions.ActionMap["hello"]
 
 	//assert
-	assert.Equal(t, nil, validateAction.Execute(&optionsList[0]))
+	assert.Equal(t, nil, validateAction.Execute(context.Background(), &optionsList[0]))
 
 	t.Cleanup(func() {
 		removeCommandYamlFromHomeDir(roverHome)
@@ -453,7 +454,7 @@ func Test_Execute_GroupAllCustom(t *testing.T) {
 	validateAction := actions.ActionMap["deploy"]
 
 	//assert
-	err := validateAction.Execute(&optionsList[0])
+	err := validateAction.Execute(context.Background(), &optionsList[0])
 	assert.Equal(t, nil, err)
 
 	t.Cleanup(func() {
@@ -6,7 +6,6 @@ import (
 	"os"
 	"path"
 
-	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/console"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/spf13/cobra"
@@ -14,6 +13,8 @@ import (
 
 type ApplyAction struct {
 	TerraformAction
+	// Force overwrites newer or unrelated remote state when uploading launchpad state
+	Force bool
 }
 
 func NewApplyAction() *ApplyAction {
@@ -29,11 +30,20 @@ func NewApplyAction() *ApplyAction {
 	}
 }
 
-func (a *ApplyAction) Execute(o *Options) error {
-	tf, err := a.prepareTerraformCAF(o)
+func (a *ApplyAction) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("force", false, "Overwrite remote launchpad state even if it is newer or from a different lineage")
+}
+
+func (a *ApplyAction) ParseFlags(cmd *cobra.Command) {
+	a.Force, _ = cmd.Flags().GetBool("force")
+}
+
+func (a *ApplyAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
 	if err != nil {
 		return err
 	}
+	defer a.finishRun(o)
 
 	planFile := path.Join(o.DataDir, fmt.Sprintf("%s.tfplan", o.StateName))
 	stateFile := path.Join(o.DataDir, fmt.Sprintf("%s.tfstate", o.StateName))
@@ -46,32 +56,69 @@ func (a *ApplyAction) Execute(o *Options) error {
 		tfexec.Parallelism(terraformParallelism),
 	}
 
+	// Only the plan which was reviewed is applied, a swapped or stale plan is refused
+	fingerprint, err := o.checkPlanFingerprint(planFile)
+	if err != nil {
+		return err
+	}
+
+	// There's no state to snapshot until the launchpad has been deployed
+	if a.launchPadStorageID != "" {
+		err = o.snapshotState(o.backend(), a.launchPadStorageID)
+		cobra.CheckErr(err)
+	}
+
 	console.StartSpinner()
-	err = tf.Apply(context.Background(), applyOptions...)
+	// Only failing to lock the state is retried, anything later may have come after resources were changed
+	// The saved plan is applied again as it is, it's never replaced with one which wasn't reviewed
+	err = o.retry(ctx, "Apply", lockReason, func() error {
+		return tf.Apply(ctx, applyOptions...)
+	})
 	console.StopSpinner()
-	cobra.CheckErr(err)
+	checkErr(err)
 
 	// Special case for post launchpad deployment
-	newStorageID, err := azure.FindStorageAccount(o.Level, o.CafEnvironment, o.StateSubscription)
+	deployed := o.LaunchPadMode && a.launchPadStorageID == ""
+	if deployed {
+		o.Run.SetLaunchpadDeployed()
+	}
+	var newStorageID string
+	err = o.retry(ctx, "Finding state storage account", graphLagReason(deployed || o.Run.LaunchpadDeployed()), func() error {
+		newStorageID, err = o.backend().Locate(ctx, o)
+		return err
+	})
 	cobra.CheckErr(err)
 	if o.LaunchPadMode && a.launchPadStorageID != newStorageID {
 		console.Info("Detected the launchpad infrastructure has been deployed or updated")
 
 		stateFileName := o.DataDir + "/" + o.StateName + ".tfstate"
-		err := azure.UploadFileToBlob(newStorageID, o.Workspace, o.StateName+".tfstate", stateFileName)
+		console.Infof("Uploading state from launchpad process to %s\n", o.backend().Describe(newStorageID))
+		err := o.uploadState(o.backend(), newStorageID, stateFileName, a.Force)
 		cobra.CheckErr(err)
-		console.Info("Uploading state from launchpad process to Azure storage")
 		os.Remove(stateFileName)
 
 		// Why re-init with remote this straight after?
 		// Otherwise we aren't tracking state at all, state will be uploaded to Azure but we won't use it
-		err = o.runRemoteInit(tf, newStorageID)
-		cobra.CheckErr(err)
+		err = o.runRemoteInit(ctx, tf, newStorageID)
+		checkErr(err)
 	}
 
+	reportPlanOverrides(fingerprint)
 	console.Success("Apply was successful")
 	console.Infof("Removing plan file: %s\n", planFile)
 	_ = os.Remove(planFile)
+	_ = os.Remove(fingerprintPath(planFile))
 
 	return nil
 }
+
+// reportPlanOverrides lists any variable overrides the applied plan was created with
+func reportPlanOverrides(fingerprint *PlanFingerprint) {
+	if len(fingerprint.Overrides) == 0 {
+		return
+	}
+	console.Warning("The applied plan was created with variable overrides:")
+	for _, arg := range fingerprint.Overrides {
+		console.Warningf("  %s\n", arg)
+	}
+}
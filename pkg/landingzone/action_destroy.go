@@ -3,10 +3,8 @@ package landingzone
 import (
 	"context"
 	"fmt"
-	"os"
 	"path"
 
-	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/console"
 	"github.com/aztfmod/rover/pkg/terraform"
 	"github.com/hashicorp/terraform-exec/tfexec"
@@ -30,11 +28,12 @@ func NewDestroyAction() *DestroyAction {
 	}
 }
 
-func (a *DestroyAction) Execute(o *Options) error {
-	tf, err := a.prepareTerraformCAF(o)
+func (a *DestroyAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
 	if err != nil {
 		return err
 	}
+	defer a.finishRun(o)
 
 	if o.DryRun {
 		return nil
@@ -48,6 +47,11 @@ func (a *DestroyAction) Execute(o *Options) error {
 		tfexec.Refresh(false),
 	}
 
+	if a.launchPadStorageID != "" {
+		err = o.snapshotState(o.backend(), a.launchPadStorageID)
+		cobra.CheckErr(err)
+	}
+
 	// We need to do all sorts of extra shenanigans for launchPadMode
 	if o.LaunchPadMode {
 		console.Warning("WARNING! You are destroying the launchpad!")
@@ -60,16 +64,18 @@ func (a *DestroyAction) Execute(o *Options) error {
 		o.cleanUp()
 		o.removeStateConfig()
 
-		// Download the current state
-		err := azure.DownloadFileFromBlob(a.launchPadStorageID, o.Workspace, o.StateName+".tfstate", stateFileName)
+		// Download the current state, and check it's readable before destroying with it
+		_, err := o.backend().Download(o, a.launchPadStorageID, stateFileName)
+		cobra.CheckErr(err)
+		_, err = readStateMeta(stateFileName)
 		cobra.CheckErr(err)
 
 		// Reset back to use local state
 		console.Warning("Resetting state to local, have to re-run init without a backend/remote state")
-		err = o.runLaunchpadInit(tf, true)
-		cobra.CheckErr(err)
+		err = o.runLaunchpadInit(ctx, tf, true)
+		checkErr(err)
 		// This is critical and stops terraform from trying to use remote state
-		_ = os.Remove(o.SourcePath + "/backend.azurerm.tf")
+		o.removeBackendConfig()
 
 		// Tell destroy to use local downloaded state to destroy a launchpad
 		// TODO: This is a deprecated option, the solution is to switch to this
@@ -89,17 +95,21 @@ func (a *DestroyAction) Execute(o *Options) error {
 		// Note. spread operator would not work here, I tried ¯\_(ツ)_/¯
 		destroyOptions = append(destroyOptions, vo)
 	}
+	destroyOptions = append(destroyOptions, o.Overrides.destroyOptions()...)
 
 	console.Warning("Destroy is now running ...")
 	console.StartSpinner()
-	err = tf.Destroy(context.Background(), destroyOptions...)
+	err = o.retry(ctx, "Destroy", transientReason, func() error {
+		return tf.Destroy(ctx, destroyOptions...)
+	})
 	console.StopSpinner()
-	cobra.CheckErr(err)
+	checkErr(err)
 
 	// Remove files
 	o.cleanUp()
-	_ = os.RemoveAll(o.DataDir)
+	o.removeTerraformFiles()
 
+	o.reportOverrides()
 	console.Success("Destroy was successful")
 
 	return nil
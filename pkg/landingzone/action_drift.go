@@ -0,0 +1,130 @@
+package landingzone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/terraform"
+)
+
+type DriftAction struct {
+	TerraformAction
+	// Report holds the drift found in each stack this action was executed against
+	Report DriftReport
+}
+
+func NewDriftAction() *DriftAction {
+	return &DriftAction{
+		Report: DriftReport{Stacks: []StackDrift{}},
+		TerraformAction: TerraformAction{
+			launchPadStorageID: "",
+			ActionBase: ActionBase{
+				Name:        "drift",
+				Type:        BuiltinCommand,
+				Description: "Detect changes made outside of terraform",
+			},
+		},
+	}
+}
+
+func (a *DriftAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
+	if err != nil {
+		return err
+	}
+	defer a.finishRun(o)
+
+	if o.DryRun {
+		return nil
+	}
+
+	if a.launchPadStorageID == "" {
+		return errors.New("no remote state found, the launchpad must be deployed before drift can be detected")
+	}
+	a.runTerraformInit(ctx, o, tf, false)
+
+	if !o.LaunchPadMode {
+		err := o.connectToLaunchPad(a.launchPadStorageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A refresh-only plan never writes state, we also skip locking so running pipelines are not disturbed
+	planFile := path.Join(o.DataDir, fmt.Sprintf("%s.drift.tfplan", o.StateName))
+	defer os.Remove(planFile)
+	planArgs := []string{
+		"plan",
+		"-refresh-only",
+		"-input=false",
+		"-lock=false",
+		"-detailed-exitcode",
+		"-out=" + planFile,
+		"-parallelism=" + strconv.Itoa(terraformParallelism),
+	}
+	varFiles, err := terraform.FindVarFiles(o.ConfigPath)
+	if err != nil {
+		return err
+	}
+	for _, varFile := range varFiles {
+		planArgs = append(planArgs, "-var-file="+varFile)
+	}
+
+	console.StartSpinner()
+	stderr := bytes.Buffer{}
+	exitCode := 0
+	err = o.retry(ctx, "Refresh-only plan", transientReason, func() error {
+		stderr.Reset()
+		exitCode, err = terraform.Run(ctx, tf, o.log.stdout, io.MultiWriter(o.log.stderr, &stderr), planArgs...)
+		if err != nil {
+			return err
+		}
+		// With -detailed-exitcode, 0 is no changes, 2 is changes and anything else is a failure
+		if exitCode != 0 && exitCode != 2 {
+			return fmt.Errorf("refresh-only plan failed for %s: %s", o.StateName, stderr.String())
+		}
+		return nil
+	})
+	console.StopSpinner()
+	if err != nil {
+		return err
+	}
+
+	drifted := []DriftedResource{}
+	if exitCode == 2 {
+		planJSON := bytes.Buffer{}
+		stderr.Reset()
+		exitCode, err = terraform.Run(ctx, tf, &planJSON, &stderr, "show", "-json", planFile)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("unable to read refresh-only plan for %s: %s", o.StateName, stderr.String())
+		}
+		drifted, err = ParseDrift(planJSON.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	a.Report.Stacks = append(a.Report.Stacks, StackDrift{
+		Level:     o.Level,
+		Stack:     o.stackName(),
+		Resources: drifted,
+	})
+
+	if len(drifted) > 0 {
+		a.Report.Drifted = true
+		console.Warningf("Detected drift in %d resource(s) in %s\n", len(drifted), o.StateName)
+	} else {
+		console.Successf("No drift detected in %s\n", o.StateName)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTerraform puts a terraform script first on the path, it records its args and writes the plan & state files asked for
+// It returns the file the args are recorded in, one line per run
+func fakeTerraform(t *testing.T) string {
+	binDir := t.TempDir()
+	argsLog := filepath.Join(binDir, "args.log")
+	script := `#!/bin/sh
+echo "$@" >> '` + argsLog + `'
+case "$1" in
+version)
+  if [ "$2" = "-json" ]; then
+    echo '{"terraform_version":"1.5.7","platform":"linux_amd64","provider_selections":{},"terraform_outdated":false}'
+  else
+    echo 'Terraform v1.5.7'
+  fi ;;
+plan)
+  for arg in "$@"; do
+    case "$arg" in -out=*) echo "plan" > "${arg#-out=}" ;; esac
+  done
+  # Changes are present
+  exit 2 ;;
+apply)
+  for arg in "$@"; do
+    case "$arg" in -state-out=*) echo '{"version":4,"serial":1,"lineage":"a1"}' > "${arg#-state-out=}" ;; esac
+  done ;;
+esac
+`
+	assert.Nil(t, os.WriteFile(filepath.Join(binDir, "terraform"), []byte(script), 0755))
+
+	savedPath := os.Getenv("PATH")
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+savedPath)
+	t.Cleanup(func() { os.Setenv("PATH", savedPath) })
+
+	// Keep the plugin cache and rover config out of the real rover home
+	rover.SetHomeDirectory(t.TempDir())
+	t.Cleanup(func() { rover.SetHomeDirectory("") })
+	return argsLog
+}
+
+// terraformRuns returns the args of each terraform run recorded by fakeTerraform, apart from version checks
+func terraformRuns(t *testing.T, argsLog string) []string {
+	content, err := os.ReadFile(argsLog)
+	assert.Nil(t, err)
+	runs := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if !strings.HasPrefix(line, "version") {
+			runs = append(runs, line)
+		}
+	}
+	return runs
+}
+
+// newExecuteOptions are options for running actions against the cloud, with a tfvars file for the stack
+func newExecuteOptions(t *testing.T, cloud azure.Cloud, level string, stateName string) *Options {
+	o := newTestOptions(cloud, level, stateName)
+	o.SourcePath = t.TempDir()
+	o.ConfigPath = t.TempDir()
+	o.DataDir = t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(o.ConfigPath, stateName+".tfvars"), []byte(`location = "westeurope"`), 0644))
+	return o
+}
+
+func Test_Plan_And_Apply_Execute(t *testing.T) {
+	argsLog := fakeTerraform(t)
+	ctx := context.Background()
+	cloud := newTestCloud()
+	o := newExecuteOptions(t, cloud, "level1", "web")
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "tfstate", "web.tfstate", []byte(`{"version":4,"serial":3,"lineage":"a1"}`)))
+
+	plan := NewPlanAction()
+	assert.Nil(t, plan.Execute(ctx, o))
+	planFile := filepath.Join(o.DataDir, "web.tfplan")
+	assert.FileExists(t, planFile)
+	assert.FileExists(t, fingerprintPath(planFile))
+	assert.True(t, plan.hasChanges)
+	runs := terraformRuns(t, argsLog)
+	if !assert.Len(t, runs, 1) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(runs[0], "plan "))
+	assert.Contains(t, runs[0], "-var-file="+filepath.Join(o.ConfigPath, "web.tfvars"))
+
+	// The plan was made without overrides, so it isn't applied with them
+	overridden := *o
+	overrides, err := ParseVarOverrides([]string{"location=northeurope"}, nil)
+	assert.Nil(t, err)
+	overridden.Overrides = overrides
+	assert.Error(t, NewApplyAction().Execute(ctx, &overridden))
+	assert.Len(t, terraformRuns(t, argsLog), 1)
+
+	// The reviewed plan is applied, after the state is snapshotted
+	assert.Nil(t, NewApplyAction().Execute(ctx, o))
+	runs = terraformRuns(t, argsLog)
+	if !assert.Len(t, runs, 2) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(runs[1], "apply "))
+	assert.True(t, strings.HasSuffix(runs[1], planFile))
+	assert.NoFileExists(t, planFile)
+	assert.NoFileExists(t, fingerprintPath(planFile))
+	snapshots, err := azurermBackend{}.Snapshots(o, testStorageL1)
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+
+	// Without a new plan there's nothing to apply
+	assert.Error(t, NewApplyAction().Execute(ctx, o))
+	assert.Len(t, terraformRuns(t, argsLog), 2)
+}
+
+func Test_Destroy_Launchpad_Execute(t *testing.T) {
+	argsLog := fakeTerraform(t)
+	cloud := newTestCloud()
+	cloud.AddOwner("object-1", testSubID)
+	assert.Nil(t, cloud.PutBlob(testStorageL0, "tfstate", "launchpad.tfstate", []byte(`{"version":4,"serial":7,"lineage":"a1"}`)))
+	o := newExecuteOptions(t, cloud, "level0", "launchpad")
+	o.LaunchPadMode = true
+
+	assert.Nil(t, NewDestroyAction().Execute(context.Background(), o))
+
+	// The launchpad is destroyed using its state downloaded from the launchpad, terraform can't reach remote state
+	runs := terraformRuns(t, argsLog)
+	if !assert.Len(t, runs, 2) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(runs[0], "init "))
+	assert.True(t, strings.HasPrefix(runs[1], "destroy "))
+	assert.Contains(t, runs[1], "-state="+filepath.Join(o.DataDir, "launchpad.tfstate"))
+	assert.NoFileExists(t, filepath.Join(o.SourcePath, "backend.azurerm.tf"))
+	snapshots, err := azurermBackend{}.Snapshots(o, testStorageL0)
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+
+	// Terraform's working files are removed, the destroy log and run metadata are kept for auditing
+	assert.NoFileExists(t, filepath.Join(o.DataDir, "launchpad.tfstate"))
+	assert.True(t, strings.HasPrefix(o.RunMetadata.LogFile, filepath.Join(o.DataDir, logDirName, "destroy-")))
+	assert.FileExists(t, o.RunMetadata.LogFile)
+	assert.FileExists(t, filepath.Join(o.DataDir, "launchpad.run.json"))
+}
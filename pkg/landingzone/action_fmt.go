@@ -1,19 +1,29 @@
 package landingzone
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 
 	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/terraform"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/spf13/cobra"
 )
 
 type FormatAction struct {
 	TerraformAction
+	// Write rewrites files which need formatting, rather than only reporting them
+	Write bool
+	// Diff shows the formatting changes needed in each file
+	Diff bool
+	// Stacks often share the same landingzone source, so each directory is only formatted once
+	formatted map[string]bool
 }
 
 func NewFormatAction() *FormatAction {
 	return &FormatAction{
+		formatted: map[string]bool{},
 		TerraformAction: TerraformAction{
 			launchPadStorageID: "",
 			ActionBase: ActionBase{
@@ -25,35 +35,108 @@ func NewFormatAction() *FormatAction {
 	}
 }
 
-func (a *FormatAction) Execute(o *Options) error {
-	tf, err := a.prepareTerraformCAF(o)
+func (a *FormatAction) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("write", false, "Rewrite files which need formatting")
+	cmd.Flags().Bool("diff", false, "Show the formatting changes needed in each file")
+}
+
+func (a *FormatAction) ParseFlags(cmd *cobra.Command) {
+	a.Write, _ = cmd.Flags().GetBool("write")
+	a.Diff, _ = cmd.Flags().GetBool("diff")
+}
+
+// Execute checks the formatting of the landingzone source and the tfvars in the config directory
+// Formatting is purely local, so unlike other actions no Azure login is needed
+func (a *FormatAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformLocal(o)
 	if err != nil {
 		return err
 	}
 
 	if o.DryRun {
+		console.Infof("Would check formatting of %s and %s\n", o.SourcePath, o.ConfigPath)
 		return nil
 	}
 
-	fo := []tfexec.FormatOption{
-		tfexec.Dir(o.SourcePath),
-		tfexec.Recursive(true),
-	}
+	filesToFix := []string{}
+	for _, dir := range []struct {
+		path      string
+		recursive bool
+	}{
+		{o.SourcePath, true},
+		{o.ConfigPath, false},
+	} {
+		if dir.path == "" || a.formatted[dir.path] {
+			continue
+		}
+		a.formatted[dir.path] = true
 
-	outcome, filesToFix, err := tf.FormatCheck(context.Background(), fo...)
-	cobra.CheckErr(err)
+		files, err := a.formatDir(ctx, tf, dir.path, dir.recursive)
+		if err != nil {
+			return err
+		}
+		filesToFix = append(filesToFix, files...)
+	}
 
-	// TODO: return something (exit code?) so that pipeline can react appropriately
-	if outcome {
+	if len(filesToFix) == 0 {
 		console.Success("No formatting is necessary.")
-	} else {
-		console.Error("The following file(s) require formatting:")
+		return nil
+	}
+
+	if a.Write {
+		console.Success("The following file(s) were formatted:")
 		for _, filename := range filesToFix {
-			console.Errorf("  %s\n", filename)
+			console.Successf("  %s\n", filename)
 		}
-		cobra.CheckErr("Format detected issues")
+		return nil
+	}
+
+	console.Error("The following file(s) require formatting:")
+	for _, filename := range filesToFix {
+		console.Errorf("  %s\n", filename)
 	}
+	cobra.CheckErr("Format detected issues, run rover fmt --write to fix them")
 
-	console.Success("Format completed")
 	return nil
 }
+
+// formatDir checks a directory, returning the files which need formatting
+// The diff is shown and the files rewritten when requested
+func (a *FormatAction) formatDir(ctx context.Context, tf *tfexec.Terraform, dir string, recursive bool) ([]string, error) {
+	console.Infof("Checking formatting of %s\n", dir)
+	fo := []tfexec.FormatOption{
+		tfexec.Dir(dir),
+		tfexec.Recursive(recursive),
+	}
+
+	outcome, filesToFix, err := tf.FormatCheck(ctx, fo...)
+	checkErr(err)
+	if outcome {
+		return nil, nil
+	}
+
+	if a.Diff {
+		args := []string{"fmt", "-check", "-diff", "-list=false", "-no-color"}
+		if recursive {
+			args = append(args, "-recursive")
+		}
+		args = append(args, dir)
+
+		// With -check the exit code is non-zero when there are changes, so only stderr is used to detect a failure
+		stderr := bytes.Buffer{}
+		_, err := terraform.Run(ctx, tf, console.Output, &stderr, args...)
+		if err != nil {
+			return nil, err
+		}
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("unable to show formatting changes for %s: %s", dir, stderr.String())
+		}
+	}
+
+	if a.Write {
+		err = tf.FormatWrite(ctx, fo...)
+		checkErr(err)
+	}
+
+	return filesToFix, nil
+}
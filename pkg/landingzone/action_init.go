@@ -1,5 +1,7 @@
 package landingzone
 
+import "context"
+
 type InitAction struct {
 	TerraformAction
 }
@@ -17,16 +19,17 @@ func NewInitAction() *InitAction {
 	}
 }
 
-func (a *InitAction) Execute(o *Options) error {
-	tf, err := a.prepareTerraformCAF(o)
+func (a *InitAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
 	if err != nil {
 		return err
 	}
+	defer a.finishRun(o)
 
 	if o.DryRun {
 		return nil
 	}
 
-	a.runTerraformInit(o, tf, false)
+	a.runTerraformInit(ctx, o, tf, false)
 	return nil
 }
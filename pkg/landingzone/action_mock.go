@@ -1,12 +1,16 @@
 package landingzone
 
-import "github.com/aztfmod/rover/pkg/console"
+import (
+	"context"
+
+	"github.com/aztfmod/rover/pkg/console"
+)
 
 type MockAction struct {
 	ActionBase
 }
 
-func (ma MockAction) Execute(o *Options) error {
+func (ma MockAction) Execute(ctx context.Context, o *Options) error {
 
 	console.Infof("Environment is: %s\n", o.CafEnvironment)
 	console.Infof("Config path is: %s\n", o.ConfigPath)
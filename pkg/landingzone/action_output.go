@@ -0,0 +1,89 @@
+package landingzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+type OutputAction struct {
+	TerraformAction
+	// OutputName limits results to a single output, empty means all outputs
+	OutputName string
+	// Results holds the outputs gathered from each stack this action was executed against
+	Results []StackOutputs
+}
+
+// StackOutputs are the outputs read from the remote state of a single level & stack
+type StackOutputs struct {
+	Level   string
+	Stack   string
+	Outputs map[string]tfexec.OutputMeta
+}
+
+func NewOutputAction(outputName string) *OutputAction {
+	return &OutputAction{
+		OutputName: outputName,
+		TerraformAction: TerraformAction{
+			launchPadStorageID: "",
+			ActionBase: ActionBase{
+				Name:        "output",
+				Type:        BuiltinCommand,
+				Description: "Read outputs from landingzone remote state",
+			},
+		},
+	}
+}
+
+func (a *OutputAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
+	if err != nil {
+		return err
+	}
+	defer a.finishRun(o)
+
+	if o.DryRun {
+		return nil
+	}
+
+	// Outputs only exist once state has been moved to the launchpad, so local state is no use here
+	if a.launchPadStorageID == "" {
+		return errors.New("no remote state found, the launchpad must be deployed before outputs can be read")
+	}
+	a.runTerraformInit(ctx, o, tf, false)
+
+	// Output values may be sensitive, so they are kept out of the log file and live output
+	tf.SetStdout(io.Discard)
+
+	console.StartSpinner()
+	var outputs map[string]tfexec.OutputMeta
+	err = o.retry(ctx, "Reading outputs", transientReason, func() error {
+		outputs, err = tf.Output(ctx)
+		return err
+	})
+	console.StopSpinner()
+	if err != nil {
+		return err
+	}
+
+	if a.OutputName != "" {
+		output, ok := outputs[a.OutputName]
+		if !ok {
+			return fmt.Errorf("output '%s' not found in state for level '%s' stack '%s'", a.OutputName, o.Level, o.StateName)
+		}
+		outputs = map[string]tfexec.OutputMeta{a.OutputName: output}
+	}
+
+	a.Results = append(a.Results, StackOutputs{
+		Level:   o.Level,
+		Stack:   o.stackName(),
+		Outputs: outputs,
+	})
+
+	console.Successf("Read %d output(s) from %s\n", len(outputs), o.StateName)
+	return nil
+}
@@ -0,0 +1,140 @@
+package landingzone
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/terraform"
+)
+
+// These terraform commands accept -var-file, so the tfvars from the config directory and any overrides are added to them
+var varFileCommands = map[string]bool{
+	"plan":    true,
+	"apply":   true,
+	"destroy": true,
+	"refresh": true,
+	"import":  true,
+	"console": true,
+}
+
+// These terraform commands change state, so it's snapshotted before they run
+var stateChangeCommands = map[string]bool{
+	"apply":   true,
+	"destroy": true,
+	"refresh": true,
+	"import":  true,
+	"taint":   true,
+	"untaint": true,
+}
+
+// State subcommands which change state, the rest only read it
+var stateChangeSubcommands = map[string]bool{
+	"mv":               true,
+	"rm":               true,
+	"push":             true,
+	"replace-provider": true,
+}
+
+type PassthroughAction struct {
+	TerraformAction
+	// Args are passed to terraform, starting with the subcommand
+	Args []string
+	// ExitCode is the exit code of the last terraform run, it's non-zero when terraform failed
+	ExitCode int
+}
+
+func NewPassthroughAction(args []string) *PassthroughAction {
+	return &PassthroughAction{
+		Args: args,
+		TerraformAction: TerraformAction{
+			launchPadStorageID: "",
+			ActionBase: ActionBase{
+				Name:        "tf",
+				Type:        BuiltinCommand,
+				Description: "Run any terraform command in the landingzone",
+			},
+		},
+	}
+}
+
+func (a *PassthroughAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
+	if err != nil {
+		return err
+	}
+	defer a.finishRun(o)
+
+	varArgs := []string{}
+	if varFileCommands[a.Args[0]] {
+		varFiles, err := terraform.FindVarFiles(o.ConfigPath)
+		if err != nil {
+			return err
+		}
+		for _, varFile := range varFiles {
+			varArgs = append(varArgs, "-var-file="+varFile)
+		}
+		varArgs = append(varArgs, o.Overrides.Args()...)
+	} else if !o.Overrides.IsEmpty() {
+		console.Warningf("Variable overrides are ignored, terraform %s does not accept them\n", a.Args[0])
+	}
+	args := passthroughArgs(a.Args, varArgs)
+
+	if o.DryRun {
+		console.Infof("Would run: terraform %s\n", strings.Join(args, " "))
+		return nil
+	}
+
+	a.runTerraformInit(ctx, o, tf, false)
+
+	// Connect to launchpad, setting all the vars needed by the landingzone
+	if !o.LaunchPadMode {
+		err := o.connectToLaunchPad(a.launchPadStorageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.launchPadStorageID != "" && changesState(a.Args) {
+		err = o.snapshotState(o.backend(), a.launchPadStorageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	console.Infof("Running terraform %s for %s\n", a.Args[0], o.StateName)
+	a.ExitCode, err = terraform.Passthrough(ctx, tf, args...)
+	if err != nil {
+		return err
+	}
+	o.reportOverrides()
+	if a.ExitCode != 0 {
+		console.Errorf("Terraform %s exited with code %d\n", a.Args[0], a.ExitCode)
+	}
+	return nil
+}
+
+// passthroughArgs adds the -var-file & -var args to the terraform args, directly after the subcommand
+// Vars can't be used when applying a saved plan, so they are left off if apply is given a plan file
+func passthroughArgs(args []string, varArgs []string) []string {
+	if args[0] == "apply" {
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				varArgs = nil
+				break
+			}
+		}
+	}
+
+	tfArgs := []string{args[0]}
+	tfArgs = append(tfArgs, varArgs...)
+	return append(tfArgs, args[1:]...)
+}
+
+// changesState is true when the terraform args run a command which writes state
+func changesState(args []string) bool {
+	if args[0] == "state" {
+		return len(args) > 1 && stateChangeSubcommands[args[1]]
+	}
+	return stateChangeCommands[args[0]]
+}
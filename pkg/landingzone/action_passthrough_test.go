@@ -0,0 +1,38 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Passthrough_Args(t *testing.T) {
+	varArgs := []string{"-var-file=/config/a.tfvars", "-var-file=/config/b.tfvars"}
+
+	args := passthroughArgs([]string{"console"}, varArgs)
+	assert.Equal(t, []string{"console", "-var-file=/config/a.tfvars", "-var-file=/config/b.tfvars"}, args)
+
+	args = passthroughArgs([]string{"import", "-allow-missing-config", "azurerm_resource_group.rg", "/subscriptions/123"}, varArgs[:1])
+	assert.Equal(t, []string{"import", "-var-file=/config/a.tfvars", "-allow-missing-config", "azurerm_resource_group.rg", "/subscriptions/123"}, args)
+
+	// A saved plan can't be applied with var files
+	args = passthroughArgs([]string{"apply", "-auto-approve", "web.tfplan"}, varArgs)
+	assert.Equal(t, []string{"apply", "-auto-approve", "web.tfplan"}, args)
+
+	args = passthroughArgs([]string{"apply", "-auto-approve"}, varArgs[:1])
+	assert.Equal(t, []string{"apply", "-var-file=/config/a.tfvars", "-auto-approve"}, args)
+
+	args = passthroughArgs([]string{"graph"}, nil)
+	assert.Equal(t, []string{"graph"}, args)
+}
+
+func Test_Changes_State(t *testing.T) {
+	assert.True(t, changesState([]string{"import", "azurerm_resource_group.rg", "/subscriptions/123"}))
+	assert.True(t, changesState([]string{"state", "rm", "azurerm_resource_group.rg"}))
+	assert.False(t, changesState([]string{"state", "list"}))
+	assert.False(t, changesState([]string{"state"}))
+	assert.False(t, changesState([]string{"console"}))
+}
@@ -30,11 +30,12 @@ func NewPlanAction() *PlanAction {
 	}
 }
 
-func (a *PlanAction) Execute(o *Options) error {
-	tf, err := a.prepareTerraformCAF(o)
+func (a *PlanAction) Execute(ctx context.Context, o *Options) error {
+	tf, err := a.prepareTerraformCAF(ctx, o)
 	if err != nil {
 		return err
 	}
+	defer a.finishRun(o)
 
 	if o.DryRun {
 		return nil
@@ -46,8 +47,32 @@ func (a *PlanAction) Execute(o *Options) error {
 		cobra.CheckErr(err)
 	}
 
-	// Build plan options starting with tfplan output
 	planFile := path.Join(o.DataDir, fmt.Sprintf("%s.tfplan", o.StateName))
+	planOptions, err := o.planOptions(planFile)
+	cobra.CheckErr(err)
+
+	console.StartSpinner()
+	err = o.retry(ctx, "Plan", transientReason, func() error {
+		a.hasChanges, err = tf.Plan(ctx, planOptions...)
+		return err
+	})
+	console.StopSpinner()
+	checkErr(err)
+	err = o.writePlanFingerprint(planFile)
+	cobra.CheckErr(err)
+
+	o.reportOverrides()
+	if a.hasChanges {
+		console.Successf("Plan %s contains infrastructure updates\n", planFile)
+	} else {
+		console.Successf("Plan %s detected no changes\n", planFile)
+	}
+	return nil
+}
+
+// planOptions builds the options used to plan a landingzone, saving the plan to planFile
+func (o *Options) planOptions(planFile string) ([]tfexec.PlanOption, error) {
+	// Build plan options starting with tfplan output
 	planOptions := []tfexec.PlanOption{
 		tfexec.Out(planFile),
 		tfexec.Refresh(true),
@@ -56,20 +81,15 @@ func (a *PlanAction) Execute(o *Options) error {
 
 	// Then merge all tfvars found in config directory into -var-file options
 	varOpts, err := terraform.ExpandVarDirectory(o.ConfigPath)
-	cobra.CheckErr(err)
+	if err != nil {
+		return nil, err
+	}
 	for _, vo := range varOpts {
 		// Note. spread operator would not work here, I tried ¯\_(ツ)_/¯
 		planOptions = append(planOptions, vo)
 	}
 
-	console.StartSpinner()
-	a.hasChanges, err = tf.Plan(context.Background(), planOptions...)
-	console.StopSpinner()
-	cobra.CheckErr(err)
-	if a.hasChanges {
-		console.Successf("Plan %s contains infrastructure updates\n", planFile)
-	} else {
-		console.Successf("Plan %s detected no changes\n", planFile)
-	}
-	return nil
+	// Overrides from the command line come last, so they take precedence over the config directory
+	planOptions = append(planOptions, o.Overrides.planOptions()...)
+	return planOptions, nil
 }
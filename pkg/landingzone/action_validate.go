@@ -2,17 +2,31 @@ package landingzone
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/aztfmod/rover/pkg/console"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/spf13/cobra"
 )
 
 type ValidateAction struct {
 	TerraformAction
+	// DiagnosticsOutput is set from the --format & --out flags, formats other than text are written by Summarize
+	DiagnosticsOutput
+	// Report holds the diagnostics from every stack validated
+	Report ValidateReport
+	// Stacks often share the same landingzone source, so each is only validated once
+	validated map[string]bool
 }
 
 func NewValidateAction() *ValidateAction {
 	return &ValidateAction{
+		DiagnosticsOutput: DiagnosticsOutput{Format: ValidateFormatText},
+		Report:            ValidateReport{Valid: true, Diagnostics: []Diagnostic{}},
+		validated:         map[string]bool{},
 		TerraformAction: TerraformAction{
 			launchPadStorageID: "",
 			ActionBase: ActionBase{
@@ -24,10 +38,18 @@ func NewValidateAction() *ValidateAction {
 	}
 }
 
-func (a *ValidateAction) Execute(o *Options) error {
-	console.Info("Carrying out Terraform validate")
+func (a *ValidateAction) AddFlags(cmd *cobra.Command) {
+	AddDiagnosticsFlags(cmd)
+}
+
+func (a *ValidateAction) ParseFlags(cmd *cobra.Command) {
+	a.DiagnosticsOutput = ParseDiagnosticsFlags(cmd)
+}
 
-	tf, err := a.prepareTerraformCAF(o)
+// Execute validates the landingzone source, it only needs a backend-less init so no Azure login is needed
+func (a *ValidateAction) Execute(ctx context.Context, o *Options) error {
+	console.Info("Carrying out Terraform validate")
+	tf, err := a.prepareTerraformLocal(o)
 	if err != nil {
 		return err
 	}
@@ -35,26 +57,91 @@ func (a *ValidateAction) Execute(o *Options) error {
 	if o.DryRun {
 		return nil
 	}
+	if a.validated[o.SourcePath] {
+		console.Infof("Source %s has already been validated\n", o.SourcePath)
+		return nil
+	}
+	a.validated[o.SourcePath] = true
 
 	console.StartSpinner()
-	out, err := tf.Validate(context.Background())
-	cobra.CheckErr(err)
+	err = o.retry(ctx, "Init", transientReason, func() error {
+		return tf.Init(ctx, tfexec.Backend(false))
+	})
+	checkErr(err)
+	out, err := tf.Validate(ctx)
+	checkErr(err)
 	console.StopSpinner()
 
+	diagnostics := o.diagnostics(out.Diagnostics)
+	a.Report.Diagnostics = append(a.Report.Diagnostics, diagnostics...)
+	a.Report.ErrorCount += out.ErrorCount
+	a.Report.WarningCount += out.WarningCount
 	if !out.Valid {
-		console.Errorf("Valdate returned %d warnings\n", out.WarningCount)
-		console.Errorf("Valdate returned %d errors\n", out.ErrorCount)
-		for _, d := range out.Diagnostics {
-			console.Error("---------------")
-			console.Errorf("Severity: %s\n", d.Severity)
-			console.Errorf("Detail: %s\n", d.Detail)
-			console.Errorf("Summary: %s\n", d.Summary)
-			console.Errorf("Filename: %s\n", d.Range.Filename)
-			console.Errorf("Line: %d\n", d.Range.Start.Line)
+		a.Report.Valid = false
+	}
+
+	// Other formats are written once all stacks have been validated, by Summarize
+	if a.Format == ValidateFormatText {
+		err = WriteDiagnostics(console.Output, ValidateReport{Diagnostics: diagnostics}, a.Format)
+		cobra.CheckErr(err)
+		if !out.Valid {
+			console.Errorf("Validate returned %d errors and %d warnings\n", out.ErrorCount, out.WarningCount)
+			cobra.CheckErr("Validate detected issues")
 		}
-		cobra.CheckErr("Validate detected issues")
 	}
 
 	console.Success("Validate was successful")
 	return nil
 }
+
+// Summarize writes the diagnostics from all stacks, failing if any were errors
+func (a *ValidateAction) Summarize() error {
+	if a.Format == ValidateFormatText {
+		return nil
+	}
+
+	err := a.DiagnosticsOutput.Write(a.Report)
+	if err != nil {
+		return err
+	}
+
+	if !a.Report.Valid {
+		return fmt.Errorf("validate returned %d errors and %d warnings", a.Report.ErrorCount, a.Report.WarningCount)
+	}
+	return nil
+}
+
+// diagnostics converts terraform diagnostics, making file names relative to where rover was run from
+func (o *Options) diagnostics(tfDiagnostics []tfjson.Diagnostic) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, d := range tfDiagnostics {
+		diag := Diagnostic{
+			Level:    o.Level,
+			Stack:    o.stackName(),
+			Severity: string(d.Severity),
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+		if d.Range != nil {
+			diag.Filename = repoPath(filepath.Join(o.SourcePath, d.Range.Filename))
+			diag.Line = d.Range.Start.Line
+			diag.Column = d.Range.Start.Column
+			diag.EndLine = d.Range.End.Line
+			diag.EndColumn = d.Range.End.Column
+		}
+		diagnostics = append(diagnostics, diag)
+	}
+	return diagnostics
+}
+
+// repoPath makes a file name relative to the directory rover was run from, normally the root of the repo
+// so diagnostics are shown against the right file by CI systems
+func repoPath(filename string) string {
+	cwd, err := os.Getwd()
+	if err == nil {
+		if rel, err := filepath.Rel(cwd, filename); err == nil {
+			filename = rel
+		}
+	}
+	return filepath.ToSlash(filename)
+}
@@ -2,13 +2,13 @@ package landingzone
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path"
 	"strings"
 
-	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/command"
 	"github.com/aztfmod/rover/pkg/console"
 	"github.com/spf13/cobra"
@@ -31,13 +31,18 @@ func NewTestAction() *TestAction {
 	}
 }
 
-func (ta *TestAction) Execute(o *Options) error {
+func (ta *TestAction) Execute(ctx context.Context, o *Options) error {
 	console.Info("Carrying out Rover Test")
 
+	_, err := o.loadBackend()
+	if err != nil {
+		return err
+	}
+
 	// Locate state storage account id
-	if o.StateSubscription == "" {
+	if o.StateSubscription == "" && o.backend().NeedsAzure() {
 		console.Infof("No State sub provided, trying to locate default sub")
-		sub, err := azure.GetSubscription()
+		sub, err := o.cloud().GetSubscription()
 
 		if err != nil {
 			return errors.New("can't locate state sub")
@@ -45,18 +50,18 @@ func (ta *TestAction) Execute(o *Options) error {
 		o.StateSubscription = sub.ID
 	}
 
-	storageID, err := azure.FindStorageAccount(o.Level, o.CafEnvironment, o.StateSubscription)
+	storageID, err := o.backend().Locate(ctx, o)
 	if err != nil {
 
 		console.Errorf("No state storage account found for environment '%s' and level %s", o.CafEnvironment, o.Level)
 		return errors.New("can't test a landing zone without a state file storage account")
 	}
 
-	console.Infof("Located state storage account %s\n", storageID)
+	console.Infof("Located state in %s\n", o.backend().Describe(storageID))
 
 	// download tfstate file
 	stateFilePath := path.Join(o.DataDir, "terraform.tfstate")
-	err = azure.DownloadFileFromBlob(storageID, o.Workspace, o.StateName+".tfstate", stateFilePath)
+	_, err = o.backend().Download(o, storageID, stateFilePath)
 	cobra.CheckErr(err)
 
 	// Execute go test
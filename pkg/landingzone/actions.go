@@ -1,12 +1,29 @@
 package landingzone
 
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
 type Action interface {
-	Execute(o *Options) error
+	Execute(ctx context.Context, o *Options) error
 	GetType() string
 	GetName() string
 	GetDescription() string
 }
 
+// ActionWithFlags is implemented by actions which have flags of their own, as well as the common action flags
+type ActionWithFlags interface {
+	AddFlags(cmd *cobra.Command)
+	ParseFlags(cmd *cobra.Command)
+}
+
+// ActionWithSummary is implemented by actions which report on all the stacks, once the action has run against them
+type ActionWithSummary interface {
+	Summarize() error
+}
+
 const (
 	BuiltinCommand = "Builtin"
 	CustomCommand  = "Custom"
@@ -0,0 +1,179 @@
+//
+// Rover - State backends
+// * Where terraform state for each level is held, and how landingzones find the state of the level below
+// * azurerm keeps state in the launchpad storage account, local keeps it on disk for sandboxes & tests
+//
+
+package landingzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+const (
+	BackendAzureRM = "azurerm"
+	BackendLocal   = "local"
+)
+
+// StateBackends lists the names of all the supported backends
+var StateBackends = []string{BackendAzureRM, BackendLocal}
+
+// StateBackend holds the terraform state for landingzones
+// A location is where state for a level is held, for azurerm it's the resource id of the launchpad storage account
+type StateBackend interface {
+	// Name is used to choose the backend in the symphony config and with --state-backend
+	Name() string
+	// NeedsAzure is true when the backend can't be used without an Azure login
+	NeedsAzure() bool
+	// Locate finds where state for the level is held, returning an error if it can't be found
+	Locate(ctx context.Context, o *Options) (string, error)
+	// Configure enables the backend in the landingzone source and returns the options for terraform init
+	Configure(o *Options, location string) ([]tfexec.InitOption, error)
+	// Connect sets the TF_VAR_ variables a landingzone uses to find its own state and the state of the level below
+	Connect(o *Options, location string) error
+	// Upload copies a local state file into the backend, as the state for the stack
+	// It only replaces state still at the version given, see stateVersionAny & stateVersionNone, returning errStateChanged if not
+	Upload(o *Options, location string, stateFile string, version string) error
+	// Download copies the state for the stack from the backend to a local file, returning the version downloaded
+	Download(o *Options, location string, stateFile string) (string, error)
+	// Delete removes the state for the stack, leaving its snapshots
+	// It only removes state still at the version given, or whatever its version with stateVersionAny, returning errStateChanged if not
+	Delete(o *Options, location string, version string) error
+	// LockInfo returns the lock held on the state for the stack, nil when it isn't locked
+	LockInfo(o *Options, location string) (*StateLock, error)
+	// Unlock forcibly releases a lock read with LockInfo, failing if the state has been locked again since
+	Unlock(o *Options, location string, lock *StateLock) error
+	// SaveSnapshot copies the state for the stack to a snapshot, returning false when there is no state yet
+	SaveSnapshot(o *Options, location string, id string) (bool, error)
+	// Snapshots lists the snapshots of the state for the stack, oldest first
+	Snapshots(o *Options, location string) ([]StateSnapshot, error)
+	// DownloadSnapshot copies a snapshot from the backend to a local file
+	DownloadSnapshot(o *Options, location string, id string, stateFile string) error
+	// DeleteSnapshot removes a snapshot
+	DeleteSnapshot(o *Options, location string, id string) error
+	// Describe names a location in messages
+	Describe(location string) string
+}
+
+// Versions for StateBackend.Upload & Delete, any other version is one returned by Download
+const (
+	// stateVersionAny replaces the state whatever its version
+	stateVersionAny = azure.BlobETagAny
+	// stateVersionNone only creates state, there must be none for the stack yet
+	stateVersionNone = azure.BlobETagNone
+)
+
+// errStateChanged is returned by StateBackend.Upload & Delete when the state is no longer at the expected version
+var errStateChanged = errors.New("state has changed since it was read")
+
+// StateBackendConfig chooses the backend, it's set in the symphony config or with the --state-backend & --state-dir flags
+type StateBackendConfig struct {
+	// Type is one of StateBackends, azurerm when empty
+	Type string `yaml:"type,omitempty"`
+	// Path is the root directory for the local backend
+	Path string `yaml:"path,omitempty"`
+	// AzureADAuth uses the signed in identity rather than storage account keys with the azurerm backend
+	// When not set the azureADAuth setting in the rover config file is used
+	AzureADAuth *bool `yaml:"azureADAuth,omitempty"`
+	// LaunchpadTags are tags the azurerm launchpad storage account & key vault must have, on top of the level & environment
+	// They pick out one launchpad when several share a subscription, e.g. caf_tfstate: level1
+	LaunchpadTags map[string]string `yaml:"launchpadTags,omitempty"`
+	// StorageAccountID & KeyVaultID name the azurerm launchpad resources, rather than finding them by their tags
+	// They are only set with flags, as they apply to a single level
+	StorageAccountID string `yaml:"-"`
+	KeyVaultID       string `yaml:"-"`
+}
+
+// UsesAzureADAuth returns the AzureADAuth setting, falling back to the rover config file
+func (conf StateBackendConfig) UsesAzureADAuth() bool {
+	if conf.AzureADAuth != nil {
+		return *conf.AzureADAuth
+	}
+	roverConfig, err := rover.LoadConfig()
+	if err != nil {
+		return false
+	}
+	return roverConfig.AzureADAuth
+}
+
+// NewStateBackend returns the backend described by the config
+func NewStateBackend(conf StateBackendConfig) (StateBackend, error) {
+	switch conf.Type {
+	case "", BackendAzureRM:
+		if conf.Path != "" {
+			return nil, fmt.Errorf("a state path can only be used with the %s state backend", BackendLocal)
+		}
+		return newAzurermBackend(conf), nil
+	case BackendLocal:
+		if conf.AzureADAuth != nil && *conf.AzureADAuth {
+			return nil, fmt.Errorf("Azure AD auth can only be used with the %s state backend", BackendAzureRM)
+		}
+		if len(conf.LaunchpadTags) > 0 || conf.StorageAccountID != "" || conf.KeyVaultID != "" {
+			return nil, fmt.Errorf("launchpad tags and resource IDs can only be used with the %s state backend", BackendAzureRM)
+		}
+		return newLocalBackend(conf.Path)
+	}
+	return nil, fmt.Errorf("unsupported state backend '%s', must be one of: %s", conf.Type, strings.Join(StateBackends, ", "))
+}
+
+// SetStateBackend checks the backend config, making the local path absolute
+func (o *Options) SetStateBackend(conf StateBackendConfig) error {
+	if conf.Path != "" {
+		statePath, err := filepath.Abs(conf.Path)
+		if err != nil {
+			return err
+		}
+		conf.Path = statePath
+	}
+	backend, err := NewStateBackend(conf)
+	if err != nil {
+		return err
+	}
+	o.StateBackend = conf
+	o.stateBackend = backend
+	return nil
+}
+
+// loadBackend builds the state backend from the config the first time it's needed, e.g. when tests set StateBackend directly
+func (o *Options) loadBackend() (StateBackend, error) {
+	if o.stateBackend == nil {
+		err := o.SetStateBackend(o.StateBackend)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o.stateBackend, nil
+}
+
+// backend returns the state backend for these options, once SetupEnvironment or locateState has loaded it
+func (o *Options) backend() StateBackend {
+	return o.stateBackend
+}
+
+// cloud returns the Azure access for these options, tests set Cloud to a fake
+func (o *Options) cloud() azure.Cloud {
+	if o.Cloud == nil {
+		azurerm, _ := o.stateBackend.(azurermBackend)
+		return azure.SDKCloud{AzureADAuth: azurerm.azureADAuth}
+	}
+	return o.Cloud
+}
+
+// Files written into the landingzone source to enable each backend, see removeBackendConfig
+var backendConfigFiles = []string{"backend.azurerm.tf", "backend.local.tf"}
+
+// removeBackendConfig stops terraform using remote state in the landingzone source
+func (o *Options) removeBackendConfig() {
+	for _, file := range backendConfigFiles {
+		_ = os.Remove(filepath.Join(o.SourcePath, file))
+	}
+}
@@ -0,0 +1,305 @@
+//
+// Rover - azurerm state backend
+// * State is held as blobs in the launchpad storage account, one container per workspace
+//
+
+package landingzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/aztfmod/rover/pkg/utils"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+type azurermBackend struct {
+	// azureADAuth has terraform use the signed in identity for state, rather than an access key
+	azureADAuth bool
+	// launchpadTags narrow the search for the launchpad, storageAccountID & keyVaultID skip it
+	launchpadTags    map[string]string
+	storageAccountID string
+	keyVaultID       string
+}
+
+func newAzurermBackend(conf StateBackendConfig) azurermBackend {
+	return azurermBackend{
+		azureADAuth:      conf.UsesAzureADAuth(),
+		launchpadTags:    conf.LaunchpadTags,
+		storageAccountID: conf.StorageAccountID,
+		keyVaultID:       conf.KeyVaultID,
+	}
+}
+
+// FindLaunchpadStorage returns the launchpad storage account for a level & environment, as the azurerm backend does
+func FindLaunchpadStorage(cloud azure.Cloud, conf StateBackendConfig, level string, environment string, subID string) (string, error) {
+	return newAzurermBackend(conf).findStorageAccount(cloud, level, environment, subID)
+}
+
+func (b azurermBackend) Name() string {
+	return BackendAzureRM
+}
+
+func (b azurermBackend) NeedsAzure() bool {
+	return true
+}
+
+// Locate finds the launchpad storage account tagged with the level and environment
+func (b azurermBackend) Locate(ctx context.Context, o *Options) (string, error) {
+	return b.findStorageAccount(o.cloud(), o.Level, o.CafEnvironment, o.StateSubscription)
+}
+
+// findStorageAccount returns the storage account given by ID, or the one with the launchpad tags
+func (b azurermBackend) findStorageAccount(cloud azure.Cloud, level string, environment string, subID string) (string, error) {
+	if b.storageAccountID == "" {
+		tags, err := LaunchpadTags(level, environment, b.launchpadTags)
+		if err != nil {
+			return "", err
+		}
+		return cloud.FindStorageAccount(tags, subID)
+	}
+
+	_, _, _, err := azure.ParseResourceID(b.storageAccountID)
+	if err != nil {
+		return "", fmt.Errorf("invalid launchpad storage account ID '%s': %s", b.storageAccountID, err)
+	}
+	// In launchpad mode the account may not be deployed yet
+	_, err = cloud.ListContainers(b.storageAccountID)
+	if err != nil {
+		return "", fmt.Errorf("%w: storage account %s: %s", azure.ErrLaunchpadNotFound, b.storageAccountID, err)
+	}
+	return b.storageAccountID, nil
+}
+
+// LaunchpadTags are the tags which identify the launchpad resources for a level & environment
+// The tag names can be changed in the rover config file, extra tags narrow the search
+func LaunchpadTags(level string, environment string, extra map[string]string) (map[string]string, error) {
+	levelTag, environmentTag := "level", "environment"
+	roverConfig, err := rover.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if roverConfig.LaunchpadTags != nil {
+		if roverConfig.LaunchpadTags.Level != "" {
+			levelTag = roverConfig.LaunchpadTags.Level
+		}
+		if roverConfig.LaunchpadTags.Environment != "" {
+			environmentTag = roverConfig.LaunchpadTags.Environment
+		}
+	}
+
+	tags := map[string]string{levelTag: level, environmentTag: environment}
+	for name, value := range extra {
+		tags[name] = value
+	}
+	return tags, nil
+}
+
+// Configure copies backend.azurerm to backend.azurerm.tf, enabling remote state in the landingzone source
+func (b azurermBackend) Configure(o *Options, storageID string) ([]tfexec.InitOption, error) {
+	// Catch a mistyped workspace here, terraform only gives a confusing backend error
+	err := CheckWorkspace(o.cloud(), storageID, o.Workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	console.Info("Enabling backend state with backend.azurerm.tf file")
+	err = utils.CopyFile(filepath.Join(o.SourcePath, "backend.azurerm"), filepath.Join(o.SourcePath, "backend.azurerm.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	subID, resGrp, accountName, err := azure.ParseResourceID(storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	initOptions := []tfexec.InitOption{
+		tfexec.BackendConfig(fmt.Sprintf("storage_account_name=%s", accountName)),
+		tfexec.BackendConfig(fmt.Sprintf("container_name=%s", o.Workspace)),
+		tfexec.BackendConfig(fmt.Sprintf("resource_group_name=%s", resGrp)),
+		tfexec.BackendConfig(fmt.Sprintf("key=%s", o.StateName+".tfstate")),
+	}
+
+	// The state is in the cloud rover is signed in to, which may be sovereign or loaded from ARM metadata
+	cloud, err := azure.CloudForEnvironment(o.Subscription.EnvironmentName)
+	if err != nil {
+		return nil, err
+	}
+	initOptions = append(initOptions, tfexec.BackendConfig(fmt.Sprintf("environment=%s", cloud.TerraformName)))
+	if cloud.MetadataHost != "" {
+		initOptions = append(initOptions, tfexec.BackendConfig(fmt.Sprintf("metadata_host=%s", cloud.MetadataHost)))
+	}
+
+	// With Azure AD auth terraform uses the same identity as rover, so no key is needed or shown in debug output
+	if b.azureADAuth {
+		console.Info("Using Azure AD authentication for remote state")
+		return append(initOptions,
+			tfexec.BackendConfig("use_azuread_auth=true"),
+			tfexec.BackendConfig(fmt.Sprintf("subscription_id=%s", subID)),
+		), nil
+	}
+
+	accessKey, err := o.cloud().GetAccountKey(subID, accountName, resGrp)
+	if err != nil {
+		return nil, err
+	}
+	return append(initOptions, tfexec.BackendConfig(fmt.Sprintf("access_key=%s", accessKey))), nil
+}
+
+// Connect reads the lower level details from the secrets in the launchpad key vault
+func (b azurermBackend) Connect(o *Options, lpStorageID string) error {
+	cloud := o.cloud()
+	lpKeyVaultID := b.keyVaultID
+	if lpKeyVaultID == "" {
+		tags, err := LaunchpadTags(o.Level, o.CafEnvironment, b.launchpadTags)
+		if err != nil {
+			return err
+		}
+		lpKeyVaultID, err = cloud.FindKeyVault(tags, o.StateSubscription)
+		if err != nil {
+			return err
+		}
+	}
+	if lpKeyVaultID == "" {
+		return fmt.Errorf("Unable to locate the launchpad for environment '%s' and level '%s'", o.CafEnvironment, o.Level)
+	}
+
+	lpTenantID, err := cloud.GetKeyVaultSecret(lpKeyVaultID, SecretTenantID)
+	if err != nil {
+		return err
+	}
+	lpLowerSAName, err := cloud.GetKeyVaultSecret(lpKeyVaultID, SecretLowerSAName)
+	if err != nil {
+		return err
+	}
+	lpLowerResGrp, err := cloud.GetKeyVaultSecret(lpKeyVaultID, SecretLowerRGName)
+	if err != nil {
+		return err
+	}
+
+	if lpLowerSAName == "" || lpTenantID == "" || lpLowerResGrp == "" {
+		return fmt.Errorf("Required secret(s) not found in launchpad, either you are not authorized or the launchpad was not deployed correctly")
+	}
+
+	_, lpStorageResGrp, lpStorageName, err := azure.ParseResourceID(lpStorageID)
+	if err != nil {
+		return err
+	}
+
+	_ = os.Setenv("TF_VAR_tenant_id", lpTenantID)
+	_ = os.Setenv("TF_VAR_tfstate_storage_account_name", lpStorageName)
+	_ = os.Setenv("TF_VAR_tfstate_resource_group_name", lpStorageResGrp)
+	_ = os.Setenv("TF_VAR_lower_storage_account_name", lpLowerSAName)
+	_ = os.Setenv("TF_VAR_lower_resource_group_name", lpLowerResGrp)
+
+	_ = os.Setenv("TF_VAR_tfstate_container_name", o.Workspace)
+	_ = os.Setenv("TF_VAR_lower_container_name", o.Workspace)
+	// NOTE: This will have been set by initializeCAF()
+	//_ = os.Setenv("TF_VAR_tfstate_key", os.Getenv("TF_VAR_tf_name"))
+	_ = os.Setenv("TF_VAR_tfstate_key", fmt.Sprintf("%s.tfstate", o.StateName))
+
+	console.Debugf(" - TF_VAR_tenant_id=%s\n", lpTenantID)
+	console.Debugf(" - TF_VAR_tfstate_storage_account_name=%s\n", lpStorageName)
+	console.Debugf(" - TF_VAR_tfstate_resource_group_name=%s\n", lpStorageResGrp)
+	console.Debugf(" - TF_VAR_lower_storage_account_name=%s\n", lpLowerSAName)
+	console.Debugf(" - TF_VAR_lower_resource_group_name=%s\n", lpLowerResGrp)
+	console.Debugf(" - TF_VAR_tfstate_key=%s\n", os.Getenv("TF_VAR_tfstate_key"))
+
+	return nil
+}
+
+// Upload uses the blob ETag as the state version, a leased (locked) blob can't be replaced either
+func (b azurermBackend) Upload(o *Options, storageID string, stateFile string, version string) error {
+	err := o.cloud().UploadFileToBlobIfMatch(storageID, o.Workspace, o.StateName+".tfstate", stateFile, version)
+	if errors.Is(err, azure.ErrBlobChanged) {
+		return errStateChanged
+	}
+	return err
+}
+
+func (b azurermBackend) Download(o *Options, storageID string, stateFile string) (string, error) {
+	return o.cloud().DownloadFileFromBlob(storageID, o.Workspace, o.StateName+".tfstate", stateFile)
+}
+
+func (b azurermBackend) Delete(o *Options, storageID string, version string) error {
+	err := o.cloud().DeleteBlobIfMatch(storageID, o.Workspace, o.StateName+".tfstate", version)
+	if errors.Is(err, azure.ErrBlobChanged) {
+		return errStateChanged
+	}
+	return err
+}
+
+// LockInfo reads the lease on the state blob, terraform keeps the lock details in the blob metadata
+func (b azurermBackend) LockInfo(o *Options, storageID string) (*StateLock, error) {
+	lease, err := o.cloud().GetBlobLease(storageID, o.Workspace, o.StateName+".tfstate")
+	if err != nil {
+		return nil, err
+	}
+	return azurermStateLock(lease)
+}
+
+// Unlock breaks the lease on the state blob and removes the lock details, as long as the blob is unchanged since the lock was read
+func (b azurermBackend) Unlock(o *Options, storageID string, lock *StateLock) error {
+	return o.cloud().BreakBlobLease(storageID, o.Workspace, o.StateName+".tfstate", azurermLockMetaKey, lock.etag)
+}
+
+// SaveSnapshot copies the state blob into the snapshots folder of the workspace container
+func (b azurermBackend) SaveSnapshot(o *Options, storageID string, id string) (bool, error) {
+	err := o.cloud().CopyBlob(storageID, o.Workspace, o.StateName+".tfstate", o.Workspace, b.snapshotBlob(o, id))
+	if azure.IsBlobNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b azurermBackend) Snapshots(o *Options, storageID string) ([]StateSnapshot, error) {
+	blobs, err := o.cloud().ListBlobs(storageID, o.Workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []StateSnapshot{}
+	for _, blob := range blobs {
+		if !strings.HasPrefix(blob.Name, SnapshotsPrefix) {
+			continue
+		}
+		var size int64
+		if blob.Properties.ContentLength != nil {
+			size = *blob.Properties.ContentLength
+		}
+		if snapshot, ok := parseSnapshotName(o.StateName, strings.TrimPrefix(blob.Name, SnapshotsPrefix), size); ok {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+func (b azurermBackend) DownloadSnapshot(o *Options, storageID string, id string, stateFile string) error {
+	_, err := o.cloud().DownloadFileFromBlob(storageID, o.Workspace, b.snapshotBlob(o, id), stateFile)
+	return err
+}
+
+func (b azurermBackend) DeleteSnapshot(o *Options, storageID string, id string) error {
+	return o.cloud().DeleteBlobIfMatch(storageID, o.Workspace, b.snapshotBlob(o, id), azure.BlobETagAny)
+}
+
+func (b azurermBackend) snapshotBlob(o *Options, id string) string {
+	return SnapshotsPrefix + snapshotName(o.StateName, id)
+}
+
+func (b azurermBackend) Describe(storageID string) string {
+	_, _, accountName, err := azure.ParseResourceID(storageID)
+	if err != nil {
+		accountName = storageID
+	}
+	return fmt.Sprintf("storage account '%s'", accountName)
+}
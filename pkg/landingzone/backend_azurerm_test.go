@@ -0,0 +1,267 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/azure/azuretest"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSubID     = "00000000-0000-0000-0000-000000000001"
+	testStorageL0 = "/subscriptions/" + testSubID + "/resourceGroups/rg-launchpad/providers/Microsoft.Storage/storageAccounts/stlevel0"
+	testStorageL1 = "/subscriptions/" + testSubID + "/resourceGroups/rg-launchpad/providers/Microsoft.Storage/storageAccounts/stlevel1"
+	testVaultL1   = "/subscriptions/" + testSubID + "/resourceGroups/rg-launchpad/providers/Microsoft.KeyVault/vaults/kvlevel1"
+)
+
+// newTestCloud is a launchpad for levels 0 & 1 in the sandpit environment, with a tfstate workspace
+func newTestCloud() *azuretest.FakeCloud {
+	cloud := azuretest.NewFakeCloud(azure.Subscription{ID: testSubID, TenantID: "tenant-1", User: azure.AccountUser{Name: "dev@contoso.com", Usertype: "user"}})
+	cloud.Identity = azure.Identity{DisplayName: "Dev", ObjectID: "object-1", ObjectType: "User"}
+	cloud.AddStorageAccount(testStorageL0, "level0", "sandpit")
+	cloud.AddStorageAccount(testStorageL1, "level1", "sandpit")
+	cloud.AddKeyVault(testVaultL1, "level1", "sandpit", map[string]string{
+		SecretTenantID:    "tenant-1",
+		SecretLowerSAName: "stlevel0",
+		SecretLowerRGName: "rg-launchpad",
+	})
+	_ = cloud.CreateContainer(testStorageL0, "tfstate")
+	_ = cloud.CreateContainer(testStorageL1, "tfstate")
+	return cloud
+}
+
+func newTestOptions(cloud azure.Cloud, level string, stateName string) *Options {
+	return &Options{
+		Level:             level,
+		CafEnvironment:    "sandpit",
+		Workspace:         "tfstate",
+		StateName:         stateName,
+		StateSubscription: testSubID,
+		Snapshots:         &rover.SnapshotConfig{Retain: 5},
+		Cloud:             cloud,
+	}
+}
+
+func Test_AzureRM_Backend(t *testing.T) {
+	cloud := newTestCloud()
+	o := newTestOptions(cloud, "level1", "web")
+	o.SourcePath = t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(o.SourcePath, "backend.azurerm"), []byte(`terraform { backend "azurerm" {} }`), 0644))
+	backend := azurermBackend{}
+
+	location, err := backend.Locate(context.Background(), o)
+	assert.Nil(t, err)
+	assert.Equal(t, testStorageL1, location)
+	_, err = backend.Locate(context.Background(), newTestOptions(cloud, "level2", "web"))
+	assert.Error(t, err)
+
+	// The backend is in the same cloud rover is signed in to
+	o.Subscription.EnvironmentName = "AzureChinaCloud"
+	initOptions, err := backend.Configure(o, location)
+	assert.Nil(t, err)
+	assert.Len(t, initOptions, 6)
+	assert.Contains(t, initOptions, tfexec.BackendConfig("environment=china"))
+	assert.FileExists(t, filepath.Join(o.SourcePath, "backend.azurerm.tf"))
+
+	o.Workspace = "tfstat"
+	_, err = backend.Configure(o, location)
+	assert.EqualError(t, err, "workspace 'tfstat' does not exist in the launchpad storage account, did you mean: tfstate")
+	o.Workspace = "tfstate"
+
+	// The lower level details come from the launchpad key vault
+	assert.Nil(t, backend.Connect(o, location))
+	assert.Equal(t, "stlevel1", os.Getenv("TF_VAR_tfstate_storage_account_name"))
+	assert.Equal(t, "stlevel0", os.Getenv("TF_VAR_lower_storage_account_name"))
+	assert.Equal(t, "web.tfstate", os.Getenv("TF_VAR_tfstate_key"))
+	assert.Error(t, backend.Connect(newTestOptions(cloud, "level0", "launchpad"), testStorageL0))
+}
+
+func Test_AzureRM_Launchpad_Lookup(t *testing.T) {
+	const testStorageL1b = "/subscriptions/" + testSubID + "/resourceGroups/rg-launchpad-new/providers/Microsoft.Storage/storageAccounts/stlevel1new"
+	cloud := newTestCloud()
+	cloud.AddStorageAccount(testStorageL1b, "level1", "sandpit")
+	o := newTestOptions(cloud, "level1", "web")
+	ctx := context.Background()
+
+	// Two launchpads for the level, e.g. part way through a migration
+	_, err := azurermBackend{}.Locate(ctx, o)
+	assert.True(t, errors.Is(err, azure.ErrLaunchpadAmbiguous))
+	assert.Contains(t, err.Error(), testStorageL1)
+	assert.Contains(t, err.Error(), testStorageL1b)
+
+	// Extra tags narrow the search
+	cloud.SetTags(testStorageL1b, map[string]string{"level": "level1", "environment": "sandpit", "caf_tfstate": "level1"})
+	location, err := azurermBackend{launchpadTags: map[string]string{"caf_tfstate": "level1"}}.Locate(ctx, o)
+	assert.Nil(t, err)
+	assert.Equal(t, testStorageL1b, location)
+
+	// Or the storage account is named, it must exist
+	location, err = azurermBackend{storageAccountID: testStorageL1}.Locate(ctx, o)
+	assert.Nil(t, err)
+	assert.Equal(t, testStorageL1, location)
+	_, err = azurermBackend{storageAccountID: testStorageL1 + "x"}.Locate(ctx, o)
+	assert.True(t, azure.IsLaunchpadNotFound(err))
+
+	// The tag names can be changed in the rover config file
+	roverHome, err := rover.HomeDirectory()
+	assert.Nil(t, err)
+	t.Cleanup(func() { rover.SetHomeDirectory(roverHome) })
+	rover.SetHomeDirectory(t.TempDir())
+	home, _ := rover.HomeDirectory()
+	assert.Nil(t, os.WriteFile(filepath.Join(home, "config.yaml"), []byte("launchpadTags:\n  level: caf_level\n"), 0644))
+	cloud.SetTags(testStorageL1b, map[string]string{"caf_level": "level1", "environment": "sandpit"})
+	location, err = azurermBackend{}.Locate(ctx, o)
+	assert.Nil(t, err)
+	assert.Equal(t, testStorageL1b, location)
+}
+
+func Test_AzureRM_State(t *testing.T) {
+	cloud := newTestCloud()
+	o := newTestOptions(cloud, "level1", "web")
+	backend := azurermBackend{}
+	ctx := context.Background()
+
+	_, err := backend.Download(o, testStorageL1, filepath.Join(t.TempDir(), "web.tfstate"))
+	assert.True(t, isStateNotFound(err))
+
+	stateFile := filepath.Join(t.TempDir(), "web.tfstate")
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`{"version":4,"serial":2,"lineage":"a1"}`), 0644))
+	assert.Nil(t, o.uploadState(backend, testStorageL1, stateFile, false))
+	assert.ErrorIs(t, backend.Upload(o, testStorageL1, stateFile, stateVersionNone), errStateChanged)
+	assert.ErrorIs(t, backend.Delete(o, testStorageL1, `"0x0"`), errStateChanged)
+
+	// Snapshots sit alongside the state, and aren't listed as landingzones
+	saved, err := backend.SaveSnapshot(o, testStorageL1, "20210901T100000Z")
+	assert.Nil(t, err)
+	assert.True(t, saved)
+	_, ok := cloud.GetBlob(testStorageL1, "tfstate", "snapshots/web.tfstate.20210901T100000Z")
+	assert.True(t, ok)
+
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "tfstate", "web.tfstate", []byte(`{"version":4,"serial":5,"lineage":"a1"}`)))
+	assert.Nil(t, o.RestoreSnapshot(ctx, "20210901T100000Z", false))
+	meta, err := readStateMeta(downloadTestState(t, backend, o))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(6), meta.Serial)
+
+	snapshots, err := o.StateSnapshots(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 2)
+
+	// A leased blob is locked, rover names the holder and can break the lease
+	lockID := base64.StdEncoding.EncodeToString([]byte(testLockInfo))
+	assert.Nil(t, cloud.LeaseBlob(testStorageL1, "tfstate", "web.tfstate", map[string]string{"terraformlockid": lockID}))
+	lock, err := o.StateLockInfo(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "runner@fv-az123", lock.Who)
+	assert.Error(t, o.RestoreSnapshot(ctx, "20210901T100000Z", false))
+
+	_, err = o.UnlockState(ctx, "wrong-id")
+	assert.Error(t, err)
+
+	// A lock taken by another run after this one was read is left alone
+	stale, err := backend.LockInfo(o, testStorageL1)
+	assert.Nil(t, err)
+	assert.Nil(t, cloud.BreakBlobLease(testStorageL1, "tfstate", "web.tfstate", "terraformlockid", stale.etag))
+	assert.Nil(t, cloud.LeaseBlob(testStorageL1, "tfstate", "web.tfstate", map[string]string{"terraformlockid": lockID}))
+	err = backend.Unlock(o, testStorageL1, stale)
+	assert.True(t, errors.Is(err, azure.ErrBlobChanged))
+	lock, err = o.StateLockInfo(ctx)
+	assert.Nil(t, err)
+	assert.NotNil(t, lock)
+
+	_, err = o.UnlockState(ctx, lock.ID)
+	assert.Nil(t, err)
+	lock, err = o.StateLockInfo(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+}
+
+func Test_AzureRM_Migrate_State(t *testing.T) {
+	cloud := newTestCloud()
+	base := *newTestOptions(cloud, "", "")
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "tfstate", "web.tfstate", []byte(`{"version":4,"serial":2,"lineage":"a1"}`)))
+
+	from, _ := ParseStateAddress("level1/sandpit/tfstate/web")
+	to, _ := ParseStateAddress("level0/sandpit/tfstate/web")
+	assert.Nil(t, MigrateState(context.Background(), base, from, to, MigrateOptions{DeleteSource: true}))
+
+	_, ok := cloud.GetBlob(testStorageL0, "tfstate", "web.tfstate")
+	assert.True(t, ok)
+	_, ok = cloud.GetBlob(testStorageL1, "tfstate", "web.tfstate")
+	assert.False(t, ok)
+
+	// The destination workspace must exist
+	to, _ = ParseStateAddress("level1/sandpit/missing/web")
+	from, _ = ParseStateAddress("level0/sandpit/tfstate/web")
+	assert.Error(t, MigrateState(context.Background(), base, from, to, MigrateOptions{}))
+
+	// State can be moved to a launchpad in another subscription
+	const prodSubID = "00000000-0000-0000-0000-000000000002"
+	const prodStorageL0 = "/subscriptions/" + prodSubID + "/resourceGroups/rg-launchpad/providers/Microsoft.Storage/storageAccounts/stprodlevel0"
+	cloud.AddStorageAccount(prodStorageL0, "level0", "sandpit")
+	assert.Nil(t, cloud.CreateContainer(prodStorageL0, "tfstate"))
+	to, _ = ParseStateAddress("level0/sandpit/tfstate/web")
+	to.Subscription = prodSubID
+	assert.Nil(t, MigrateState(context.Background(), base, from, to, MigrateOptions{}))
+	_, ok = cloud.GetBlob(prodStorageL0, "tfstate", "web.tfstate")
+	assert.True(t, ok)
+
+	// Giving the subscription already used for state doesn't make it a different address
+	to.Subscription = testSubID
+	assert.Error(t, MigrateState(context.Background(), base, from, to, MigrateOptions{}))
+}
+
+func Test_AzureRM_Copy_Workspace(t *testing.T) {
+	rover.SetHomeDirectory(t.TempDir())
+	cloud := newTestCloud()
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "tfstate", "web.tfstate", []byte(`{"version":4,"serial":2,"lineage":"a1"}`)))
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "tfstate", "snapshots/web.tfstate.20210901T100000Z", []byte(`{"version":4,"serial":1,"lineage":"a1"}`)))
+	assert.Nil(t, cloud.CreateContainer(testStorageL1, "dev"))
+
+	copied, err := CopyWorkspace(cloud, StateBackendConfig{}, testStorageL1, "tfstate", "dev", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, copied)
+	_, ok := cloud.GetBlob(testStorageL1, "dev", "snapshots/web.tfstate.20210901T100000Z")
+	assert.False(t, ok)
+
+	// Newer state in the destination is only replaced when forced, after a snapshot
+	assert.Nil(t, cloud.PutBlob(testStorageL1, "dev", "web.tfstate", []byte(`{"version":4,"serial":7,"lineage":"a1"}`)))
+	_, err = CopyWorkspace(cloud, StateBackendConfig{}, testStorageL1, "tfstate", "dev", false)
+	assert.Error(t, err)
+	copied, err = CopyWorkspace(cloud, StateBackendConfig{}, testStorageL1, "tfstate", "dev", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, copied)
+	snapshots, err := azurermBackend{}.Snapshots(&Options{Workspace: "dev", StateName: "web", Cloud: cloud}, testStorageL1)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, snapshots)
+
+	// Locked state is never replaced
+	assert.Nil(t, cloud.LeaseBlob(testStorageL1, "dev", "web.tfstate", nil))
+	_, err = CopyWorkspace(cloud, StateBackendConfig{}, testStorageL1, "tfstate", "dev", true)
+	assert.Error(t, err)
+
+	// A copy of the state is saved before a workspace is deleted
+	backupDir, saved, err := BackupWorkspace(cloud, testStorageL1, "tfstate")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, saved)
+	assert.FileExists(t, filepath.Join(backupDir, "web.tfstate"))
+	assert.NoFileExists(t, filepath.Join(backupDir, "web.tfstate.20210901T100000Z"))
+}
+
+func downloadTestState(t *testing.T, backend StateBackend, o *Options) string {
+	stateFile := filepath.Join(t.TempDir(), o.StateName+".tfstate")
+	_, err := backend.Download(o, testStorageL1, stateFile)
+	assert.Nil(t, err)
+	return stateFile
+}
@@ -0,0 +1,261 @@
+//
+// Rover - local state backend
+// * State is held on disk, laid out as <path>/<environment>/<level>/<workspace>/<statename>.tfstate
+// * Snapshots are kept alongside, in <path>/<environment>/<level>/<workspace>/snapshots
+// * No Azure login is needed for state, so whole symphonies can be run in sandboxes & tests
+//
+
+package landingzone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/aztfmod/rover/pkg/utils"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// Written to the landingzone source in place of backend.azurerm.tf
+const localBackendConfig = `# Created by rover for the local state backend, do not edit
+terraform {
+  backend "local" {}
+}
+`
+
+var levelNumberRegex = regexp.MustCompile(`^level(\d+)$`)
+
+type localBackend struct {
+	root string
+}
+
+// newLocalBackend keeps state under root, by default the state directory in the rover home
+func newLocalBackend(root string) (localBackend, error) {
+	if root == "" {
+		roverHome, err := rover.HomeDirectory()
+		if err != nil {
+			return localBackend{}, err
+		}
+		root = filepath.Join(roverHome, "state")
+	}
+	return localBackend{root: root}, nil
+}
+
+func (b localBackend) Name() string {
+	return BackendLocal
+}
+
+func (b localBackend) NeedsAzure() bool {
+	return false
+}
+
+// Locate returns the directory for the level, as with azurerm the launchpad must be deployed first
+// Deploying the launchpad creates the directory for the environment, which holds the state for all levels
+func (b localBackend) Locate(ctx context.Context, o *Options) (string, error) {
+	envDir := filepath.Join(b.root, o.CafEnvironment)
+	if o.LaunchPadMode {
+		err := os.MkdirAll(envDir, os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+	}
+	if _, err := os.Stat(envDir); err != nil {
+		return "", fmt.Errorf("no local state found for environment '%s' in %s", o.CafEnvironment, b.root)
+	}
+
+	levelDir := filepath.Join(envDir, o.Level)
+	return levelDir, os.MkdirAll(levelDir, os.ModePerm)
+}
+
+// Configure writes backend.local.tf, enabling the local backend in the landingzone source
+func (b localBackend) Configure(o *Options, levelDir string) ([]tfexec.InitOption, error) {
+	workspaceDir := filepath.Join(levelDir, o.Workspace)
+	err := os.MkdirAll(workspaceDir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	console.Info("Enabling backend state with backend.local.tf file")
+	err = os.WriteFile(filepath.Join(o.SourcePath, "backend.local.tf"), []byte(localBackendConfig), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return []tfexec.InitOption{
+		tfexec.BackendConfig(fmt.Sprintf("path=%s", b.statePath(o, levelDir))),
+	}, nil
+}
+
+// Connect points the landingzone at the state directories for its own level and the level below
+// Landingzones read these with a terraform_remote_state using the local backend
+func (b localBackend) Connect(o *Options, levelDir string) error {
+	lowerDir := filepath.Join(filepath.Dir(levelDir), lowerLevel(o.Level), o.Workspace)
+
+	_ = os.Setenv("TF_VAR_backend_type", BackendLocal)
+	_ = os.Setenv("TF_VAR_tfstate_path", filepath.Join(levelDir, o.Workspace))
+	_ = os.Setenv("TF_VAR_lower_tfstate_path", lowerDir)
+	_ = os.Setenv("TF_VAR_tfstate_container_name", o.Workspace)
+	_ = os.Setenv("TF_VAR_lower_container_name", o.Workspace)
+	_ = os.Setenv("TF_VAR_tfstate_key", fmt.Sprintf("%s.tfstate", o.StateName))
+
+	console.Debugf(" - TF_VAR_tfstate_path=%s\n", os.Getenv("TF_VAR_tfstate_path"))
+	console.Debugf(" - TF_VAR_lower_tfstate_path=%s\n", lowerDir)
+	console.Debugf(" - TF_VAR_tfstate_key=%s\n", os.Getenv("TF_VAR_tfstate_key"))
+
+	return nil
+}
+
+// Upload uses a hash of the state file as its version
+// The check and copy aren't atomic, local state is only meant for a single user
+func (b localBackend) Upload(o *Options, levelDir string, stateFile string, version string) error {
+	err := os.MkdirAll(filepath.Join(levelDir, o.Workspace), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if version != stateVersionAny {
+		current, err := fileSHA256(b.statePath(o, levelDir))
+		if os.IsNotExist(err) {
+			current, err = stateVersionNone, nil
+		}
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return errStateChanged
+		}
+	}
+	return utils.CopyFile(stateFile, b.statePath(o, levelDir))
+}
+
+func (b localBackend) Download(o *Options, levelDir string, stateFile string) (string, error) {
+	err := utils.CopyFile(b.statePath(o, levelDir), stateFile)
+	if err != nil {
+		return "", err
+	}
+	return fileSHA256(stateFile)
+}
+
+func (b localBackend) Delete(o *Options, levelDir string, version string) error {
+	if version != stateVersionAny {
+		current, err := fileSHA256(b.statePath(o, levelDir))
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return errStateChanged
+		}
+	}
+	return os.Remove(b.statePath(o, levelDir))
+}
+
+// LockInfo reads the lock info file terraform writes next to the state
+// Terraform also holds a file lock while running, so a lock info file left by a killed process may be stale
+func (b localBackend) LockInfo(o *Options, levelDir string) (*StateLock, error) {
+	lockJSON, err := os.ReadFile(b.lockInfoPath(o, levelDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStateLock(lockJSON)
+}
+
+// Unlock removes the lock info file, as long as it still holds the lock which was read
+func (b localBackend) Unlock(o *Options, levelDir string, lock *StateLock) error {
+	current, err := b.LockInfo(o, levelDir)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+	if current.ID != lock.ID || !current.Created.Equal(lock.Created) {
+		return errLockChanged
+	}
+	return os.Remove(b.lockInfoPath(o, levelDir))
+}
+
+func (b localBackend) SaveSnapshot(o *Options, levelDir string, id string) (bool, error) {
+	err := os.MkdirAll(b.snapshotDir(o, levelDir), os.ModePerm)
+	if err != nil {
+		return false, err
+	}
+	err = utils.CopyFile(b.statePath(o, levelDir), b.snapshotPath(o, levelDir, id))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b localBackend) Snapshots(o *Options, levelDir string) ([]StateSnapshot, error) {
+	snapshots := []StateSnapshot{}
+	entries, err := os.ReadDir(b.snapshotDir(o, levelDir))
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if snapshot, ok := parseSnapshotName(o.StateName, entry.Name(), info.Size()); ok {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+func (b localBackend) DownloadSnapshot(o *Options, levelDir string, id string, stateFile string) error {
+	return utils.CopyFile(b.snapshotPath(o, levelDir, id), stateFile)
+}
+
+func (b localBackend) DeleteSnapshot(o *Options, levelDir string, id string) error {
+	return os.Remove(b.snapshotPath(o, levelDir, id))
+}
+
+func (b localBackend) Describe(levelDir string) string {
+	return fmt.Sprintf("directory '%s'", levelDir)
+}
+
+func (b localBackend) statePath(o *Options, levelDir string) string {
+	return filepath.Join(levelDir, o.Workspace, o.StateName+".tfstate")
+}
+
+func (b localBackend) snapshotDir(o *Options, levelDir string) string {
+	return filepath.Join(levelDir, o.Workspace, SnapshotsPrefix)
+}
+
+func (b localBackend) snapshotPath(o *Options, levelDir string, id string) string {
+	return filepath.Join(b.snapshotDir(o, levelDir), snapshotName(o.StateName, id))
+}
+
+// lockInfoPath matches the name the terraform local backend uses, .<statefile>.lock.info
+func (b localBackend) lockInfoPath(o *Options, levelDir string) string {
+	return filepath.Join(levelDir, o.Workspace, fmt.Sprintf(".%s.tfstate.lock.info", o.StateName))
+}
+
+// lowerLevel is the level whose state a landingzone reads, level0 reads its own state
+// Levels not named levelN have no lower level, so read their own
+func lowerLevel(level string) string {
+	matches := levelNumberRegex.FindStringSubmatch(level)
+	if matches == nil {
+		return level
+	}
+	n, err := strconv.Atoi(matches[1])
+	if err != nil || n == 0 {
+		return level
+	}
+	return fmt.Sprintf("level%d", n-1)
+}
@@ -0,0 +1,115 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New_State_Backend(t *testing.T) {
+	backend, err := NewStateBackend(StateBackendConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, BackendAzureRM, backend.Name())
+	assert.True(t, backend.NeedsAzure())
+
+	backend, err = NewStateBackend(StateBackendConfig{Type: BackendLocal, Path: "/tmp/state"})
+	assert.Nil(t, err)
+	assert.Equal(t, BackendLocal, backend.Name())
+	assert.False(t, backend.NeedsAzure())
+
+	azureADAuth := true
+	backend, err = NewStateBackend(StateBackendConfig{Type: BackendAzureRM, AzureADAuth: &azureADAuth})
+	assert.Nil(t, err)
+	assert.True(t, backend.(azurermBackend).azureADAuth)
+	_, err = NewStateBackend(StateBackendConfig{Type: BackendLocal, AzureADAuth: &azureADAuth})
+	assert.Error(t, err)
+
+	_, err = NewStateBackend(StateBackendConfig{Type: "s3"})
+	assert.Error(t, err)
+	_, err = NewStateBackend(StateBackendConfig{Type: BackendAzureRM, Path: "/tmp/state"})
+	assert.Error(t, err)
+}
+
+func Test_Local_Backend(t *testing.T) {
+	root := t.TempDir()
+	source := t.TempDir()
+	backend := localBackend{root: root}
+	ctx := context.Background()
+
+	// A landingzone can't be deployed before the launchpad
+	o := &Options{Level: "level1", CafEnvironment: "sandpit", Workspace: "tfstate", StateName: "web", SourcePath: source}
+	_, err := backend.Locate(ctx, o)
+	assert.Error(t, err)
+
+	launchpad := &Options{Level: "level0", CafEnvironment: "sandpit", Workspace: "tfstate", StateName: "launchpad", LaunchPadMode: true}
+	location, err := backend.Locate(ctx, launchpad)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "sandpit", "level0"), location)
+
+	location, err = backend.Locate(ctx, o)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "sandpit", "level1"), location)
+
+	initOptions, err := backend.Configure(o, location)
+	assert.Nil(t, err)
+	assert.Len(t, initOptions, 1)
+	assert.FileExists(t, filepath.Join(source, "backend.local.tf"))
+	o.removeBackendConfig()
+	assert.NoFileExists(t, filepath.Join(source, "backend.local.tf"))
+
+	// State is handed to the level above through the lower path
+	err = backend.Connect(o, location)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "sandpit", "level1", "tfstate"), os.Getenv("TF_VAR_tfstate_path"))
+	assert.Equal(t, filepath.Join(root, "sandpit", "level0", "tfstate"), os.Getenv("TF_VAR_lower_tfstate_path"))
+	assert.Equal(t, "web.tfstate", os.Getenv("TF_VAR_tfstate_key"))
+
+	stateFile := filepath.Join(t.TempDir(), "web.tfstate")
+	err = os.WriteFile(stateFile, []byte(`{"version": 4, "serial": 1}`), 0644)
+	assert.Nil(t, err)
+	err = backend.Upload(o, location, stateFile, stateVersionNone)
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(location, "tfstate", "web.tfstate"))
+	err = backend.Upload(o, location, stateFile, stateVersionNone)
+	assert.ErrorIs(t, err, errStateChanged)
+
+	downloaded := filepath.Join(t.TempDir(), "downloaded.tfstate")
+	version, err := backend.Download(o, location, downloaded)
+	assert.Nil(t, err)
+	content, _ := os.ReadFile(downloaded)
+	assert.Equal(t, `{"version": 4, "serial": 1}`, string(content))
+
+	// Uploads only replace state which hasn't changed since it was downloaded
+	err = backend.Upload(o, location, stateFile, version)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(location, "tfstate", "web.tfstate"), []byte(`{"version": 4, "serial": 2}`), 0644)
+	assert.Nil(t, err)
+	err = backend.Upload(o, location, stateFile, version)
+	assert.ErrorIs(t, err, errStateChanged)
+	err = backend.Upload(o, location, stateFile, stateVersionAny)
+	assert.Nil(t, err)
+
+	// As do deletes
+	err = os.WriteFile(filepath.Join(location, "tfstate", "web.tfstate"), []byte(`{"version": 4, "serial": 3}`), 0644)
+	assert.Nil(t, err)
+	err = backend.Delete(o, location, version)
+	assert.ErrorIs(t, err, errStateChanged)
+	version, err = backend.Download(o, location, downloaded)
+	assert.Nil(t, err)
+	err = backend.Delete(o, location, version)
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filepath.Join(location, "tfstate", "web.tfstate"))
+}
+
+func Test_Lower_Level(t *testing.T) {
+	assert.Equal(t, "level0", lowerLevel("level0"))
+	assert.Equal(t, "level0", lowerLevel("level1"))
+	assert.Equal(t, "level3", lowerLevel("level4"))
+	assert.Equal(t, "shared", lowerLevel("shared"))
+}
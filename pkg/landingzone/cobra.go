@@ -30,6 +30,7 @@ func BuildOptions(cmd *cobra.Command) []Options {
 	targetSub, _ := cmd.Flags().GetString("target-sub")
 	testpath, _ := cmd.Flags().GetString("test-source")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
 
 	// Normally cobra would provide automatic defaults but we are using it in a weird way
 	if level == "" {
@@ -56,6 +57,7 @@ func BuildOptions(cmd *cobra.Command) []Options {
 		StateSubscription:  stateSub,
 		TestPath:           testpath,
 		DryRun:             dryRun,
+		Timeout:            timeout,
 	}
 
 	// Safely set the paths up
@@ -0,0 +1,64 @@
+//
+// Rover - Diagnostics output
+// * The --format & --out flags shared by the commands which report diagnostics, validate and lint-config
+//
+
+package landingzone
+
+import (
+	"io"
+	"os"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+// DiagnosticsOutput is how and where a report of diagnostics is written
+type DiagnosticsOutput struct {
+	// Format of the diagnostics, one of ValidateFormats
+	Format string
+	// Out is a file to write the diagnostics to, when empty they are written to stdout
+	Out string
+}
+
+// AddDiagnosticsFlags adds the --format & --out flags to a command
+func AddDiagnosticsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("format", "o", ValidateFormatText, "Diagnostics format: text, json, sarif, github or azdo")
+	cmd.Flags().String("out", "", "Write the diagnostics to this file rather than stdout")
+}
+
+// ParseDiagnosticsFlags reads the --format & --out flags, checking the format before any work is done
+// Unless the diagnostics are text or go to a file, rover messages are sent to stderr to keep stdout clean for them
+func ParseDiagnosticsFlags(cmd *cobra.Command) DiagnosticsOutput {
+	output := DiagnosticsOutput{}
+	output.Format, _ = cmd.Flags().GetString("format")
+	output.Out, _ = cmd.Flags().GetString("out")
+	err := WriteDiagnostics(io.Discard, ValidateReport{}, output.Format)
+	cobra.CheckErr(err)
+
+	if output.Format != ValidateFormatText && output.Out == "" {
+		console.SetOutput(os.Stderr)
+	}
+	return output
+}
+
+// Write writes the report in the format, to the out file or stdout
+func (d DiagnosticsOutput) Write(report ValidateReport) error {
+	w := os.Stdout
+	if d.Out != "" {
+		file, err := os.Create(d.Out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	err := WriteDiagnostics(w, report, d.Format)
+	if err != nil {
+		return err
+	}
+	if d.Out != "" {
+		console.Infof("Diagnostics written to %s\n", d.Out)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+//
+// Rover - Drift detection
+// * Parses refresh-only plans to find resources changed outside of Terraform
+//
+
+package landingzone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DriftedResource is a single resource which no longer matches what is held in state
+type DriftedResource struct {
+	Address    string   `json:"address"`
+	Actions    []string `json:"actions"`
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// StackDrift holds the drift detected in a single level & stack
+type StackDrift struct {
+	Level     string            `json:"level"`
+	Stack     string            `json:"stack"`
+	Resources []DriftedResource `json:"resources"`
+}
+
+// DriftReport is the result of a drift check across one or more stacks
+type DriftReport struct {
+	Drifted bool         `json:"drifted"`
+	Stacks  []StackDrift `json:"stacks"`
+}
+
+// Subset of the 'terraform show -json' plan format, terraform-json doesn't support resource_drift yet
+type planResourceChange struct {
+	Address string `json:"address"`
+	Change  struct {
+		Actions []string               `json:"actions"`
+		Before  map[string]interface{} `json:"before"`
+		After   map[string]interface{} `json:"after"`
+	} `json:"change"`
+}
+
+type planDrift struct {
+	ResourceDrift   []planResourceChange `json:"resource_drift"`
+	ResourceChanges []planResourceChange `json:"resource_changes"`
+}
+
+// ParseDrift finds the drifted resources in the JSON of a refresh-only plan
+func ParseDrift(planJSON []byte) ([]DriftedResource, error) {
+	plan := planDrift{}
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("unable to parse plan JSON: %s", err)
+	}
+
+	// Older versions of terraform report refresh-only changes in resource_changes
+	changes := plan.ResourceDrift
+	if len(changes) == 0 {
+		changes = plan.ResourceChanges
+	}
+
+	drifted := []DriftedResource{}
+	for _, rc := range changes {
+		if len(rc.Change.Actions) == 0 || (len(rc.Change.Actions) == 1 && rc.Change.Actions[0] == "no-op") {
+			continue
+		}
+		drifted = append(drifted, DriftedResource{
+			Address:    rc.Address,
+			Actions:    rc.Change.Actions,
+			Attributes: changedAttributes(rc.Change.Before, rc.Change.After),
+		})
+	}
+
+	sort.Slice(drifted, func(i, j int) bool { return drifted[i].Address < drifted[j].Address })
+	return drifted, nil
+}
+
+// changedAttributes compares the top level attributes of a resource before & after refresh
+func changedAttributes(before map[string]interface{}, after map[string]interface{}) []string {
+	// Resource was deleted outside of Terraform, no point listing every attribute
+	if before == nil || after == nil {
+		return nil
+	}
+
+	attrs := []string{}
+	for name, beforeVal := range before {
+		if !reflect.DeepEqual(beforeVal, after[name]) {
+			attrs = append(attrs, name)
+		}
+	}
+	for name := range after {
+		if _, exists := before[name]; !exists {
+			attrs = append(attrs, name)
+		}
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// WriteDriftReport prints a human readable summary of the drift report
+func WriteDriftReport(w io.Writer, report DriftReport) {
+	for _, stack := range report.Stacks {
+		if len(stack.Resources) == 0 {
+			fmt.Fprintf(w, "%s/%s: no drift\n", stack.Level, stack.Stack)
+			continue
+		}
+		fmt.Fprintf(w, "%s/%s: %d drifted resource(s)\n", stack.Level, stack.Stack, len(stack.Resources))
+		for _, res := range stack.Resources {
+			fmt.Fprintf(w, "  - %s (%s)\n", res.Address, strings.Join(res.Actions, ","))
+			if len(res.Attributes) > 0 {
+				fmt.Fprintf(w, "      changed: %s\n", strings.Join(res.Attributes, ", "))
+			}
+		}
+	}
+}
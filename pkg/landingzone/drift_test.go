@@ -0,0 +1,52 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDriftPlan = `{
+  "format_version": "0.2",
+  "resource_drift": [
+    {
+      "address": "module.caf.azurerm_resource_group.rg[\"web\"]",
+      "change": {
+        "actions": ["update"],
+        "before": {"name": "rg-web", "location": "westeurope", "tags": {"env": "dev"}},
+        "after": {"name": "rg-web", "location": "westeurope", "tags": {"env": "dev", "owner": "bob"}}
+      }
+    },
+    {
+      "address": "azurerm_storage_account.deleted",
+      "change": {
+        "actions": ["delete"],
+        "before": {"name": "stdeleted"},
+        "after": null
+      }
+    }
+  ],
+  "resource_changes": []
+}`
+
+func Test_Parse_Drift(t *testing.T) {
+	drifted, err := ParseDrift([]byte(testDriftPlan))
+	assert.NoError(t, err)
+	assert.Len(t, drifted, 2)
+
+	assert.Equal(t, "azurerm_storage_account.deleted", drifted[0].Address)
+	assert.Equal(t, []string{"delete"}, drifted[0].Actions)
+	assert.Empty(t, drifted[0].Attributes)
+
+	assert.Equal(t, `module.caf.azurerm_resource_group.rg["web"]`, drifted[1].Address)
+	assert.Equal(t, []string{"tags"}, drifted[1].Attributes)
+}
+
+func Test_Parse_Drift_No_Changes(t *testing.T) {
+	drifted, err := ParseDrift([]byte(`{"resource_changes": [{"address": "a.b", "change": {"actions": ["no-op"]}}]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, drifted)
+}
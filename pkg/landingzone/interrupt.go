@@ -0,0 +1,181 @@
+//
+// Rover - Interrupt handling
+// * Tracks which stacks terraform is running against, so they can be reported when rover is interrupted
+// * Reports any state which may have been left locked, so it can be released
+// * Actions which run past their timeout are reported the same way, with their own exit code
+//
+
+package landingzone
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/spf13/cobra"
+)
+
+// InterruptExitCode is used when rover stops due to Ctrl-C or SIGTERM, matching the shell convention for SIGINT
+const InterruptExitCode = 130
+
+// TimeoutExitCode is used when an action runs past its timeout, matching the convention of the timeout command
+const TimeoutExitCode = 124
+
+// These actions take a lock on remote state while terraform runs
+var lockingActions = map[string]bool{
+	"plan":    true,
+	"apply":   true,
+	"destroy": true,
+}
+
+// stackRun is an action running against a single stack
+type stackRun struct {
+	action    string
+	level     string
+	stack     string
+	stateName string
+	workspace string
+	// location describes where state is held, it's empty when there is no remote state
+	location string
+}
+
+var runs = struct {
+	sync.Mutex
+	interrupted bool
+	// timedOut is the timeout the running action went past, zero when it hasn't
+	timedOut time.Duration
+	running  map[*Options]stackRun
+	stopped  []stackRun
+}{
+	running: map[*Options]stackRun{},
+}
+
+// SetInterrupted marks the run as interrupted, any stacks running now or later are reported as interrupted
+func SetInterrupted() {
+	runs.Lock()
+	defer runs.Unlock()
+	runs.interrupted = true
+}
+
+// Interrupted returns true once rover has been asked to stop
+func Interrupted() bool {
+	runs.Lock()
+	defer runs.Unlock()
+	return runs.interrupted
+}
+
+// SetTimedOut marks the running action as having gone past its timeout, it's stopped but the run isn't interrupted
+func SetTimedOut(timeout time.Duration) {
+	runs.Lock()
+	defer runs.Unlock()
+	runs.timedOut = timeout
+}
+
+// ClearTimedOut is called once an action has ended, so its timeout isn't held against the next stack
+func ClearTimedOut() {
+	runs.Lock()
+	defer runs.Unlock()
+	runs.timedOut = 0
+	if !runs.interrupted {
+		runs.stopped = nil
+	}
+}
+
+// TimedOut returns the timeout the running action went past, or zero if it hasn't timed out
+func TimedOut() time.Duration {
+	runs.Lock()
+	defer runs.Unlock()
+	return runs.timedOut
+}
+
+// Stopped returns true when rover was interrupted or the running action timed out
+func Stopped() bool {
+	return Interrupted() || TimedOut() > 0
+}
+
+// trackRun records terraform is about to run against the stack in these options
+func (c *TerraformAction) trackRun(o *Options) {
+	runs.Lock()
+	defer runs.Unlock()
+	run := stackRun{
+		action:    c.Name,
+		level:     o.Level,
+		stack:     o.stackName(),
+		stateName: o.StateName,
+		workspace: o.Workspace,
+	}
+	if c.launchPadStorageID != "" {
+		run.location = o.backend().Describe(c.launchPadStorageID)
+	}
+	runs.running[o] = run
+}
+
+// untrackRun records terraform has finished with the stack, if rover was interrupted the stack is kept for the report
+func untrackRun(o *Options) {
+	runs.Lock()
+	defer runs.Unlock()
+	run, ok := runs.running[o]
+	if !ok {
+		return
+	}
+	delete(runs.running, o)
+	if runs.interrupted || runs.timedOut > 0 {
+		runs.stopped = append(runs.stopped, run)
+	}
+}
+
+// ReportInterrupted lists the stacks which were interrupted and any state locks which may need releasing
+func ReportInterrupted() {
+	reportStopped("Rover was interrupted before terraform was run against any stack", "Rover was interrupted, these stacks did not finish:")
+}
+
+// ReportTimedOut lists the stack whose action timed out and any state locks which may need releasing
+func ReportTimedOut() {
+	timeout := TimedOut()
+	reportStopped(fmt.Sprintf("Action timed out after %s, before terraform was run", timeout), fmt.Sprintf("Action timed out after %s, these stacks did not finish:", timeout))
+}
+
+// reportStopped lists the stacks which were stopped before terraform finished and any state locks which may need releasing
+func reportStopped(noneRun string, header string) {
+	runs.Lock()
+	defer runs.Unlock()
+
+	stopped := append([]stackRun{}, runs.stopped...)
+	for _, run := range runs.running {
+		stopped = append(stopped, run)
+	}
+
+	if len(stopped) == 0 {
+		console.Warning(noneRun)
+		return
+	}
+
+	console.Warning(header)
+	for _, run := range stopped {
+		console.Warningf(" - %s/%s (%s, state %s)\n", run.level, run.stack, run.action, run.stateName)
+	}
+
+	for _, run := range stopped {
+		if !lockingActions[run.action] || run.location == "" {
+			continue
+		}
+		console.Warningf("State for %s/%s may still be locked, check the lock on '%s.tfstate' in workspace '%s' of %s\n",
+			run.level, run.stack, run.stateName, run.workspace, run.location)
+	}
+}
+
+// checkErr is used in place of cobra.CheckErr after running terraform
+// When terraform failed because rover was interrupted or the action timed out, the stopped stacks are reported before exiting
+func checkErr(err error) {
+	if err != nil && Interrupted() {
+		ReportInterrupted()
+		os.Exit(InterruptExitCode)
+	}
+	if err != nil && TimedOut() > 0 {
+		ReportTimedOut()
+		os.Exit(TimeoutExitCode)
+	}
+	cobra.CheckErr(err)
+}
@@ -9,16 +9,15 @@ package landingzone
 import (
 	"context"
 	"errors"
-	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/console"
 	"github.com/aztfmod/rover/pkg/terraform"
-	"github.com/aztfmod/rover/pkg/utils"
 	"github.com/aztfmod/rover/pkg/version"
 
 	"github.com/hashicorp/terraform-exec/tfexec"
@@ -30,16 +29,35 @@ const SecretTenantID = "tenant-id"
 const SecretLowerSAName = "lower-storage-account-name"
 const SecretLowerRGName = "lower-resource-group-name"
 
+// secretEnvRegex matches the names of env vars holding secrets, such as ARM_CLIENT_SECRET or ARM_OIDC_REQUEST_TOKEN
+var secretEnvRegex = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|ACCESS_?KEY|SAS)`)
+
+// redactEnv hides the value of a NAME=value env var when it holds a secret, paths & URLs are left as they are
+func redactEnv(env string) string {
+	parts := strings.SplitN(env, "=", 2)
+	name := strings.ToUpper(parts[0])
+	if len(parts) < 2 || parts[1] == "" || !secretEnvRegex.MatchString(name) || strings.HasSuffix(name, "_PATH") || strings.HasSuffix(name, "_URL") {
+		return env
+	}
+	return parts[0] + "=<redacted>"
+}
+
 // Called by all CAF actions to set up Terraform and configure it for CAF landingzones
-func (c *TerraformAction) prepareTerraformCAF(o *Options) (*tfexec.Terraform, error) {
+func (c *TerraformAction) prepareTerraformCAF(ctx context.Context, o *Options) (*tfexec.Terraform, error) {
 
 	err := o.SetupEnvironment()
 	if err != nil {
 		return nil, err
 	}
 
-	// Locate terraform
-	tfPath, err := terraform.Setup()
+	// Locate terraform, checking it matches any pinned version
+	tfPath, tfVer, err := terraform.Setup(o.TerraformVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Share providers between all stacks, rather than downloading them into every data dir
+	err = terraform.SetupPluginCache()
 	if err != nil {
 		return nil, err
 	}
@@ -50,13 +68,30 @@ func (c *TerraformAction) prepareTerraformCAF(o *Options) (*tfexec.Terraform, er
 		return nil, err
 	}
 
+	o.RunMetadata = o.newRunMetadata(c.Name)
+	o.RunMetadata.TerraformPath = tfPath
+	o.RunMetadata.TerraformVersion = tfVer.String()
+
+	// Terraform output is logged to a file and streamed live, unless in quiet mode
+	err = o.startLog(c.Name, tf)
+	if err != nil {
+		return nil, err
+	}
+	// Actions close the log with finishRun once the run is prepared, until then it's closed here on failure
+	prepared := false
+	defer func() {
+		if !prepared {
+			o.closeLog()
+		}
+	}()
+	err = o.writeRunMetadata()
+	if err != nil {
+		return nil, err
+	}
+
 	// The debugging done here
 	if console.DebugEnabled {
-		// By default no output from Terraform is seen, note TF_LOG env var is ignored by tfexec
-		tf.SetStdout(os.Stdout)
-		tf.SetStderr(os.Stderr)
-
-		// This gives us some info level logs we can send to stdout
+		// This gives us some info level logs we can send to stdout, note TF_LOG env var is ignored by tfexec
 		tf.SetLogger(console.Printfer{})
 
 		console.Debug("==== Execution Context ====")
@@ -65,7 +100,7 @@ func (c *TerraformAction) prepareTerraformCAF(o *Options) (*tfexec.Terraform, er
 		console.Debug("==== Environmental Variables ====")
 		for _, env := range os.Environ() {
 			if strings.HasPrefix(env, "ARM_") || strings.HasPrefix(env, "AZURE_") || strings.HasPrefix(env, "TF_") {
-				console.Debugf("   %s\n", env)
+				console.Debugf("   %s\n", redactEnv(env))
 			}
 		}
 	}
@@ -73,27 +108,79 @@ func (c *TerraformAction) prepareTerraformCAF(o *Options) (*tfexec.Terraform, er
 	// Remove old files, reset backend etc
 	o.cleanUp()
 
-	// Find state storage account for this environment and level
-	c.launchPadStorageID, err = azure.FindStorageAccount(o.Level, o.CafEnvironment, o.StateSubscription)
+	// Find where state is held for this environment and level, for azurerm this is the state storage account
+	backend := o.backend()
+	err = o.retry(ctx, "Finding state storage account", graphLagReason(o.Run.LaunchpadDeployed()), func() error {
+		c.launchPadStorageID, err = backend.Locate(ctx, o)
+		return err
+	})
 	if err != nil {
+		// Picking one of several launchpads could put state in the wrong place, even in launchpad mode
+		if errors.Is(err, azure.ErrLaunchpadAmbiguous) {
+			return nil, err
+		}
 		if o.LaunchPadMode {
 			console.Warning("No state storage account found, but running in launchpad mode, we can continue")
 		} else {
-			console.Errorf("No state storage account found for environment '%s' and level %s, please deploy a launchpad first!\n", o.CafEnvironment, o.Level)
+			console.Errorf("No %s state found for environment '%s' and level %s, please deploy a launchpad first!\n", backend.Name(), o.CafEnvironment, o.Level)
 			return nil, errors.New("can't deploy a landing zone without a launchpad")
 		}
 	} else {
-		console.Infof("Located state storage account %s\n", c.launchPadStorageID)
+		console.Infof("Located state in %s\n", backend.Describe(c.launchPadStorageID))
+
+		// Catch state left locked by another run, terraform only fails with a confusing lease error
+		if lockingActions[c.Name] {
+			err = o.checkStateLock(backend, c.launchPadStorageID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// From here terraform may be run against the stack, so it's reported if rover is interrupted
+	c.trackRun(o)
+
+	prepared = true
+	return tf, nil
+}
+
+// Called by actions which only work with local files to set up Terraform, no Azure login is needed
+func (c *TerraformAction) prepareTerraformLocal(o *Options) (*tfexec.Terraform, error) {
+	// Locate terraform, checking it matches any pinned version
+	tfPath, _, err := terraform.Setup(o.TerraformVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := tfexec.NewTerraform(o.SourcePath, tfPath)
+	if err != nil {
+		return nil, err
 	}
+	os.Setenv("TF_DATA_DIR", o.DataDir)
 
+	if console.DebugEnabled {
+		tf.SetLogger(console.Printfer{})
+	}
 	return tf, nil
 }
 
 // SetupEnvironment for all the terraform env vars AND values in options stuct
 func (o *Options) SetupEnvironment() error {
-	// Get current Azure details, subscription etc from CLI
-	acct, err := azure.GetSubscription()
-	cobra.CheckErr(err)
+	_, err := o.loadBackend()
+	if err != nil {
+		return err
+	}
+
+	// Get current Azure details, subscription etc from the signed in credential
+	acct, err := o.cloud().GetSubscription()
+	if err != nil {
+		// Backends such as local can be used in sandboxes with no Azure login
+		if o.backend().NeedsAzure() {
+			cobra.CheckErr(err)
+		}
+		console.Warningf("Not signed in to Azure, continuing as the %s state backend doesn't need it\n", o.backend().Name())
+		acct = &azure.Subscription{}
+	}
 
 	// If they weren't set already, fall back to logged in account subscription
 	if o.StateSubscription == "" {
@@ -111,8 +198,20 @@ func (o *Options) SetupEnvironment() error {
 	}
 
 	// Get the currently signed in identity regardless of type
-	o.Identity = getIdentity(*acct, o.TargetSubscription)
-	console.Successf("Obtained identity successfully.\nWe are signed in as: %s '%s' (%s)\n", o.Identity.ObjectType, o.Identity.DisplayName, o.Identity.ObjectID)
+	if o.signedIn() {
+		o.Identity = getIdentity(o.cloud(), *acct, o.TargetSubscription)
+		console.Successf("Obtained identity successfully.\nWe are signed in as: %s '%s' (%s)\n", o.Identity.ObjectType, o.Identity.DisplayName, o.Identity.ObjectID)
+
+		// Terraform signs in to the same cloud as rover
+		cloud, err := azure.CloudForEnvironment(acct.EnvironmentName)
+		if err != nil {
+			return err
+		}
+		os.Setenv("ARM_ENVIRONMENT", cloud.TerraformName)
+		if cloud.MetadataHost != "" {
+			os.Setenv("ARM_METADATA_HOSTNAME", cloud.MetadataHost)
+		}
+	}
 
 	// Slight hack for now, we set debug on when in dry-run mode
 	if o.DryRun {
@@ -125,6 +224,11 @@ func (o *Options) SetupEnvironment() error {
 		// the AssignedIdentityInfo starts with "MSI" for both system assigned and user assigned
 		if strings.HasPrefix(acct.User.AssignedIdentityInfo, "MSI") {
 			os.Setenv("ARM_USE_MSI", "true")
+		} else if len(acct.TerraformEnv) > 0 {
+			// Rover signed in without the CLI, e.g. with OIDC, Terraform is told to sign in the same way
+			for name, value := range acct.TerraformEnv {
+				os.Setenv(name, value)
+			}
 		} else {
 			// Otherwise were using a old fashioned SP and we need the secret to be set outside of rover
 			if os.Getenv("ARM_CLIENT_SECRET") == "" && os.Getenv("ARM_CLIENT_CERTIFICATE_PATH") == "" {
@@ -151,11 +255,16 @@ func (o *Options) SetupEnvironment() error {
 	return nil
 }
 
+// signedIn is false when running without an Azure login, which is only allowed by some state backends
+func (o *Options) signedIn() bool {
+	return o.Subscription.ID != ""
+}
+
 // Try to get our identity which might be user, managed-identity or service principal
-func getIdentity(acct azure.Subscription, targetSubID string) azure.Identity {
+func getIdentity(cloud azure.Cloud, acct azure.Subscription, targetSubID string) azure.Identity {
 	if strings.EqualFold(acct.User.Usertype, "user") {
 		console.Debug("Detected we are signed in as a user. Attempting to get identity from CLI")
-		ident, err := azure.GetSignedInIdentity()
+		ident, err := cloud.GetSignedInIdentity()
 		cobra.CheckErr(err)
 		return *ident
 
@@ -171,8 +280,8 @@ func getIdentity(acct azure.Subscription, targetSubID string) azure.Identity {
 			vmIdentityID = strings.SplitAfterN(acct.User.AssignedIdentityInfo, "-", 2)[1]
 		}
 
-		metadata := azure.VMInstanceMetadataService()
-		vmIdentities, err := azure.GetVMIdentities(acct.ID, metadata.Compute.ResourceGroupName, metadata.Compute.Name)
+		metadata := cloud.VMInstanceMetadataService()
+		vmIdentities, err := cloud.GetVMIdentities(acct.ID, metadata.Compute.ResourceGroupName, metadata.Compute.Name)
 		cobra.CheckErr(err)
 
 		// look for the vm identity that matches the az login id
@@ -194,7 +303,7 @@ func getIdentity(acct azure.Subscription, targetSubID string) azure.Identity {
 	} else if strings.EqualFold(acct.User.Usertype, "serviceprincipal") {
 		console.Debug("Detected we are signed in as a service principal. Attempting to get identity from the Graph API")
 		// The Azure CLI puts the SP clientid in the name field, which is weird but useful for us
-		identity, err := azure.GetServicePrincipalIdentity(acct.User.Name)
+		identity, err := cloud.GetServicePrincipalIdentity(acct.User.Name)
 		cobra.CheckErr(err)
 		return *identity
 	} else {
@@ -208,66 +317,62 @@ func getIdentity(acct azure.Subscription, targetSubID string) azure.Identity {
 }
 
 // Runs init in the correct mode
-func (c TerraformAction) runTerraformInit(o *Options, tf *tfexec.Terraform, forceLocal bool) {
+func (c TerraformAction) runTerraformInit(ctx context.Context, o *Options, tf *tfexec.Terraform, forceLocal bool) {
 	var err error
 	o.removeStateConfig()
 
 	if (o.LaunchPadMode && c.launchPadStorageID == "") || forceLocal {
-		err = o.runLaunchpadInit(tf, false)
+		err = o.runLaunchpadInit(ctx, tf, false)
 	} else {
-		err = o.runRemoteInit(tf, c.launchPadStorageID)
+		err = o.runRemoteInit(ctx, tf, c.launchPadStorageID)
 	}
-	cobra.CheckErr(err)
+	checkErr(err)
 }
 
 // Carry out Terraform init operation in launchpad mode has no backend state
-func (o *Options) runLaunchpadInit(tf *tfexec.Terraform, reconfigure bool) error {
+func (o *Options) runLaunchpadInit(ctx context.Context, tf *tfexec.Terraform, reconfigure bool) error {
 	console.Info("Running init for launchpad (local state)")
 
 	console.StartSpinner()
 	// Validate that the identity we are using is owner on subscription, not sure why but it's in rover v1 code
-	isOwner, err := azure.CheckIsOwner(o.Identity.ObjectID, o.StateSubscription)
-	cobra.CheckErr(err)
-	if !isOwner {
-		console.StopSpinner()
-		console.Errorf("The identity %s (%s) is not assigned 'Owner' role on subscription %s\n", o.Identity.DisplayName, o.Identity.ObjectID, o.StateSubscription)
-		cobra.CheckErr("To deploy a launchpad the identity used must be assigned the 'Owner' role")
+	if o.signedIn() {
+		isOwner, err := o.cloud().CheckIsOwner(o.Identity.ObjectID, o.StateSubscription)
+		cobra.CheckErr(err)
+		if !isOwner {
+			console.StopSpinner()
+			console.Errorf("The identity %s (%s) is not assigned 'Owner' role on subscription %s\n", o.Identity.DisplayName, o.Identity.ObjectID, o.StateSubscription)
+			cobra.CheckErr("To deploy a launchpad the identity used must be assigned the 'Owner' role")
+		}
 	}
 
 	// Proceed and run tf init
-	err = tf.Init(context.Background(), tfexec.Upgrade(true), tfexec.Reconfigure(reconfigure))
+	err := o.retry(ctx, "Init", transientReason, func() error {
+		return tf.Init(ctx, tfexec.Upgrade(true), tfexec.Reconfigure(reconfigure))
+	})
 	console.StopSpinner()
 	return err
 }
 
 // Carry out Terraform init operation with remote state backend
-func (o *Options) runRemoteInit(tf *tfexec.Terraform, storageID string) error {
+func (o *Options) runRemoteInit(ctx context.Context, tf *tfexec.Terraform, location string) error {
 	console.Info("Running init with remote state")
 
 	// IMPORTANT: This enables remote state in the source terraform dir
-	o.enableAzureBackend()
-
-	subID, resGrp, accountName, err := azure.ParseResourceID(storageID)
+	initOptions, err := o.backend().Configure(o, location)
 	if err != nil {
 		return err
 	}
-	accessKey, err := azure.GetAccountKey(subID, accountName, resGrp)
-	cobra.CheckErr(err)
-
-	initOptions := []tfexec.InitOption{
-		tfexec.BackendConfig(fmt.Sprintf("storage_account_name=%s", accountName)),
-		tfexec.BackendConfig(fmt.Sprintf("container_name=%s", o.Workspace)),
-		tfexec.BackendConfig(fmt.Sprintf("resource_group_name=%s", resGrp)),
-		tfexec.BackendConfig(fmt.Sprintf("access_key=%s", accessKey)),
-		tfexec.BackendConfig(fmt.Sprintf("key=%s", o.StateName+".tfstate")),
+	initOptions = append(initOptions,
 		tfexec.Reconfigure(true),
 		tfexec.Upgrade(true),
 		tfexec.Backend(true),
-	}
+	)
 
 	console.StartSpinner()
-	err = tf.Init(context.Background(), initOptions...)
-	cobra.CheckErr(err)
+	err = o.retry(ctx, "Init", transientReason, func() error {
+		return tf.Init(ctx, initOptions...)
+	})
+	checkErr(err)
 	console.StopSpinner()
 	return err
 }
@@ -280,87 +385,36 @@ func (o *Options) cleanUp() {
 	//_ = os.Remove(o.DataDir + "/terraform.tfstate")
 }
 
+// removeTerraformFiles empties the data dir of terraform's working files once a stack is destroyed
+// The run logs & metadata are kept, so the destroy can still be audited
+func (o *Options) removeTerraformFiles() {
+	entries, err := os.ReadDir(o.DataDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(o.DataDir, entry.Name())
+		if entry.Name() == logDirName || path == o.runMetadataPath() {
+			continue
+		}
+		_ = os.RemoveAll(path)
+	}
+}
+
 // Remove the remote state configuration
 // TODO: This may require future changes please leave the commented out lines
 func (o *Options) removeStateConfig() {
-	_ = os.Remove(o.SourcePath + "/backend.azurerm.tf")
+	o.removeBackendConfig()
 	_ = os.Remove(o.DataDir + "/terraform.tfstate")
 }
 
-// By copying this file we enable teh azurerm backend and therefore remote state
-func (o *Options) enableAzureBackend() {
-	console.Info("Enabling backend state with backend.azurerm.tf file")
-	filepath.Join(o.SourcePath, "backend.azurerm")
-	err := utils.CopyFile(filepath.Join(o.SourcePath, "backend.azurerm"), filepath.Join(o.SourcePath, "backend.azurerm.tf"))
-	cobra.CheckErr(err)
-}
-
 // Sets various TF_VAR_ variables required for a landingzone to be deployed/destroyed
-func (o *Options) connectToLaunchPad(lpStorageID string) error {
+func (o *Options) connectToLaunchPad(lpLocation string) error {
 	console.Infof("Connecting to launchpad for level '%s'\n", o.Level)
-	lpKeyVaultID, err := azure.FindKeyVault(o.Level, o.CafEnvironment, o.StateSubscription)
-	if err != nil {
-		return err
-	}
-	if lpKeyVaultID == "" {
-		return fmt.Errorf("Unable to locate the launchpad for environment '%s' and level '%s'", o.CafEnvironment, o.Level)
-	}
-
-	_, _, keyVaultName, err := azure.ParseResourceID(lpKeyVaultID)
+	err := o.backend().Connect(o, lpLocation)
 	if err != nil {
 		return err
 	}
-
-	endpoint, err := azure.KeyvaultEndpointForSubscription()
-	if err != nil {
-		return err
-	}
-	kvClient, err := azure.NewKVClient(endpoint, keyVaultName)
-	if err != nil {
-		return err
-	}
-
-	lpTenantID, err := kvClient.GetSecret(SecretTenantID)
-	if err != nil {
-		return err
-	}
-	lpLowerSAName, err := kvClient.GetSecret(SecretLowerSAName)
-	if err != nil {
-		return err
-	}
-	lpLowerResGrp, err := kvClient.GetSecret(SecretLowerRGName)
-	if err != nil {
-		return err
-	}
-
-	if lpLowerSAName == "" || lpTenantID == "" || lpLowerResGrp == "" {
-		return fmt.Errorf("Required secret(s) not found in launchpad, either you are not authorized or the launchpad was not deployed correctly")
-	}
-
-	_, lpStorageResGrp, lpStorageName, err := azure.ParseResourceID(lpStorageID)
-	if err != nil {
-		return err
-	}
-
 	console.Success("Connected to launchpad OK")
-	_ = os.Setenv("TF_VAR_tenant_id", lpTenantID)
-	_ = os.Setenv("TF_VAR_tfstate_storage_account_name", lpStorageName)
-	_ = os.Setenv("TF_VAR_tfstate_resource_group_name", lpStorageResGrp)
-	_ = os.Setenv("TF_VAR_lower_storage_account_name", lpLowerSAName)
-	_ = os.Setenv("TF_VAR_lower_resource_group_name", lpLowerResGrp)
-
-	_ = os.Setenv("TF_VAR_tfstate_container_name", o.Workspace)
-	_ = os.Setenv("TF_VAR_lower_container_name", o.Workspace)
-	// NOTE: This will have been set by initializeCAF()
-	//_ = os.Setenv("TF_VAR_tfstate_key", os.Getenv("TF_VAR_tf_name"))
-	_ = os.Setenv("TF_VAR_tfstate_key", fmt.Sprintf("%s.tfstate", o.StateName))
-
-	console.Debugf(" - TF_VAR_tenant_id=%s\n", lpTenantID)
-	console.Debugf(" - TF_VAR_tfstate_storage_account_name=%s\n", lpStorageName)
-	console.Debugf(" - TF_VAR_tfstate_resource_group_name=%s\n", lpStorageResGrp)
-	console.Debugf(" - TF_VAR_lower_storage_account_name=%s\n", lpLowerSAName)
-	console.Debugf(" - TF_VAR_lower_resource_group_name=%s\n", lpLowerResGrp)
-	console.Debugf(" - TF_VAR_tfstate_key=%s\n", os.Getenv("TF_VAR_tfstate_key"))
-
 	return nil
 }
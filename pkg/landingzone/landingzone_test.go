@@ -0,0 +1,116 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Setup_Environment(t *testing.T) {
+	cloud := newTestCloud()
+	o := newTestOptions(cloud, "level1", "web")
+	o.StateSubscription = ""
+
+	assert.Nil(t, o.SetupEnvironment())
+	assert.Equal(t, testSubID, o.StateSubscription)
+	assert.Equal(t, testSubID, o.TargetSubscription)
+	assert.Equal(t, "object-1", o.Identity.ObjectID)
+	assert.Equal(t, testSubID, os.Getenv("ARM_SUBSCRIPTION_ID"))
+	assert.Equal(t, "tenant-1", os.Getenv("TF_VAR_tenant_id"))
+	assert.Equal(t, "object-1", os.Getenv("TF_VAR_logged_user_objectId"))
+	assert.Equal(t, "public", os.Getenv("ARM_ENVIRONMENT"))
+
+	// The local backend can run without an Azure login
+	cloud.Subscription = azure.Subscription{}
+	o = newTestOptions(cloud, "level1", "web")
+	o.StateBackend = StateBackendConfig{Type: BackendLocal, Path: t.TempDir()}
+	assert.Nil(t, o.SetupEnvironment())
+	assert.False(t, o.signedIn())
+}
+
+func Test_Setup_Environment_Service_Principal(t *testing.T) {
+	for _, name := range []string{"ARM_CLIENT_SECRET", "ARM_CLIENT_CERTIFICATE_PATH", "ARM_USE_OIDC", "ARM_OIDC_TOKEN"} {
+		saved := os.Getenv(name)
+		os.Unsetenv(name)
+		defer os.Setenv(name, saved)
+	}
+	cloud := newTestCloud()
+	cloud.Subscription.User = azure.AccountUser{Name: "client-1", Usertype: "servicePrincipal"}
+	cloud.ServicePrincipals["client-1"] = azure.Identity{DisplayName: "pipeline", ObjectID: "object-2", ObjectType: "ServicePrincipal", ClientID: "client-1"}
+
+	// Terraform would have no way to sign in
+	o := newTestOptions(cloud, "level1", "web")
+	assert.NotNil(t, o.SetupEnvironment())
+
+	// Signed in with OIDC, Terraform is told to do the same
+	cloud.Subscription.Credential = azure.CredentialWorkloadIdentity
+	cloud.Subscription.TerraformEnv = map[string]string{"ARM_USE_OIDC": "true", "ARM_OIDC_TOKEN": "token"}
+	o = newTestOptions(cloud, "level1", "web")
+	assert.Nil(t, o.SetupEnvironment())
+	assert.Equal(t, "client-1", os.Getenv("ARM_CLIENT_ID"))
+	assert.Equal(t, "true", os.Getenv("ARM_USE_OIDC"))
+	assert.Equal(t, "token", os.Getenv("ARM_OIDC_TOKEN"))
+}
+
+func Test_Get_Identity(t *testing.T) {
+	cloud := newTestCloud()
+	cloud.ServicePrincipals["client-1"] = azure.Identity{DisplayName: "pipeline", ObjectID: "object-2", ObjectType: "ServicePrincipal", ClientID: "client-1"}
+	identity := getIdentity(cloud, azure.Subscription{ID: testSubID, User: azure.AccountUser{Name: "client-1", Usertype: "servicePrincipal"}}, testSubID)
+	assert.Equal(t, "object-2", identity.ObjectID)
+
+	cloud.VM = &azure.Metadata{Compute: azure.Compute{Name: "runner", ResourceGroupName: "rg-runners"}}
+	cloud.VMIdentities = []azure.Identity{
+		{DisplayName: "SystemAssigned", ObjectID: "object-3"},
+		{DisplayName: "uami", ObjectID: "object-4", ClientID: "client-4"},
+	}
+	identity = getIdentity(cloud, azure.Subscription{ID: testSubID, User: azure.AccountUser{AssignedIdentityInfo: "MSI"}}, testSubID)
+	assert.Equal(t, "object-3", identity.ObjectID)
+	identity = getIdentity(cloud, azure.Subscription{ID: testSubID, User: azure.AccountUser{AssignedIdentityInfo: "MSIClient-client-4"}}, testSubID)
+	assert.Equal(t, "object-4", identity.ObjectID)
+}
+
+func Test_Redact_Env(t *testing.T) {
+	assert.Equal(t, "ARM_CLIENT_SECRET=<redacted>", redactEnv("ARM_CLIENT_SECRET=abc=123"))
+	assert.Equal(t, "ARM_OIDC_TOKEN=<redacted>", redactEnv("ARM_OIDC_TOKEN=eyJ0eXAi"))
+	assert.Equal(t, "ARM_OIDC_REQUEST_TOKEN=<redacted>", redactEnv("ARM_OIDC_REQUEST_TOKEN=xyz"))
+	assert.Equal(t, "ARM_CLIENT_CERTIFICATE_PASSWORD=<redacted>", redactEnv("ARM_CLIENT_CERTIFICATE_PASSWORD=pw"))
+	assert.Equal(t, "ARM_ACCESS_KEY=<redacted>", redactEnv("ARM_ACCESS_KEY=key"))
+	assert.Equal(t, "ARM_OIDC_TOKEN_FILE_PATH=/var/run/token", redactEnv("ARM_OIDC_TOKEN_FILE_PATH=/var/run/token"))
+	assert.Equal(t, "ARM_CLIENT_ID=1234", redactEnv("ARM_CLIENT_ID=1234"))
+	assert.Equal(t, "ARM_CLIENT_SECRET=", redactEnv("ARM_CLIENT_SECRET="))
+}
+
+func Test_Run_Log_Per_Stack(t *testing.T) {
+	execPath := filepath.Join(t.TempDir(), "terraform")
+	assert.Nil(t, os.WriteFile(execPath, []byte{}, 0755))
+	tf, err := tfexec.NewTerraform(t.TempDir(), execPath)
+	assert.Nil(t, err)
+
+	// Stacks run by the same action each get their own log
+	web := newTestOptions(newTestCloud(), "level1", "web")
+	web.DataDir = t.TempDir()
+	app := newTestOptions(newTestCloud(), "level1", "app")
+	app.DataDir = t.TempDir()
+	assert.Nil(t, web.startLog("plan", tf))
+	assert.Nil(t, app.startLog("plan", tf))
+	assert.NotEqual(t, web.RunMetadata.LogFile, app.RunMetadata.LogFile)
+
+	_, err = web.log.stdout.Write([]byte("web output\n"))
+	assert.Nil(t, err)
+	web.closeLog()
+	assert.Nil(t, web.log)
+	_, err = app.log.stdout.Write([]byte("app output\n"))
+	assert.Nil(t, err)
+	app.closeLog()
+
+	logged, err := os.ReadFile(web.RunMetadata.LogFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "web output\n", string(logged))
+}
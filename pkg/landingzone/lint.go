@@ -0,0 +1,224 @@
+//
+// Rover - Config linting
+// * Checks the tfvars in a configuration directory against the variables declared by the landingzone
+// * Catches misspelled keys and type mismatches before terraform is run, no Azure access is needed
+//
+
+package landingzone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aztfmod/rover/pkg/terraform"
+	"github.com/aztfmod/rover/pkg/utils"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// These variables are set by rover as TF_VAR_ environment variables, so never need a value in tfvars
+var roverVariables = []string{
+	"tfstate_key", "tfstate_subscription_id", "tf_name", "tf_plan", "workspace", "level", "environment",
+	"rover_version", "tenant_id", "user_type", "logged_user_objectId",
+	"tfstate_storage_account_name", "tfstate_resource_group_name", "tfstate_container_name",
+	"lower_storage_account_name", "lower_resource_group_name", "lower_container_name",
+	"backend_type", "tfstate_path", "lower_tfstate_path",
+}
+
+// How far a misspelled variable name can be from a declared one to be suggested
+const variableSuggestDistance = 3
+
+var variableBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "type"}, {Name: "default"}},
+}
+
+// variableDecl is a variable declared by the landingzone
+type variableDecl struct {
+	name     string
+	varType  cty.Type
+	required bool
+	declRng  hcl.Range
+}
+
+// LintConfig checks the tfvars in the config directory against the variables declared in the landingzone source
+// It reports unknown variables, type mismatches and required variables with no value
+func (o *Options) LintConfig() ([]Diagnostic, error) {
+	parser := hclparse.NewParser()
+
+	sourceFiles, err := filepath.Glob(filepath.Join(o.SourcePath, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	varFiles, err := terraform.FindVarFiles(o.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	decls, diags := loadVariables(parser, sourceFiles)
+	if len(decls) == 0 && !diags.HasErrors() {
+		return nil, fmt.Errorf("no variables are declared in landingzone source %s", o.SourcePath)
+	}
+	diags = append(diags, checkVarFiles(parser, decls, varFiles)...)
+
+	diagnostics := []Diagnostic{}
+	for _, diag := range diags {
+		d := Diagnostic{
+			Level:    o.Level,
+			Stack:    o.stackName(),
+			Severity: "error",
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+		if diag.Severity == hcl.DiagWarning {
+			d.Severity = "warning"
+		}
+		if diag.Subject != nil {
+			d.Filename = repoPath(diag.Subject.Filename)
+			d.Line = diag.Subject.Start.Line
+			d.Column = diag.Subject.Start.Column
+			d.EndLine = diag.Subject.End.Line
+			d.EndColumn = diag.Subject.End.Column
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics, nil
+}
+
+// loadVariables reads the variable blocks from the landingzone .tf files
+func loadVariables(parser *hclparse.Parser, sourceFiles []string) (map[string]variableDecl, hcl.Diagnostics) {
+	decls := map[string]variableDecl{}
+	diags := hcl.Diagnostics{}
+
+	for _, sourceFile := range sourceFiles {
+		file, fileDiags := parser.ParseHCLFile(sourceFile)
+		diags = append(diags, fileDiags...)
+		if file == nil {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(variableBlockSchema)
+		for _, block := range content.Blocks {
+			decl := variableDecl{
+				name:     block.Labels[0],
+				varType:  cty.DynamicPseudoType,
+				required: true,
+				declRng:  block.DefRange,
+			}
+			attrs, _, _ := block.Body.PartialContent(variableSchema)
+			if typeAttr, ok := attrs.Attributes["type"]; ok {
+				// Types the parser doesn't understand, e.g. optional attributes, are not checked
+				varType, typeDiags := typeexpr.TypeConstraint(typeAttr.Expr)
+				if !typeDiags.HasErrors() {
+					decl.varType = varType
+				}
+			}
+			if _, ok := attrs.Attributes["default"]; ok {
+				decl.required = false
+			}
+			decls[decl.name] = decl
+		}
+	}
+	return decls, diags
+}
+
+// checkVarFiles checks every value in the tfvars files is for a declared variable and is of the right type
+// then checks every required variable has been given a value
+func checkVarFiles(parser *hclparse.Parser, decls map[string]variableDecl, varFiles []string) hcl.Diagnostics {
+	diags := hcl.Diagnostics{}
+	declNames := []string{}
+	for name := range decls {
+		declNames = append(declNames, name)
+	}
+	sort.Strings(declNames)
+
+	assigned := map[string]bool{}
+	for _, varFile := range varFiles {
+		file, fileDiags := parser.ParseHCLFile(varFile)
+		diags = append(diags, fileDiags...)
+		if file == nil {
+			continue
+		}
+		attrs, attrDiags := file.Body.JustAttributes()
+		diags = append(diags, attrDiags...)
+
+		for _, name := range sortedAttributeNames(attrs) {
+			attr := attrs[name]
+			assigned[name] = true
+
+			decl, ok := decls[name]
+			if !ok {
+				detail := fmt.Sprintf("The landingzone does not declare a variable named \"%s\".", name)
+				if matches := utils.ClosestMatches(name, declNames, variableSuggestDistance); len(matches) > 0 {
+					detail += fmt.Sprintf(" Did you mean \"%s\"?", matches[0])
+				}
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Value for undeclared variable",
+					Detail:   detail,
+					Subject:  attr.NameRange.Ptr(),
+				})
+				continue
+			}
+
+			value, valueDiags := attr.Expr.Value(nil)
+			diags = append(diags, valueDiags...)
+			if valueDiags.HasErrors() {
+				continue
+			}
+			_, err := convert.Convert(value, decl.varType)
+			if err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid value for variable",
+					Detail:   fmt.Sprintf("The value for \"%s\" does not match its type %s: %s.", name, typeexpr.TypeString(decl.varType), err),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			}
+		}
+	}
+
+	for _, name := range declNames {
+		decl := decls[name]
+		if !decl.required || assigned[name] || isProvidedByEnv(name) {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No value for required variable",
+			Detail:   fmt.Sprintf("The variable \"%s\" has no default, so a value must be set in the tfvars files in the configuration directory.", name),
+			Subject:  decl.declRng.Ptr(),
+		})
+	}
+	return diags
+}
+
+// isProvidedByEnv returns true if rover or the environment will give the variable a value
+func isProvidedByEnv(name string) bool {
+	for _, roverVar := range roverVariables {
+		if name == roverVar {
+			return true
+		}
+	}
+	_, ok := os.LookupEnv("TF_VAR_" + name)
+	return ok
+}
+
+func sortedAttributeNames(attrs hcl.Attributes) []string {
+	names := []string{}
+	for name := range attrs {
+		names = append(names, name)
+	}
+	// Report in the order they appear in the file
+	sort.Slice(names, func(i, j int) bool {
+		return attrs[names[i]].NameRange.Start.Byte < attrs[names[j]].NameRange.Start.Byte
+	})
+	return names
+}
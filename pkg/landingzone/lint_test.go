@@ -0,0 +1,94 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testVariablesTf = `
+variable "global_settings" {
+  default = {}
+}
+variable "resource_groups" {
+  type = map(object({
+    name     = string
+    location = string
+  }))
+}
+variable "vnets" {
+  default = {}
+}
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+variable "landingzone" {}
+variable "tfstate_key" {}
+`
+
+const testTfvars = `landingzone = {
+  backend_type = "azurerm"
+}
+
+resource_groups = {
+  rg1 = {
+    name     = "web"
+    location = ["westeurope"]
+  }
+}
+
+vnet = {}
+
+tags = "production"
+`
+
+func Test_Lint_Config(t *testing.T) {
+	sourceDir := t.TempDir()
+	configDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(sourceDir, "variables.tf"), []byte(testVariablesTf), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(configDir, "config.tfvars"), []byte(testTfvars), 0644))
+
+	o := Options{Level: "level1", Stack: "web", SourcePath: sourceDir, ConfigPath: configDir}
+	diagnostics, err := o.LintConfig()
+	assert.Nil(t, err)
+	assert.Len(t, diagnostics, 3)
+
+	// resource_groups has the wrong type for location
+	assert.Equal(t, "Invalid value for variable", diagnostics[0].Summary)
+	assert.Contains(t, diagnostics[0].Detail, `element "rg1": attribute "location": string required`)
+	assert.Equal(t, 5, diagnostics[0].Line)
+
+	// vnet is misspelled
+	assert.Equal(t, "Value for undeclared variable", diagnostics[1].Summary)
+	assert.Contains(t, diagnostics[1].Detail, `Did you mean "vnets"?`)
+	assert.Equal(t, 12, diagnostics[1].Line)
+	assert.Equal(t, 1, diagnostics[1].Column)
+
+	// tags must be a map
+	assert.Equal(t, "Invalid value for variable", diagnostics[2].Summary)
+	assert.Equal(t, 14, diagnostics[2].Line)
+	assert.Equal(t, "error", diagnostics[2].Severity)
+}
+
+func Test_Lint_Config_Required(t *testing.T) {
+	sourceDir := t.TempDir()
+	configDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(sourceDir, "variables.tf"), []byte(testVariablesTf), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(configDir, "config.tfvars"), []byte("landingzone = {}\n"), 0644))
+
+	o := Options{Level: "level1", SourcePath: sourceDir, ConfigPath: configDir}
+	diagnostics, err := o.LintConfig()
+	assert.Nil(t, err)
+
+	// tfstate_key is set by rover so it isn't reported
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "No value for required variable", diagnostics[0].Summary)
+	assert.Contains(t, diagnostics[0].Detail, `"resource_groups"`)
+	assert.Equal(t, 5, diagnostics[0].Line)
+}
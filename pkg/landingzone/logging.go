@@ -0,0 +1,78 @@
+//
+// Rover - Terraform output logging
+// * All terraform output is written to a timestamped log file in the data dir
+// * Unless in quiet mode it is also streamed live, with each line prefixed by the level & stack
+//
+
+package landingzone
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+const logDirName = "logs"
+
+// runLog is where terraform output goes for one stack, it's kept on the options so stacks never share one
+type runLog struct {
+	file    *os.File
+	stdout  io.Writer
+	stderr  io.Writer
+	streams []*console.PrefixWriter
+}
+
+// startLog sets up the log file and live output for a terraform run
+func (o *Options) startLog(action string, tf *tfexec.Terraform) error {
+	logDir := filepath.Join(o.DataDir, logDirName)
+	err := os.MkdirAll(logDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", action, time.Now().UTC().Format("20060102T150405Z")))
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	o.RunMetadata.LogFile = logPath
+
+	log := &runLog{file: logFile, stdout: logFile, stderr: logFile}
+	if !console.Quiet {
+		prefix := fmt.Sprintf("[%s/%s] ", o.Level, o.stackName())
+		outStream := console.NewPrefixWriter(prefix, console.Output)
+		errStream := console.NewPrefixWriter(prefix, console.Output)
+		log.streams = []*console.PrefixWriter{outStream, errStream}
+		log.stdout = io.MultiWriter(logFile, outStream)
+		log.stderr = io.MultiWriter(logFile, errStream)
+	}
+	o.log = log
+
+	tf.SetStdout(log.stdout)
+	tf.SetStderr(log.stderr)
+	console.Infof("Terraform output is logged to %s\n", logPath)
+	return nil
+}
+
+// finishRun is deferred by every action once prepareTerraformCAF has succeeded
+func (c *TerraformAction) finishRun(o *Options) {
+	untrackRun(o)
+	o.closeLog()
+}
+
+// closeLog flushes any partial lines of live output and closes the log file
+func (o *Options) closeLog() {
+	if o.log == nil {
+		return
+	}
+	for _, stream := range o.log.streams {
+		_ = stream.Flush()
+	}
+	_ = o.log.file.Close()
+	o.log = nil
+}
@@ -0,0 +1,59 @@
+//
+// Rover - Run metadata
+// * Records how an action was run against a stack, written alongside the plan & state in the data dir
+//
+
+package landingzone
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/version"
+)
+
+// RunMetadata holds details of the last action run against a stack
+type RunMetadata struct {
+	RoverVersion     string    `json:"roverVersion"`
+	Action           string    `json:"action"`
+	Level            string    `json:"level"`
+	Stack            string    `json:"stack,omitempty"`
+	StateName        string    `json:"stateName"`
+	Workspace        string    `json:"workspace"`
+	Environment      string    `json:"environment"`
+	StartTime        time.Time `json:"startTime"`
+	TerraformPath    string    `json:"terraformPath"`
+	TerraformVersion string    `json:"terraformVersion"`
+	LogFile          string    `json:"logFile"`
+	Overrides        []string  `json:"overrides,omitempty"`
+}
+
+// newRunMetadata starts the metadata for an action about to run with these options
+func (o *Options) newRunMetadata(action string) RunMetadata {
+	return RunMetadata{
+		RoverVersion: version.Value,
+		Action:       action,
+		Level:        o.Level,
+		Stack:        o.Stack,
+		StateName:    o.StateName,
+		Workspace:    o.Workspace,
+		Environment:  o.CafEnvironment,
+		StartTime:    time.Now().UTC(),
+		Overrides:    o.Overrides.Args(),
+	}
+}
+
+// writeRunMetadata saves the metadata as <statename>.run.json in the data dir
+func (o *Options) writeRunMetadata() error {
+	metaJSON, err := json.MarshalIndent(o.RunMetadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(o.runMetadataPath(), metaJSON, 0644)
+}
+
+func (o *Options) runMetadataPath() string {
+	return filepath.Join(o.DataDir, o.StateName+".run.json")
+}
@@ -13,6 +13,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aztfmod/rover/pkg/azure"
 	"github.com/aztfmod/rover/pkg/console"
@@ -37,8 +38,24 @@ type Options struct {
 	Impersonate        bool
 	DataDir            string
 	DryRun             bool
-	Subscription       azure.Subscription
-	Identity           azure.Identity
+	TerraformVersion   string
+	Timeout            time.Duration
+	Timeouts           map[string]string
+	Retry              *rover.RetryConfig
+	Snapshots          *rover.SnapshotConfig
+	Overrides          VarOverrides
+	StateBackend       StateBackendConfig
+	// Cloud is how Azure is accessed, a Session shared by all the stacks in a run, or the Azure SDK uncached when nil
+	Cloud        azure.Cloud
+	Subscription azure.Subscription
+	Identity     azure.Identity
+	RunMetadata  RunMetadata
+	// Run is state shared by all the stacks in a run, it can be nil when there's only one
+	Run *RunState
+	// stateBackend is built from StateBackend once, see loadBackend
+	stateBackend StateBackend
+	// log is where terraform output for this stack goes, set up by startLog
+	log *runLog
 }
 
 const cafLaunchPadDir = "/caf_launchpad"
@@ -121,6 +138,14 @@ func (o *Options) SetDataDir() error {
 	return nil
 }
 
+// stackName is used to label output, falling back to the state name when not running from a symphony config
+func (o *Options) stackName() string {
+	if o.Stack != "" {
+		return o.Stack
+	}
+	return o.StateName
+}
+
 func (o *Options) Debug() {
 	if !console.DebugEnabled {
 		return
@@ -0,0 +1,159 @@
+//
+// Rover - Landing zone output formatting
+// * Renders outputs read from remote state as table, JSON, dotenv or tfvars
+//
+
+package landingzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	OutputFormatTable  = "table"
+	OutputFormatJSON   = "json"
+	OutputFormatDotenv = "dotenv"
+	OutputFormatTfvars = "tfvars"
+)
+
+// OutputFormats lists all the supported formats for WriteOutputs
+var OutputFormats = []string{OutputFormatTable, OutputFormatJSON, OutputFormatDotenv, OutputFormatTfvars}
+
+const sensitiveMask = "(sensitive)"
+
+var nonEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// WriteOutputs renders the outputs of one or more stacks in the requested format
+// Sensitive outputs are masked unless showSensitive is true
+func WriteOutputs(w io.Writer, results []StackOutputs, format string, showSensitive bool) error {
+	switch format {
+	case OutputFormatTable:
+		return writeOutputsTable(w, results, showSensitive)
+	case OutputFormatJSON:
+		return writeOutputsJSON(w, results, showSensitive)
+	case OutputFormatDotenv:
+		return writeOutputsDotenv(w, results, showSensitive)
+	case OutputFormatTfvars:
+		return writeOutputsTfvars(w, results, showSensitive)
+	}
+	return fmt.Errorf("unsupported output format '%s', must be one of: %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// CheckOutputFormat fails for formats which can't hold the outputs of the number of stacks, checked before reading them
+func CheckOutputFormat(format string, stacks int) error {
+	if format == OutputFormatTfvars && stacks > 1 {
+		return fmt.Errorf("the %s format can only be used with a single stack, as the stacks' outputs would set the same variables, choose one with --level and --stack", OutputFormatTfvars)
+	}
+	return nil
+}
+
+func writeOutputsTable(w io.Writer, results []StackOutputs, showSensitive bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LEVEL\tSTACK\tNAME\tVALUE")
+	for _, res := range results {
+		for _, name := range sortedOutputNames(res) {
+			output := res.Outputs[name]
+			value := sensitiveMask
+			if !output.Sensitive || showSensitive {
+				value = plainValue(output.Value)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Level, res.Stack, name, value)
+		}
+	}
+	return tw.Flush()
+}
+
+// Stacks are keyed by "level/stack", even when there's only one, so the shape doesn't depend on the stacks read
+func writeOutputsJSON(w io.Writer, results []StackOutputs, showSensitive bool) error {
+	stackValues := func(res StackOutputs) map[string]json.RawMessage {
+		values := map[string]json.RawMessage{}
+		for name, output := range res.Outputs {
+			if output.Sensitive && !showSensitive {
+				values[name] = json.RawMessage(`"` + sensitiveMask + `"`)
+				continue
+			}
+			values[name] = output.Value
+		}
+		return values
+	}
+
+	doc := map[string]map[string]json.RawMessage{}
+	for _, res := range results {
+		doc[res.Level+"/"+res.Stack] = stackValues(res)
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+func writeOutputsDotenv(w io.Writer, results []StackOutputs, showSensitive bool) error {
+	for _, res := range results {
+		fmt.Fprintf(w, "# %s/%s\n", res.Level, res.Stack)
+		for _, name := range sortedOutputNames(res) {
+			output := res.Outputs[name]
+			key := nonEnvChars.ReplaceAllString(strings.ToUpper(name), "_")
+			if output.Sensitive && !showSensitive {
+				fmt.Fprintf(w, "# %s=%s\n", key, sensitiveMask)
+				continue
+			}
+			// Single quotes stop shells and dotenv loaders expanding anything inside complex values
+			value := strings.ReplaceAll(plainValue(output.Value), "'", `'\''`)
+			fmt.Fprintf(w, "%s='%s'\n", key, value)
+		}
+	}
+	return nil
+}
+
+// JSON values are valid HCL expressions, so the raw output values can be written directly
+// Output names become variable names, stacks often share them, so only a single stack can be written
+func writeOutputsTfvars(w io.Writer, results []StackOutputs, showSensitive bool) error {
+	err := CheckOutputFormat(OutputFormatTfvars, len(results))
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		fmt.Fprintf(w, "# %s/%s\n", res.Level, res.Stack)
+		for _, name := range sortedOutputNames(res) {
+			output := res.Outputs[name]
+			if output.Sensitive && !showSensitive {
+				fmt.Fprintf(w, "# %s = %s\n", name, sensitiveMask)
+				continue
+			}
+			fmt.Fprintf(w, "%s = %s\n", name, string(output.Value))
+		}
+	}
+	return nil
+}
+
+// plainValue returns strings without quotes and everything else as compact JSON
+func plainValue(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	compact := bytes.Buffer{}
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+func sortedOutputNames(res StackOutputs) []string {
+	names := []string{}
+	for name := range res.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStackOutputs() []StackOutputs {
+	return []StackOutputs{
+		{
+			Level: "level1",
+			Stack: "web",
+			Outputs: map[string]tfexec.OutputMeta{
+				"vnet_id":  {Value: json.RawMessage(`"/subscriptions/123/vnet"`)},
+				"kv_uris":  {Value: json.RawMessage(`{"main": "https://kv.vault.azure.net/"}`)},
+				"password": {Sensitive: true, Value: json.RawMessage(`"hunter2"`)},
+			},
+		},
+	}
+}
+
+func Test_Output_JSON_Masks_Sensitive(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := WriteOutputs(&buf, testStackOutputs(), OutputFormatJSON, false)
+	assert.NoError(t, err)
+
+	values := map[string]map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &values))
+	assert.Equal(t, "/subscriptions/123/vnet", values["level1/web"]["vnet_id"])
+	assert.Equal(t, "(sensitive)", values["level1/web"]["password"])
+}
+
+func Test_Output_JSON_Multiple_Stacks(t *testing.T) {
+	results := append(testStackOutputs(), StackOutputs{Level: "level2", Stack: "aks", Outputs: map[string]tfexec.OutputMeta{}})
+	buf := bytes.Buffer{}
+	err := WriteOutputs(&buf, results, OutputFormatJSON, true)
+	assert.NoError(t, err)
+
+	values := map[string]map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &values))
+	assert.Equal(t, "hunter2", values["level1/web"]["password"])
+	assert.Contains(t, values, "level2/aks")
+}
+
+func Test_Output_Dotenv(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := WriteOutputs(&buf, testStackOutputs(), OutputFormatDotenv, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `# level1/web
+KV_URIS='{"main":"https://kv.vault.azure.net/"}'
+# PASSWORD=(sensitive)
+VNET_ID='/subscriptions/123/vnet'
+`, buf.String())
+}
+
+func Test_Output_Tfvars(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := WriteOutputs(&buf, testStackOutputs(), OutputFormatTfvars, true)
+	assert.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `vnet_id = "/subscriptions/123/vnet"`)
+	assert.Contains(t, buf.String(), `password = "hunter2"`)
+
+	// Stacks' outputs would set the same variables
+	results := append(testStackOutputs(), testStackOutputs()[0])
+	results[1].Level = "level2"
+	assert.Error(t, WriteOutputs(&bytes.Buffer{}, results, OutputFormatTfvars, true))
+}
+
+func Test_Output_Bad_Format(t *testing.T) {
+	err := WriteOutputs(&bytes.Buffer{}, testStackOutputs(), "xml", false)
+	assert.Error(t, err)
+}
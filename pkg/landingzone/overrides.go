@@ -0,0 +1,202 @@
+//
+// Rover - Variable overrides
+// * Ad-hoc --var and --var-file values given on the command line, applied after the tfvars in the config directory
+// * Recorded in the plan fingerprint & run metadata so they are visible when a plan is reviewed
+//
+
+package landingzone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/terraform"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// Terraform variable names, the part of a --var before the =
+var varNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// VarOverrides holds variables given on the command line rather than in the config directory
+// Var files are passed to terraform before vars, so a --var always wins over a value in a --var-file
+type VarOverrides struct {
+	// Vars are in the form key=value
+	Vars []string
+	// VarFiles are absolute paths to tfvars files
+	VarFiles []string
+}
+
+// ParseVarOverrides checks the --var and --var-file flag values, making the var file paths absolute
+func ParseVarOverrides(vars []string, varFiles []string) (VarOverrides, error) {
+	overrides := VarOverrides{}
+	for _, v := range vars {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || !varNameRegex.MatchString(parts[0]) {
+			return overrides, fmt.Errorf("invalid --var '%s', it must be in the form key=value", v)
+		}
+		overrides.Vars = append(overrides.Vars, v)
+	}
+
+	for _, varFile := range varFiles {
+		absPath, err := filepath.Abs(varFile)
+		if err != nil {
+			return overrides, err
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return overrides, fmt.Errorf("invalid --var-file: %s", err)
+		}
+		if info.IsDir() {
+			return overrides, fmt.Errorf("invalid --var-file '%s', it is a directory", varFile)
+		}
+		overrides.VarFiles = append(overrides.VarFiles, absPath)
+	}
+	return overrides, nil
+}
+
+// IsEmpty returns true when no overrides were given
+func (v VarOverrides) IsEmpty() bool {
+	return len(v.Vars) == 0 && len(v.VarFiles) == 0
+}
+
+// Args returns the overrides as terraform command line args
+func (v VarOverrides) Args() []string {
+	args := []string{}
+	for _, varFile := range v.VarFiles {
+		args = append(args, "-var-file="+varFile)
+	}
+	for _, kv := range v.Vars {
+		args = append(args, "-var="+kv)
+	}
+	return args
+}
+
+func (v VarOverrides) planOptions() []tfexec.PlanOption {
+	opts := []tfexec.PlanOption{}
+	for _, varFile := range v.VarFiles {
+		opts = append(opts, tfexec.VarFile(varFile))
+	}
+	for _, kv := range v.Vars {
+		opts = append(opts, tfexec.Var(kv))
+	}
+	return opts
+}
+
+func (v VarOverrides) destroyOptions() []tfexec.DestroyOption {
+	opts := []tfexec.DestroyOption{}
+	for _, varFile := range v.VarFiles {
+		opts = append(opts, tfexec.VarFile(varFile))
+	}
+	for _, kv := range v.Vars {
+		opts = append(opts, tfexec.Var(kv))
+	}
+	return opts
+}
+
+// reportOverrides lists the overrides as part of the summary at the end of an action
+func (o *Options) reportOverrides() {
+	if o.Overrides.IsEmpty() {
+		return
+	}
+	console.Warning("Variable overrides were given on the command line:")
+	for _, arg := range o.Overrides.Args() {
+		console.Warningf("  %s\n", arg)
+	}
+}
+
+// PlanFingerprint identifies a saved plan and records the variables it was created with
+type PlanFingerprint struct {
+	PlanFile  string    `json:"planFile"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+	VarFiles  []string  `json:"varFiles"`
+	Overrides []string  `json:"overrides,omitempty"`
+}
+
+func fingerprintPath(planFile string) string {
+	return planFile + ".fingerprint.json"
+}
+
+// writePlanFingerprint saves the fingerprint alongside the plan file
+func (o *Options) writePlanFingerprint(planFile string) error {
+	hash, err := fileSHA256(planFile)
+	if err != nil {
+		return err
+	}
+	varFiles, err := terraform.FindVarFiles(o.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := PlanFingerprint{
+		PlanFile:  planFile,
+		SHA256:    hash,
+		CreatedAt: time.Now().UTC(),
+		VarFiles:  varFiles,
+		Overrides: o.Overrides.Args(),
+	}
+	fingerprintJSON, err := json.MarshalIndent(fingerprint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fingerprintPath(planFile), fingerprintJSON, 0644)
+}
+
+// readPlanFingerprint loads the fingerprint for a plan file, if there is one
+func readPlanFingerprint(planFile string) (*PlanFingerprint, error) {
+	fingerprintJSON, err := os.ReadFile(fingerprintPath(planFile))
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := &PlanFingerprint{}
+	err = json.Unmarshal(fingerprintJSON, fingerprint)
+	return fingerprint, err
+}
+
+// checkPlanFingerprint refuses a plan which doesn't match its fingerprint, or was created with different overrides
+// A saved plan can't be given variables, so overrides given to apply must be the ones the plan was created with
+func (o *Options) checkPlanFingerprint(planFile string) (*PlanFingerprint, error) {
+	fingerprint, err := readPlanFingerprint(planFile)
+	if err != nil {
+		return nil, fmt.Errorf("no fingerprint found for plan %s, run plan again: %s", planFile, err)
+	}
+	hash, err := fileSHA256(planFile)
+	if err != nil {
+		return nil, err
+	}
+	if hash != fingerprint.SHA256 {
+		return nil, fmt.Errorf("plan %s has changed since it was created, its SHA256 doesn't match the fingerprint, run plan again", planFile)
+	}
+
+	if o.Overrides.IsEmpty() || strings.Join(o.Overrides.Args(), "\n") == strings.Join(fingerprint.Overrides, "\n") {
+		return fingerprint, nil
+	}
+	planned := "none"
+	if len(fingerprint.Overrides) > 0 {
+		planned = strings.Join(fingerprint.Overrides, " ")
+	}
+	return nil, fmt.Errorf("variable overrides %s don't match the ones plan %s was created with (%s), run plan again with them", strings.Join(o.Overrides.Args(), " "), planFile, planned)
+}
+
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,95 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse_Var_Overrides(t *testing.T) {
+	varFile := filepath.Join(t.TempDir(), "override.tfvars")
+	err := os.WriteFile(varFile, []byte("location = \"uksouth\"\n"), 0644)
+	assert.Nil(t, err)
+
+	overrides, err := ParseVarOverrides([]string{"location=westeurope", "tags={env=\"dev\"}", "empty="}, []string{varFile})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"-var-file=" + varFile,
+		"-var=location=westeurope",
+		"-var=tags={env=\"dev\"}",
+		"-var=empty=",
+	}, overrides.Args())
+	assert.False(t, overrides.IsEmpty())
+
+	_, err = ParseVarOverrides([]string{"location"}, nil)
+	assert.Error(t, err)
+	_, err = ParseVarOverrides([]string{"=westeurope"}, nil)
+	assert.Error(t, err)
+	_, err = ParseVarOverrides(nil, []string{filepath.Join(t.TempDir(), "missing.tfvars")})
+	assert.Error(t, err)
+	_, err = ParseVarOverrides(nil, []string{t.TempDir()})
+	assert.Error(t, err)
+
+	overrides, err = ParseVarOverrides(nil, nil)
+	assert.Nil(t, err)
+	assert.True(t, overrides.IsEmpty())
+	assert.Empty(t, overrides.Args())
+}
+
+func Test_Plan_Fingerprint(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "web.tfplan")
+	err := os.WriteFile(planFile, []byte("plan"), 0644)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "web.tfvars"), []byte("location = \"uksouth\"\n"), 0644)
+	assert.Nil(t, err)
+
+	o := Options{ConfigPath: dir, Overrides: VarOverrides{Vars: []string{"location=westeurope"}}}
+	err = o.writePlanFingerprint(planFile)
+	assert.Nil(t, err)
+
+	fingerprint, err := readPlanFingerprint(planFile)
+	assert.Nil(t, err)
+	assert.Equal(t, planFile, fingerprint.PlanFile)
+	assert.Equal(t, "64879f7d6b960a01909762d911a32d4582c20010c5641ee90278b644a9e3b525", fingerprint.SHA256)
+	assert.Equal(t, []string{filepath.Join(dir, "web.tfvars")}, fingerprint.VarFiles)
+	assert.Equal(t, []string{"-var=location=westeurope"}, fingerprint.Overrides)
+}
+
+func Test_Check_Plan_Fingerprint(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "web.tfplan")
+	err := os.WriteFile(planFile, []byte("plan"), 0644)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "web.tfvars"), []byte("location = \"uksouth\"\n"), 0644)
+	assert.Nil(t, err)
+
+	// No fingerprint
+	o := Options{ConfigPath: dir, Overrides: VarOverrides{Vars: []string{"location=westeurope"}}}
+	_, err = o.checkPlanFingerprint(planFile)
+	assert.Error(t, err)
+
+	err = o.writePlanFingerprint(planFile)
+	assert.Nil(t, err)
+	fingerprint, err := o.checkPlanFingerprint(planFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-var=location=westeurope"}, fingerprint.Overrides)
+
+	// Applying without the overrides is fine, different ones are not
+	_, err = (&Options{}).checkPlanFingerprint(planFile)
+	assert.Nil(t, err)
+	other := Options{Overrides: VarOverrides{Vars: []string{"location=uksouth"}}}
+	_, err = other.checkPlanFingerprint(planFile)
+	assert.EqualError(t, err, "variable overrides -var=location=uksouth don't match the ones plan "+planFile+" was created with (-var=location=westeurope), run plan again with them")
+
+	// A swapped plan is refused
+	err = os.WriteFile(planFile, []byte("another plan"), 0644)
+	assert.Nil(t, err)
+	_, err = o.checkPlanFingerprint(planFile)
+	assert.EqualError(t, err, "plan "+planFile+" has changed since it was created, its SHA256 doesn't match the fingerprint, run plan again")
+}
@@ -0,0 +1,188 @@
+//
+// Rover - Timeouts and retries
+// * Operations failing with known transient errors, e.g. state blob leases or Azure throttling, are retried
+// * The retry policy and action timeouts come from the symphony config file, or the rover config file
+//
+
+package landingzone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+)
+
+const defaultRetryAttempts = 3
+const defaultRetryDelay = 30 * time.Second
+const maxRetryDelay = 5 * time.Minute
+
+// defaultTimeoutKey is used in timeouts config to apply to every action
+const defaultTimeoutKey = "default"
+
+// transientErrors are terraform & Azure errors which are likely to succeed if tried again, with the reason logged
+var transientErrors = []struct {
+	reason  string
+	pattern *regexp.Regexp
+}{
+	{"state blob is leased by another run", regexp.MustCompile(`(?i)LeaseAlreadyPresent|LeaseIdMissing|there is currently a lease on the blob|state blob is already locked|error acquiring the state lock`)},
+	{"throttled by Azure (HTTP 429)", regexp.MustCompile(`(?i)StatusCode=429|Status=429|status code 429|TooManyRequests`)},
+	{"Azure server error (HTTP 5xx)", regexp.MustCompile(`(?i)StatusCode=5\d\d|Status=5\d\d|status code 5\d\d|InternalServerError|ServiceUnavailable|BadGateway|GatewayTimeout`)},
+}
+
+// RunState is shared by all the stacks in a run, the options for each stack point to the same one
+type RunState struct {
+	launchpadDeployed int32
+}
+
+// SetLaunchpadDeployed records that a launchpad has been created during this run
+func (r *RunState) SetLaunchpadDeployed() {
+	if r != nil {
+		atomic.StoreInt32(&r.launchpadDeployed, 1)
+	}
+}
+
+// LaunchpadDeployed is true once a launchpad has been created during this run
+// Resource graph can take a few minutes to index new resources, so lookups are retried from then on
+func (r *RunState) LaunchpadDeployed() bool {
+	return r != nil && atomic.LoadInt32(&r.launchpadDeployed) == 1
+}
+
+// retryPolicy holds the resolved retry settings
+type retryPolicy struct {
+	attempts int
+	delay    time.Duration
+}
+
+// transientReason classifies an error, returning why it's worth retrying or an empty string if it's not
+func transientReason(err error) string {
+	for _, te := range transientErrors {
+		if te.pattern.MatchString(err.Error()) {
+			return te.reason
+		}
+	}
+	return ""
+}
+
+// lockReason only treats failing to lock the state as transient, terraform hasn't changed anything at that point
+// It's used for apply, where a throttled or failed request may come after some resources were already changed
+func lockReason(err error) string {
+	if transientErrors[0].pattern.MatchString(err.Error()) {
+		return transientErrors[0].reason
+	}
+	return ""
+}
+
+// graphLagReason returns a classifier for resource graph lookups, when a launchpad has been deployed
+// not finding it is treated as transient, any other failure is not
+func graphLagReason(deployed bool) func(error) string {
+	return func(err error) string {
+		if !deployed || !azure.IsLaunchpadNotFound(err) {
+			return ""
+		}
+		return "resource graph may not have indexed the new launchpad yet"
+	}
+}
+
+// retryPolicy resolves the policy for this stack, from the symphony config, then rover config, then defaults
+func (o *Options) retryPolicy() (retryPolicy, error) {
+	policy := retryPolicy{attempts: defaultRetryAttempts, delay: defaultRetryDelay}
+
+	conf := o.Retry
+	if conf == nil {
+		roverConf, err := rover.LoadConfig()
+		if err != nil {
+			return policy, err
+		}
+		conf = roverConf.Retry
+	}
+	if conf == nil {
+		return policy, nil
+	}
+
+	if conf.Attempts > 0 {
+		policy.attempts = conf.Attempts
+	}
+	if conf.Delay != "" {
+		delay, err := time.ParseDuration(conf.Delay)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry delay '%s': %s", conf.Delay, err)
+		}
+		policy.delay = delay
+	}
+	return policy, nil
+}
+
+// retry runs fn using the retry policy for this stack
+// what describes the operation for logging, classify decides which errors are transient
+func (o *Options) retry(ctx context.Context, what string, classify func(error) string, fn func() error) error {
+	policy, err := o.retryPolicy()
+	if err != nil {
+		return err
+	}
+	return policy.run(ctx, fmt.Sprintf("%s for %s", what, o.StateName), classify, fn)
+}
+
+func (p retryPolicy) run(ctx context.Context, what string, classify func(error) string, fn func() error) error {
+	delay := p.delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.attempts || ctx.Err() != nil {
+			return err
+		}
+		reason := classify(err)
+		if reason == "" {
+			return err
+		}
+
+		console.StopSpinner()
+		console.Warningf("%s failed, %s. Retrying in %s (attempt %d of %d)\n", what, reason, delay, attempt+1, p.attempts)
+		console.Debugf("Error was: %s\n", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		console.StartSpinner()
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// TimeoutFor returns how long the named action may run against this stack, zero means there is no limit
+// The --timeout flag takes precedence, then the symphony config file, then the rover config file
+func (o *Options) TimeoutFor(action string) (time.Duration, error) {
+	if o.Timeout > 0 {
+		return o.Timeout, nil
+	}
+
+	timeouts := o.Timeouts
+	if len(timeouts) == 0 {
+		roverConf, err := rover.LoadConfig()
+		if err != nil {
+			return 0, err
+		}
+		timeouts = roverConf.Timeouts
+	}
+
+	timeout, ok := timeouts[action]
+	if !ok {
+		timeout, ok = timeouts[defaultTimeoutKey]
+	}
+	if !ok {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout '%s' for action %s: %s", timeout, action, err)
+	}
+	return duration, nil
+}
@@ -0,0 +1,112 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Transient_Reason(t *testing.T) {
+	assert.Equal(t, "state blob is leased by another run", transientReason(errors.New("Error: Failed to get existing workspaces: containers.Client#ListBlobs: Failure responding to request: StatusCode=409 -- Original Error: autorest/azure: Service returned an error. Status=409 Code=\"LeaseAlreadyPresent\"")))
+	assert.Equal(t, "throttled by Azure (HTTP 429)", transientReason(errors.New("Error: retrieving Resource Group: resources.GroupsClient#Get: Failure sending request: StatusCode=429 -- Original Error: Code=\"TooManyRequests\"")))
+	assert.Equal(t, "Azure server error (HTTP 5xx)", transientReason(errors.New("Error: creating Virtual Network: StatusCode=503 Code=\"ServiceUnavailable\"")))
+	assert.Equal(t, "", transientReason(errors.New("Error: Invalid reference, a reference to a resource type must be followed by at least one attribute access")))
+}
+
+func Test_Retry_Policy_Run(t *testing.T) {
+	policy := retryPolicy{attempts: 3, delay: time.Millisecond}
+
+	// Transient errors are retried until success
+	calls := 0
+	err := policy.run(context.Background(), "Init", transientReason, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("StatusCode=429")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Other errors are returned straight away
+	calls = 0
+	err = policy.run(context.Background(), "Init", transientReason, func() error {
+		calls++
+		return errors.New("Error: Unsupported argument")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+
+	// Retries stop after the last attempt
+	calls = 0
+	err = policy.run(context.Background(), "Init", transientReason, func() error {
+		calls++
+		return errors.New("StatusCode=500")
+	})
+	assert.EqualError(t, err, "StatusCode=500")
+	assert.Equal(t, 3, calls)
+}
+
+func Test_Timeout_For(t *testing.T) {
+	roverHome := t.TempDir()
+	rover.SetHomeDirectory(roverHome)
+	err := os.WriteFile(filepath.Join(roverHome, "config.yaml"), []byte("timeouts:\n  default: 1h\n  destroy: 3h\n"), 0644)
+	assert.Nil(t, err)
+
+	// Falls back to the rover config
+	o := Options{}
+	timeout, err := o.TimeoutFor("destroy")
+	assert.Nil(t, err)
+	assert.Equal(t, 3*time.Hour, timeout)
+	timeout, err = o.TimeoutFor("plan")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, timeout)
+
+	// Symphony config takes precedence
+	o.Timeouts = map[string]string{"apply": "90m"}
+	timeout, err = o.TimeoutFor("apply")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, timeout)
+	timeout, err = o.TimeoutFor("plan")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), timeout)
+
+	// Then the --timeout flag
+	o.Timeout = 10 * time.Minute
+	timeout, err = o.TimeoutFor("apply")
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Minute, timeout)
+
+	o = Options{Timeouts: map[string]string{"apply": "soon"}}
+	_, err = o.TimeoutFor("apply")
+	assert.NotNil(t, err)
+}
+
+func Test_Lock_And_Graph_Lag_Reason(t *testing.T) {
+	assert.Equal(t, "state blob is leased by another run", lockReason(errors.New("Error: Error acquiring the state lock")))
+	assert.Equal(t, "", lockReason(errors.New("Error: creating Virtual Network: StatusCode=503 Code=\"ServiceUnavailable\"")))
+	assert.Equal(t, "", lockReason(errors.New("StatusCode=429")))
+
+	notFound := fmt.Errorf("%w, no storage account has tags tfstate=level0", azure.ErrLaunchpadNotFound)
+	assert.Equal(t, "", graphLagReason(false)(notFound))
+	assert.NotEqual(t, "", graphLagReason(true)(notFound))
+	assert.Equal(t, "", graphLagReason(true)(azure.ErrLaunchpadAmbiguous))
+	assert.Equal(t, "", graphLagReason(true)(errors.New("AADSTS700016: application not found")))
+
+	var run *RunState
+	assert.False(t, run.LaunchpadDeployed())
+	run = &RunState{}
+	run.SetLaunchpadDeployed()
+	assert.True(t, run.LaunchpadDeployed())
+}
@@ -0,0 +1,204 @@
+//
+// Rover - State snapshots
+// * The state for a stack is copied aside before terraform changes it, giving a point to roll back to
+// * Snapshots are named <statename>.tfstate.<timestamp> and kept in a snapshots area next to the state
+//
+
+package landingzone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+)
+
+// SnapshotsPrefix is where snapshots are kept, as a folder in the workspace container or directory
+const SnapshotsPrefix = "snapshots/"
+
+const snapshotTimeFormat = "20060102T150405Z"
+const defaultSnapshotRetain = 10
+
+// StateSnapshot is a copy of the state for a stack, taken before it was changed
+type StateSnapshot struct {
+	// ID is the UTC time the snapshot was taken, it's used to choose a snapshot to restore
+	ID      string
+	Created time.Time
+	Size    int64
+}
+
+func newSnapshotID(t time.Time) string {
+	return t.UTC().Format(snapshotTimeFormat)
+}
+
+// snapshotName is the file or blob name for a snapshot, <statename>.tfstate.<id>
+func snapshotName(stateName string, id string) string {
+	return fmt.Sprintf("%s.tfstate.%s", stateName, id)
+}
+
+// parseSnapshotName returns the snapshot for a file or blob name, false when it isn't a snapshot of the state
+func parseSnapshotName(stateName string, name string, size int64) (StateSnapshot, bool) {
+	id := strings.TrimPrefix(name, stateName+".tfstate.")
+	if id == name {
+		return StateSnapshot{}, false
+	}
+	created, err := time.Parse(snapshotTimeFormat, id)
+	if err != nil {
+		return StateSnapshot{}, false
+	}
+	return StateSnapshot{ID: id, Created: created, Size: size}, true
+}
+
+// sortSnapshots orders snapshots oldest first
+func sortSnapshots(snapshots []StateSnapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+}
+
+// snapshotRetain is how many snapshots to keep, from the symphony config or the rover config file
+func (o *Options) snapshotRetain() int {
+	conf := o.Snapshots
+	if conf == nil {
+		roverConf, err := rover.LoadConfig()
+		if err == nil {
+			conf = roverConf.Snapshots
+		}
+	}
+	if conf == nil || conf.Retain == 0 {
+		return defaultSnapshotRetain
+	}
+	return conf.Retain
+}
+
+// snapshotState copies the current state aside before terraform changes it, then removes snapshots beyond the retention
+// A failed snapshot stops the run, as there would be nothing to roll back to
+func (o *Options) snapshotState(backend StateBackend, location string) error {
+	retain := o.snapshotRetain()
+	if retain < 0 {
+		console.Debug("State snapshots are disabled")
+		return nil
+	}
+
+	id := newSnapshotID(time.Now())
+	saved, err := backend.SaveSnapshot(o, location, id)
+	if err != nil {
+		return fmt.Errorf("unable to snapshot state %s.tfstate: %s", o.StateName, err)
+	}
+	if !saved {
+		console.Debugf("No state %s.tfstate to snapshot yet\n", o.StateName)
+		return nil
+	}
+	console.Infof("Saved state snapshot %s\n", snapshotName(o.StateName, id))
+
+	o.pruneSnapshots(backend, location, retain)
+	return nil
+}
+
+// pruneSnapshots removes the oldest snapshots, failures only warn as the run can safely continue
+func (o *Options) pruneSnapshots(backend StateBackend, location string, retain int) {
+	snapshots, err := backend.Snapshots(o, location)
+	if err != nil {
+		console.Warningf("Unable to list state snapshots to remove old ones: %s\n", err)
+		return
+	}
+	for len(snapshots) > retain {
+		console.Debugf("Removing state snapshot %s\n", snapshotName(o.StateName, snapshots[0].ID))
+		err = backend.DeleteSnapshot(o, location, snapshots[0].ID)
+		if err != nil {
+			console.Warningf("Unable to remove state snapshot %s: %s\n", snapshotName(o.StateName, snapshots[0].ID), err)
+		}
+		snapshots = snapshots[1:]
+	}
+}
+
+// StateSnapshots lists the snapshots of the state for the stack, oldest first
+func (o *Options) StateSnapshots(ctx context.Context) ([]StateSnapshot, error) {
+	backend, location, err := o.locateState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Snapshots(o, location)
+}
+
+// RestoreSnapshot replaces the state for the stack with a snapshot, the current state is snapshotted first
+// The restored state is given the next serial, so terraform and other runs treat it as the latest state
+func (o *Options) RestoreSnapshot(ctx context.Context, id string, force bool) error {
+	backend, location, err := o.locateState(ctx)
+	if err != nil {
+		return err
+	}
+	err = o.checkStateLock(backend, location)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "rover-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	snapshotFile := filepath.Join(tempDir, snapshotName(o.StateName, id))
+	err = backend.DownloadSnapshot(o, location, id, snapshotFile)
+	if err != nil {
+		return fmt.Errorf("unable to read snapshot %s: %s", snapshotName(o.StateName, id), err)
+	}
+	snapshot, err := readStateMeta(snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	currentFile := filepath.Join(tempDir, o.StateName+".tfstate")
+	version, err := backend.Download(o, location, currentFile)
+	if isStateNotFound(err) {
+		version = stateVersionNone
+	} else if err != nil {
+		return err
+	} else {
+		current, err := readStateMeta(currentFile)
+		if err != nil {
+			return err
+		}
+		err = checkSnapshotRestore(current, snapshot, force)
+		if err != nil {
+			return err
+		}
+		err = setStateSerial(snapshotFile, current.Serial+1)
+		if err != nil {
+			return err
+		}
+
+		// The state being replaced becomes a snapshot too, so the restore can itself be undone
+		err = o.snapshotState(backend, location)
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.replaceState(backend, location, snapshotFile, version)
+}
+
+// checkSnapshotRestore refuses to restore a snapshot which isn't an earlier version of the current state, unless forced
+func checkSnapshotRestore(current *stateMeta, snapshot *stateMeta, force bool) error {
+	var problem string
+	if current.Lineage != snapshot.Lineage {
+		problem = fmt.Sprintf("snapshot lineage %s does not match the current state lineage %s, it is from a different state", snapshot.Lineage, current.Lineage)
+	} else if snapshot.Serial > current.Serial {
+		problem = fmt.Sprintf("snapshot serial %d is newer than the current state serial %d", snapshot.Serial, current.Serial)
+	}
+	if problem == "" {
+		return nil
+	}
+	if force {
+		console.Warningf("Restoring anyway, %s\n", problem)
+		return nil
+	}
+	return fmt.Errorf("%s, use --force to restore it anyway", problem)
+}
@@ -0,0 +1,84 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse_Snapshot_Name(t *testing.T) {
+	snapshot, ok := parseSnapshotName("web", "web.tfstate.20210901T101500Z", 2048)
+	assert.True(t, ok)
+	assert.Equal(t, "20210901T101500Z", snapshot.ID)
+	assert.Equal(t, 2021, snapshot.Created.Year())
+	assert.Equal(t, int64(2048), snapshot.Size)
+
+	_, ok = parseSnapshotName("web", "web2.tfstate.20210901T101500Z", 0)
+	assert.False(t, ok)
+	_, ok = parseSnapshotName("web", "web.tfstate", 0)
+	assert.False(t, ok)
+	_, ok = parseSnapshotName("web", "web.tfstate.backup", 0)
+	assert.False(t, ok)
+}
+
+func Test_Check_Snapshot_Restore(t *testing.T) {
+	current := &stateMeta{Serial: 5, Lineage: "a1"}
+
+	assert.Nil(t, checkSnapshotRestore(current, &stateMeta{Serial: 3, Lineage: "a1"}, false))
+	assert.Error(t, checkSnapshotRestore(current, &stateMeta{Serial: 3, Lineage: "b2"}, false))
+	assert.Error(t, checkSnapshotRestore(current, &stateMeta{Serial: 6, Lineage: "a1"}, false))
+	assert.Nil(t, checkSnapshotRestore(current, &stateMeta{Serial: 6, Lineage: "b2"}, true))
+}
+
+func Test_Snapshot_And_Restore(t *testing.T) {
+	root := t.TempDir()
+	o := &Options{Level: "level0", CafEnvironment: "sandpit", Workspace: "tfstate", StateName: "launchpad", LaunchPadMode: true}
+	o.StateBackend = StateBackendConfig{Type: BackendLocal, Path: root}
+	o.Snapshots = &rover.SnapshotConfig{Retain: 2}
+	backend, err := o.loadBackend()
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	location, err := backend.Locate(ctx, o)
+	assert.Nil(t, err)
+
+	// Nothing to snapshot before there is any state
+	saved, err := backend.SaveSnapshot(o, location, "20210901T100000Z")
+	assert.Nil(t, err)
+	assert.False(t, saved)
+
+	statePath := filepath.Join(location, "tfstate", "launchpad.tfstate")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(statePath), os.ModePerm))
+	assert.Nil(t, os.WriteFile(statePath, []byte(`{"version":4,"serial":1,"lineage":"a1","resources":[]}`), 0644))
+	for _, id := range []string{"20210901T100000Z", "20210901T110000Z"} {
+		saved, err = backend.SaveSnapshot(o, location, id)
+		assert.Nil(t, err)
+		assert.True(t, saved)
+	}
+
+	assert.Nil(t, os.WriteFile(statePath, []byte(`{"version":4,"serial":3,"lineage":"a1","resources":["changed"]}`), 0644))
+	assert.Nil(t, o.RestoreSnapshot(ctx, "20210901T100000Z", false))
+
+	// The restored state has the snapshot contents, with the next serial
+	meta, err := readStateMeta(statePath)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), meta.Serial)
+	restored, err := os.ReadFile(statePath)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(restored), "changed")
+
+	// The replaced state was snapshotted, and the oldest snapshot pruned
+	snapshots, err := o.StateSnapshots(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "20210901T110000Z", snapshots[0].ID)
+
+	assert.Error(t, o.RestoreSnapshot(ctx, "20200101T000000Z", false))
+}
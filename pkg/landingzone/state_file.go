@@ -0,0 +1,126 @@
+//
+// Rover - State files
+// * Reads the serial & lineage terraform records in state, so rover never overwrites newer or unrelated state
+//
+
+package landingzone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+)
+
+// stateMeta is the part of a terraform state file which identifies it
+// Lineage is set when state is first created, serial goes up each time state is written
+type stateMeta struct {
+	Serial  uint64 `json:"serial"`
+	Lineage string `json:"lineage"`
+}
+
+// isStateNotFound is true when a download failed as there is no state for the stack yet
+func isStateNotFound(err error) bool {
+	return os.IsNotExist(err) || azure.IsBlobNotFound(err)
+}
+
+func readStateMeta(stateFile string) (*stateMeta, error) {
+	stateJSON, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	meta := &stateMeta{}
+	err = json.Unmarshal(stateJSON, meta)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terraform state %s: %s", filepath.Base(stateFile), err)
+	}
+	return meta, nil
+}
+
+// setStateSerial rewrites the serial in a state file, leaving everything else as it was
+func setStateSerial(stateFile string, serial uint64) error {
+	stateJSON, err := os.ReadFile(stateFile)
+	if err != nil {
+		return err
+	}
+	state := map[string]json.RawMessage{}
+	err = json.Unmarshal(stateJSON, &state)
+	if err != nil {
+		return fmt.Errorf("unable to read terraform state %s: %s", filepath.Base(stateFile), err)
+	}
+
+	state["serial"], err = json.Marshal(serial)
+	if err != nil {
+		return err
+	}
+	stateJSON, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, append(stateJSON, '\n'), 0644)
+}
+
+// uploadState copies a local state file into the backend, after checking it is the same or a later version of the state there
+// Newer or unrelated state in the backend is only replaced when forced
+func (o *Options) uploadState(backend StateBackend, location string, stateFile string, force bool) error {
+	local, err := readStateMeta(stateFile)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "rover-state-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	remoteFile := filepath.Join(tempDir, o.StateName+".tfstate")
+	version, err := backend.Download(o, location, remoteFile)
+	if isStateNotFound(err) {
+		version = stateVersionNone
+	} else if err != nil {
+		return err
+	} else {
+		remote, err := readStateMeta(remoteFile)
+		if err != nil {
+			return err
+		}
+		err = checkStateOverwrite(local, remote, force)
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.replaceState(backend, location, stateFile, version)
+}
+
+// checkStateOverwrite refuses to replace existing state which is newer than the state replacing it, or from a different lineage, unless forced
+func checkStateOverwrite(replacement *stateMeta, existing *stateMeta, force bool) error {
+	var problem string
+	if replacement.Lineage != existing.Lineage {
+		problem = fmt.Sprintf("existing state lineage %s does not match lineage %s of the state replacing it, it is from a different state", existing.Lineage, replacement.Lineage)
+	} else if existing.Serial > replacement.Serial {
+		problem = fmt.Sprintf("existing state serial %d is newer than serial %d of the state replacing it", existing.Serial, replacement.Serial)
+	}
+	if problem == "" {
+		return nil
+	}
+	if force {
+		console.Warningf("Overwriting state anyway, %s\n", problem)
+		return nil
+	}
+	return fmt.Errorf("%s, use --force to overwrite it anyway", problem)
+}
+
+// replaceState uploads state only if the state in the backend is still at version, i.e. nothing else has written it meanwhile
+func (o *Options) replaceState(backend StateBackend, location string, stateFile string, version string) error {
+	err := backend.Upload(o, location, stateFile, version)
+	if errors.Is(err, errStateChanged) {
+		return fmt.Errorf("state %s.tfstate was changed by another run while rover was updating it, check it and try again", o.StateName)
+	}
+	return err
+}
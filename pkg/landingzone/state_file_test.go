@@ -0,0 +1,44 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Check_State_Overwrite(t *testing.T) {
+	local := &stateMeta{Serial: 5, Lineage: "a1"}
+
+	assert.Nil(t, checkStateOverwrite(local, &stateMeta{Serial: 5, Lineage: "a1"}, false))
+	assert.Nil(t, checkStateOverwrite(local, &stateMeta{Serial: 4, Lineage: "a1"}, false))
+	assert.Error(t, checkStateOverwrite(local, &stateMeta{Serial: 6, Lineage: "a1"}, false))
+	assert.Error(t, checkStateOverwrite(local, &stateMeta{Serial: 1, Lineage: "b2"}, false))
+	assert.Nil(t, checkStateOverwrite(local, &stateMeta{Serial: 6, Lineage: "b2"}, true))
+}
+
+func Test_Upload_State(t *testing.T) {
+	o := &Options{Level: "level0", CafEnvironment: "sandpit", Workspace: "tfstate", StateName: "launchpad", LaunchPadMode: true}
+	o.StateBackend = StateBackendConfig{Type: BackendLocal, Path: t.TempDir()}
+	backend, err := o.loadBackend()
+	assert.Nil(t, err)
+	location, err := backend.Locate(context.Background(), o)
+	assert.Nil(t, err)
+
+	stateFile := filepath.Join(t.TempDir(), "launchpad.tfstate")
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`{"version":4,"serial":2,"lineage":"a1"}`), 0644))
+	assert.Nil(t, o.uploadState(backend, location, stateFile, false))
+
+	// Older local state is refused, unless forced
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`{"version":4,"serial":1,"lineage":"a1"}`), 0644))
+	assert.Error(t, o.uploadState(backend, location, stateFile, false))
+	assert.Nil(t, o.uploadState(backend, location, stateFile, true))
+
+	assert.Nil(t, os.WriteFile(stateFile, []byte(`not state`), 0644))
+	assert.Error(t, o.uploadState(backend, location, stateFile, true))
+}
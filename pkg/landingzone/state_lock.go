@@ -0,0 +1,160 @@
+//
+// Rover - State locks
+// * Reads the lock terraform takes on state, so a lock left by a failed pipeline can be explained and released
+//
+
+package landingzone
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+)
+
+// Terraform's azurerm backend keeps the lock details, base64 encoded, in this blob metadata key
+const azurermLockMetaKey = "terraformlockid"
+
+// errLockChanged is returned when unlocking finds the state holds a different lock to the one read
+var errLockChanged = errors.New("lock has changed since it was read")
+
+// StateLock describes the lock held on the state for a stack, the fields match terraform's lock info
+type StateLock struct {
+	ID        string    `json:"ID"`
+	Operation string    `json:"Operation"`
+	Info      string    `json:"Info"`
+	Who       string    `json:"Who"`
+	Version   string    `json:"Version"`
+	Created   time.Time `json:"Created"`
+	Path      string    `json:"Path"`
+	// Lease describes the blob lease, for the azurerm backend
+	Lease string `json:"-"`
+	// etag is the ETag of the state blob when the lock was read, for the azurerm backend
+	etag string
+}
+
+// Holder describes who holds the lock, for messages
+func (l StateLock) Holder() string {
+	if l.Who == "" {
+		return "an unknown holder"
+	}
+	holder := l.Who
+	if l.Operation != "" {
+		holder += fmt.Sprintf(" running %s", strings.TrimPrefix(l.Operation, "OperationType"))
+	}
+	if !l.Created.IsZero() {
+		holder += fmt.Sprintf(" since %s", l.Created.Local().Format(time.RFC1123))
+	}
+	return holder
+}
+
+// parseStateLock reads terraform's lock info JSON
+func parseStateLock(lockJSON []byte) (*StateLock, error) {
+	lock := &StateLock{}
+	err := json.Unmarshal(lockJSON, lock)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terraform lock info: %s", err)
+	}
+	return lock, nil
+}
+
+// azurermStateLock builds the lock from a leased state blob, the lease is the lock and the metadata holds the details
+func azurermStateLock(lease *azure.BlobLease) (*StateLock, error) {
+	if lease == nil || lease.State != "leased" {
+		return nil, nil
+	}
+
+	lock := &StateLock{}
+	for key, value := range lease.Metadata {
+		if !strings.EqualFold(key, azurermLockMetaKey) {
+			continue
+		}
+		lockJSON, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode terraform lock info: %s", err)
+		}
+		lock, err = parseStateLock(lockJSON)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lock.Lease = fmt.Sprintf("%s (%s)", lease.State, lease.Duration)
+	lock.etag = lease.ETag
+	return lock, nil
+}
+
+// locateState finds the backend holding the state for the stack, without the full setup done before running terraform
+func (o *Options) locateState(ctx context.Context) (StateBackend, string, error) {
+	backend, err := o.loadBackend()
+	if err != nil {
+		return nil, "", err
+	}
+	if o.StateSubscription == "" && backend.NeedsAzure() {
+		sub, err := o.cloud().GetSubscription()
+		if err != nil {
+			return nil, "", err
+		}
+		o.StateSubscription = sub.ID
+	}
+
+	location, err := backend.Locate(ctx, o)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to find %s state for environment '%s' and level '%s': %s", backend.Name(), o.CafEnvironment, o.Level, err)
+	}
+	return backend, location, nil
+}
+
+// StateLockInfo returns the lock held on the state for the stack, nil when it isn't locked
+func (o *Options) StateLockInfo(ctx context.Context) (*StateLock, error) {
+	backend, location, err := o.locateState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return backend.LockInfo(o, location)
+}
+
+// UnlockState forcibly releases the lock on the state for the stack
+// When lockID is given, the lock is only released if it matches
+func (o *Options) UnlockState(ctx context.Context, lockID string) (*StateLock, error) {
+	backend, location, err := o.locateState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := backend.LockInfo(o, location)
+	if err != nil || lock == nil {
+		return nil, err
+	}
+	if lockID != "" && lock.ID != lockID {
+		return nil, fmt.Errorf("state is locked with ID %s, not %s", lock.ID, lockID)
+	}
+	err = backend.Unlock(o, location, lock)
+	if errors.Is(err, azure.ErrBlobChanged) || errors.Is(err, errLockChanged) {
+		return nil, fmt.Errorf("the state was locked again after the lock was read, check who holds it now with 'rover state lock-info': %w", err)
+	}
+	return lock, err
+}
+
+// checkStateLock fails when the state is already locked, naming the holder rather than leaving terraform to fail with a lease error
+func (o *Options) checkStateLock(backend StateBackend, location string) error {
+	lock, err := backend.LockInfo(o, location)
+	if err != nil {
+		console.Warningf("Unable to check the lock on state %s.tfstate: %s\n", o.StateName, err)
+		return nil
+	}
+	if lock == nil {
+		return nil
+	}
+
+	console.Errorf("State %s.tfstate in workspace '%s' is locked by %s\n", o.StateName, o.Workspace, lock.Holder())
+	if lock.ID != "" {
+		console.Errorf("Lock ID: %s\n", lock.ID)
+	}
+	console.Error("If that run is no longer active, the lock can be released with 'rover state unlock'")
+	return fmt.Errorf("state for %s/%s is locked", o.Level, o.stackName())
+}
@@ -0,0 +1,72 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLockInfo = `{"ID":"6b1a2c3d-0000-4000-8000-1234567890ab","Operation":"OperationTypeApply","Info":"","Who":"runner@fv-az123","Version":"1.0.5","Created":"2021-09-01T10:15:00Z","Path":"tfstate/web.tfstate"}`
+
+func Test_AzureRM_State_Lock(t *testing.T) {
+	lease := &azure.BlobLease{
+		State:    "leased",
+		Duration: "infinite",
+		Metadata: map[string]string{"terraformlockid": base64.StdEncoding.EncodeToString([]byte(testLockInfo))},
+	}
+	lock, err := azurermStateLock(lease)
+	assert.Nil(t, err)
+	assert.Equal(t, "6b1a2c3d-0000-4000-8000-1234567890ab", lock.ID)
+	assert.Equal(t, "runner@fv-az123", lock.Who)
+	assert.Equal(t, "leased (infinite)", lock.Lease)
+	assert.Contains(t, lock.Holder(), "runner@fv-az123 running Apply since")
+
+	// A lease with no lock details is still a lock
+	lock, err = azurermStateLock(&azure.BlobLease{State: "leased", Duration: "infinite"})
+	assert.Nil(t, err)
+	assert.Equal(t, "an unknown holder", lock.Holder())
+
+	// Broken or expired leases are not locks, even if the metadata is left behind
+	lease.State = "broken"
+	lock, err = azurermStateLock(lease)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+
+	lock, err = azurermStateLock(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+}
+
+func Test_Local_State_Lock(t *testing.T) {
+	levelDir := t.TempDir()
+	backend := localBackend{root: filepath.Dir(levelDir)}
+	o := &Options{Workspace: "tfstate", StateName: "web"}
+
+	lock, err := backend.LockInfo(o, levelDir)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+
+	err = os.MkdirAll(filepath.Join(levelDir, "tfstate"), os.ModePerm)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(levelDir, "tfstate", ".web.tfstate.lock.info"), []byte(testLockInfo), 0644)
+	assert.Nil(t, err)
+
+	lock, err = backend.LockInfo(o, levelDir)
+	assert.Nil(t, err)
+	assert.Equal(t, "OperationTypeApply", lock.Operation)
+	assert.Error(t, o.checkStateLock(backend, levelDir))
+
+	err = backend.Unlock(o, levelDir, lock)
+	assert.Nil(t, err)
+	lock, err = backend.LockInfo(o, levelDir)
+	assert.Nil(t, err)
+	assert.Nil(t, lock)
+	assert.Nil(t, o.checkStateLock(backend, levelDir))
+}
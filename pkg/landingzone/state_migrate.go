@@ -0,0 +1,190 @@
+//
+// Rover - State migration
+// * Copies the state for a stack to another level, environment, workspace, state name or subscription
+// * Used when restructuring a symphony, e.g. renaming a state key or splitting an environment
+//
+
+package landingzone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/console"
+)
+
+// StateAddress identifies the state for a stack, written as level/environment/workspace/statename
+type StateAddress struct {
+	Level       string
+	Environment string
+	Workspace   string
+	StateName   string
+	// Subscription holding the launchpad for the address, when empty the subscription of the base options is used
+	Subscription string
+}
+
+// ParseStateAddress reads an address in the form level/environment/workspace/statename
+func ParseStateAddress(address string) (StateAddress, error) {
+	parts := strings.Split(address, "/")
+	if len(parts) != 4 {
+		return StateAddress{}, fmt.Errorf("state address '%s' must be in the form level/environment/workspace/statename", address)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return StateAddress{}, fmt.Errorf("state address '%s' must be in the form level/environment/workspace/statename", address)
+		}
+	}
+	return StateAddress{Level: parts[0], Environment: parts[1], Workspace: parts[2], StateName: strings.TrimSuffix(parts[3], ".tfstate")}, nil
+}
+
+func (a StateAddress) String() string {
+	address := fmt.Sprintf("%s/%s/%s/%s", a.Level, a.Environment, a.Workspace, a.StateName)
+	if a.Subscription != "" {
+		address += fmt.Sprintf(" in subscription %s", a.Subscription)
+	}
+	return address
+}
+
+// options returns the options for the stack at the address, sharing the state backend of base
+// The subscription of base is used unless the address has its own
+func (a StateAddress) options(base Options) *Options {
+	o := base
+	if a.Subscription != "" {
+		o.StateSubscription = a.Subscription
+	}
+	o.Level = a.Level
+	o.CafEnvironment = a.Environment
+	o.Workspace = a.Workspace
+	o.StateName = a.StateName
+	return &o
+}
+
+// MigrateOptions control how MigrateState moves state
+type MigrateOptions struct {
+	// DeleteSource removes the source state once it has been copied, it is snapshotted first
+	DeleteSource bool
+	// DryRun only reports what would be moved
+	DryRun bool
+	// Force overwrites existing destination state which is newer or from a different lineage
+	Force bool
+}
+
+// MigrateState copies the state for a stack from one address to another, possibly in a different launchpad or subscription
+// base supplies the state backend used to find both, and the subscription for any address without its own
+func MigrateState(ctx context.Context, base Options, from StateAddress, to StateAddress, opts MigrateOptions) error {
+	if from == to {
+		return fmt.Errorf("source and destination are both %s", from)
+	}
+
+	src := from.options(base)
+	srcBackend, srcLocation, err := src.locateState(ctx)
+	if err != nil {
+		return err
+	}
+	dest := to.options(base)
+	destBackend, destLocation, err := dest.locateState(ctx)
+	if err != nil {
+		return err
+	}
+	// Addresses which differ only in how the subscription was given can still be the same state
+	fromState, toState := from, to
+	fromState.Subscription, toState.Subscription = src.StateSubscription, dest.StateSubscription
+	if fromState == toState {
+		return fmt.Errorf("source and destination are both %s", fromState)
+	}
+
+	// Moving state while terraform holds it would lose whatever that run writes
+	err = src.checkStateLock(srcBackend, srcLocation)
+	if err != nil {
+		return err
+	}
+	err = dest.checkStateLock(destBackend, destLocation)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "rover-migrate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	srcFile := filepath.Join(tempDir, "source.tfstate")
+	srcVersion, err := srcBackend.Download(src, srcLocation, srcFile)
+	if isStateNotFound(err) {
+		return fmt.Errorf("no state found for %s in %s", from, srcBackend.Describe(srcLocation))
+	}
+	if err != nil {
+		return err
+	}
+	srcMeta, err := readStateMeta(srcFile)
+	if err != nil {
+		return err
+	}
+
+	destFile := filepath.Join(tempDir, "destination.tfstate")
+	version, err := destBackend.Download(dest, destLocation, destFile)
+	destExists := err == nil
+	if isStateNotFound(err) {
+		version = stateVersionNone
+	} else if err != nil {
+		return err
+	}
+	if destExists {
+		destMeta, err := readStateMeta(destFile)
+		if err != nil {
+			return err
+		}
+		err = checkStateOverwrite(srcMeta, destMeta, opts.Force)
+		if err != nil {
+			return fmt.Errorf("%s already has state: %s", to, err)
+		}
+	}
+
+	if opts.DryRun {
+		console.Infof("Would copy %s (serial %d, lineage %s) from %s\n", from, srcMeta.Serial, srcMeta.Lineage, srcBackend.Describe(srcLocation))
+		if destExists {
+			console.Infof("Would replace the existing state for %s in %s\n", to, destBackend.Describe(destLocation))
+		} else {
+			console.Infof("Would create %s in %s\n", to, destBackend.Describe(destLocation))
+		}
+		if opts.DeleteSource {
+			console.Infof("Would delete the source state for %s\n", from)
+		}
+		return nil
+	}
+
+	if destExists {
+		err = dest.snapshotState(destBackend, destLocation)
+		if err != nil {
+			return err
+		}
+	}
+	console.Infof("Copying %s from %s to %s in %s\n", from, srcBackend.Describe(srcLocation), to, destBackend.Describe(destLocation))
+	err = dest.replaceState(destBackend, destLocation, srcFile, version)
+	if err != nil {
+		return err
+	}
+
+	if opts.DeleteSource {
+		// Keep a snapshot of the source, so the move can still be undone
+		err = src.snapshotState(srcBackend, srcLocation)
+		if err != nil {
+			return err
+		}
+		// Only the state which was copied is deleted, anything written to the source since would be lost
+		console.Infof("Deleting the source state for %s\n", from)
+		err = srcBackend.Delete(src, srcLocation, srcVersion)
+		if errors.Is(err, errStateChanged) {
+			return fmt.Errorf("state was copied to %s, but the source has changed since so it was not deleted, check both and migrate again", to)
+		}
+		if err != nil {
+			return fmt.Errorf("state was copied to %s, but deleting the source failed: %s", to, err)
+		}
+	}
+	return nil
+}
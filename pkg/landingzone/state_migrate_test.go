@@ -0,0 +1,69 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse_State_Address(t *testing.T) {
+	address, err := ParseStateAddress("level1/sandpit/tfstate/web.tfstate")
+	assert.Nil(t, err)
+	assert.Equal(t, StateAddress{Level: "level1", Environment: "sandpit", Workspace: "tfstate", StateName: "web"}, address)
+	assert.Equal(t, "level1/sandpit/tfstate/web", address.String())
+
+	_, err = ParseStateAddress("level1/sandpit/web")
+	assert.Error(t, err)
+	_, err = ParseStateAddress("level1//tfstate/web")
+	assert.Error(t, err)
+}
+
+func Test_Migrate_State(t *testing.T) {
+	root := t.TempDir()
+	base := Options{
+		StateBackend: StateBackendConfig{Type: BackendLocal, Path: root},
+		Snapshots:    &rover.SnapshotConfig{Retain: 5},
+	}
+	ctx := context.Background()
+
+	from, _ := ParseStateAddress("level1/sandpit/tfstate/web")
+	to, _ := ParseStateAddress("level1/prod/tfstate/web")
+	srcPath := filepath.Join(root, "sandpit", "level1", "tfstate", "web.tfstate")
+	destPath := filepath.Join(root, "prod", "level1", "tfstate", "web.tfstate")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(srcPath), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "prod"), os.ModePerm))
+	assert.Nil(t, os.WriteFile(srcPath, []byte(`{"version":4,"serial":3,"lineage":"a1"}`), 0644))
+
+	// A dry run changes nothing
+	err := MigrateState(ctx, base, from, to, MigrateOptions{DryRun: true, DeleteSource: true})
+	assert.Nil(t, err)
+	assert.NoFileExists(t, destPath)
+	assert.FileExists(t, srcPath)
+
+	err = MigrateState(ctx, base, from, to, MigrateOptions{})
+	assert.Nil(t, err)
+	assert.FileExists(t, destPath)
+	assert.FileExists(t, srcPath)
+
+	// Unrelated state at the destination is only replaced when forced
+	assert.Nil(t, os.WriteFile(destPath, []byte(`{"version":4,"serial":1,"lineage":"b2"}`), 0644))
+	err = MigrateState(ctx, base, from, to, MigrateOptions{DeleteSource: true})
+	assert.Error(t, err)
+	assert.FileExists(t, srcPath)
+
+	err = MigrateState(ctx, base, from, to, MigrateOptions{DeleteSource: true, Force: true})
+	assert.Nil(t, err)
+	assert.NoFileExists(t, srcPath)
+	content, _ := os.ReadFile(destPath)
+	assert.Contains(t, string(content), `"lineage":"a1"`)
+
+	err = MigrateState(ctx, base, from, to, MigrateOptions{})
+	assert.Error(t, err)
+}
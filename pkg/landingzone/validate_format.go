@@ -0,0 +1,248 @@
+//
+// Rover - Validate diagnostics formatting
+// * Renders terraform validate diagnostics as SARIF, JSON or CI annotations for GitHub Actions & Azure DevOps
+//
+
+package landingzone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/version"
+)
+
+const (
+	ValidateFormatText   = "text"
+	ValidateFormatJSON   = "json"
+	ValidateFormatSARIF  = "sarif"
+	ValidateFormatGitHub = "github"
+	ValidateFormatAzDo   = "azdo"
+)
+
+// ValidateFormats lists all the supported formats for WriteDiagnostics
+var ValidateFormats = []string{ValidateFormatText, ValidateFormatJSON, ValidateFormatSARIF, ValidateFormatGitHub, ValidateFormatAzDo}
+
+const sarifRuleID = "terraform-validate"
+
+// Diagnostic is a single error or warning from terraform validate
+// Filename is relative to the directory rover was run from, so it matches paths in the repo
+type Diagnostic struct {
+	Level     string `json:"level"`
+	Stack     string `json:"stack,omitempty"`
+	Severity  string `json:"severity"`
+	Summary   string `json:"summary"`
+	Detail    string `json:"detail,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+// ValidateReport holds the diagnostics from all the stacks validated
+type ValidateReport struct {
+	Valid        bool         `json:"valid"`
+	ErrorCount   int          `json:"errorCount"`
+	WarningCount int          `json:"warningCount"`
+	Diagnostics  []Diagnostic `json:"diagnostics"`
+}
+
+// WriteDiagnostics renders a validate report in the requested format
+func WriteDiagnostics(w io.Writer, report ValidateReport, format string) error {
+	switch format {
+	case ValidateFormatText:
+		return writeDiagnosticsText(w, report)
+	case ValidateFormatJSON:
+		return writeDiagnosticsJSON(w, report)
+	case ValidateFormatSARIF:
+		return writeDiagnosticsSARIF(w, report)
+	case ValidateFormatGitHub:
+		return writeDiagnosticsGitHub(w, report)
+	case ValidateFormatAzDo:
+		return writeDiagnosticsAzDo(w, report)
+	}
+	return fmt.Errorf("unsupported validate format '%s', must be one of: %s", format, strings.Join(ValidateFormats, ", "))
+}
+
+func writeDiagnosticsText(w io.Writer, report ValidateReport) error {
+	for _, d := range report.Diagnostics {
+		location := d.Filename
+		if d.Line > 0 {
+			location = fmt.Sprintf("%s:%d", d.Filename, d.Line)
+		}
+		fmt.Fprintf(w, "%s: %s\n", d.Severity, d.Summary)
+		if location != "" {
+			fmt.Fprintf(w, "  on %s (%s/%s)\n", location, d.Level, d.Stack)
+		}
+		if d.Detail != "" {
+			fmt.Fprintf(w, "  %s\n", d.Detail)
+		}
+	}
+	return nil
+}
+
+func writeDiagnosticsJSON(w io.Writer, report ValidateReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// GitHub Actions workflow commands, see https://docs.github.com/actions/reference/workflow-commands-for-github-actions
+func writeDiagnosticsGitHub(w io.Writer, report ValidateReport) error {
+	for _, d := range report.Diagnostics {
+		props := []string{}
+		if d.Filename != "" {
+			props = append(props, "file="+githubEscapeProperty(d.Filename))
+		}
+		if d.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", d.Line), fmt.Sprintf("col=%d", d.Column))
+			props = append(props, fmt.Sprintf("endLine=%d", d.EndLine), fmt.Sprintf("endColumn=%d", d.EndColumn))
+		}
+		props = append(props, "title="+githubEscapeProperty(d.Summary))
+		fmt.Fprintf(w, "::%s %s::%s\n", d.Severity, strings.Join(props, ","), githubEscapeData(diagnosticMessage(d)))
+	}
+	return nil
+}
+
+// Azure DevOps logging commands, see https://docs.microsoft.com/azure/devops/pipelines/scripts/logging-commands
+func writeDiagnosticsAzDo(w io.Writer, report ValidateReport) error {
+	for _, d := range report.Diagnostics {
+		props := []string{"type=" + d.Severity}
+		if d.Filename != "" {
+			props = append(props, "sourcepath="+azdoEscape(d.Filename))
+		}
+		if d.Line > 0 {
+			props = append(props, fmt.Sprintf("linenumber=%d", d.Line), fmt.Sprintf("columnnumber=%d", d.Column))
+		}
+		fmt.Fprintf(w, "##vso[task.logissue %s;]%s\n", strings.Join(props, ";"), azdoEscape(diagnosticMessage(d)))
+	}
+	return nil
+}
+
+// SARIF 2.1.0 for upload to code scanning, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func writeDiagnosticsSARIF(w io.Writer, report ValidateReport) error {
+	results := []sarifResult{}
+	for _, d := range report.Diagnostics {
+		result := sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: diagnosticMessage(d)},
+		}
+		if d.Filename != "" {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.Filename},
+			}}
+			if d.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   d.Line,
+					StartColumn: d.Column,
+					EndLine:     d.EndLine,
+					EndColumn:   d.EndColumn,
+				}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "rover",
+				InformationURI: "https://github.com/aztfmod/rover",
+				Version:        version.Value,
+				Rules: []sarifRule{{
+					ID:               sarifRuleID,
+					ShortDescription: sarifMessage{Text: "Terraform configuration is not valid"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func diagnosticMessage(d Diagnostic) string {
+	if d.Detail == "" {
+		return d.Summary
+	}
+	return d.Summary + ": " + d.Detail
+}
+
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func azdoEscape(s string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D").Replace(s)
+}
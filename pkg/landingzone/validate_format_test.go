@@ -0,0 +1,75 @@
+//go:build unit
+// +build unit
+
+package landingzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testValidateReport = ValidateReport{
+	Valid:        false,
+	ErrorCount:   1,
+	WarningCount: 1,
+	Diagnostics: []Diagnostic{
+		{
+			Level:     "level1",
+			Stack:     "web",
+			Severity:  "error",
+			Summary:   "Unsupported argument",
+			Detail:    "An argument named \"nmae\" is not expected here.\nDid you mean \"name\"?",
+			Filename:  "landingzones/caf_solution/main.tf",
+			Line:      12,
+			Column:    3,
+			EndLine:   12,
+			EndColumn: 7,
+		},
+		{
+			Level:    "level1",
+			Stack:    "web",
+			Severity: "warning",
+			Summary:  "Deprecated, use a; b instead",
+		},
+	},
+}
+
+func Test_Write_Diagnostics_GitHub(t *testing.T) {
+	out := bytes.Buffer{}
+	err := WriteDiagnostics(&out, testValidateReport, ValidateFormatGitHub)
+	assert.Nil(t, err)
+	assert.Equal(t, "::error file=landingzones/caf_solution/main.tf,line=12,col=3,endLine=12,endColumn=7,title=Unsupported argument::Unsupported argument: An argument named \"nmae\" is not expected here.%0ADid you mean \"name\"?\n"+
+		"::warning title=Deprecated%2C use a; b instead::Deprecated, use a; b instead\n", out.String())
+}
+
+func Test_Write_Diagnostics_AzDo(t *testing.T) {
+	out := bytes.Buffer{}
+	err := WriteDiagnostics(&out, testValidateReport, ValidateFormatAzDo)
+	assert.Nil(t, err)
+	assert.Equal(t, "##vso[task.logissue type=error;sourcepath=landingzones/caf_solution/main.tf;linenumber=12;columnnumber=3;]Unsupported argument: An argument named \"nmae\" is not expected here.%0ADid you mean \"name\"?\n"+
+		"##vso[task.logissue type=warning;]Deprecated, use a%3B b instead\n", out.String())
+}
+
+func Test_Write_Diagnostics_SARIF(t *testing.T) {
+	out := bytes.Buffer{}
+	err := WriteDiagnostics(&out, testValidateReport, ValidateFormatSARIF)
+	assert.Nil(t, err)
+
+	sarif := sarifLog{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &sarif))
+	assert.Equal(t, "2.1.0", sarif.Version)
+	results := sarif.Runs[0].Results
+	assert.Len(t, results, 2)
+	assert.Equal(t, "error", results[0].Level)
+	assert.Equal(t, "landingzones/caf_solution/main.tf", results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 12, results[0].Locations[0].PhysicalLocation.Region.StartLine)
+	assert.Empty(t, results[1].Locations)
+}
+
+func Test_Write_Diagnostics_Bad_Format(t *testing.T) {
+	err := WriteDiagnostics(&bytes.Buffer{}, testValidateReport, "xml")
+	assert.NotNil(t, err)
+}
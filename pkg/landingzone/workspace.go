@@ -0,0 +1,133 @@
+//
+// Rover - Workspaces
+// * A workspace is a blob container in the launchpad storage account, holding the state for that workspace
+//
+
+package landingzone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aztfmod/rover/pkg/azure"
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/aztfmod/rover/pkg/utils"
+)
+
+// Workspace names this many edits away are offered as suggestions
+const workspaceSuggestDistance = 3
+
+// CheckWorkspace ensures the workspace container exists in the launchpad storage account
+// When it doesn't, the error suggests any workspaces with a similar name
+func CheckWorkspace(cloud azure.Cloud, storageID string, workspace string) error {
+	containers, err := cloud.ListContainers(storageID)
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		if container == workspace {
+			return nil
+		}
+	}
+
+	suggestions := utils.ClosestMatches(workspace, containers, workspaceSuggestDistance)
+	if len(suggestions) > 0 {
+		return fmt.Errorf("workspace '%s' does not exist in the launchpad storage account, did you mean: %s", workspace, strings.Join(suggestions, ", "))
+	}
+	return fmt.Errorf("workspace '%s' does not exist in the launchpad storage account, it can be created with 'rover workspace create'", workspace)
+}
+
+// workspaceBackupsDirName is where copies of state are saved in the rover home, before a workspace is deleted
+const workspaceBackupsDirName = "workspace-backups"
+
+// workspaceStates returns the names of the stacks with state in a workspace, snapshots & other blobs are left out
+func workspaceStates(cloud azure.Cloud, storageID string, workspace string) ([]string, error) {
+	blobs, err := cloud.ListBlobs(storageID, workspace)
+	if err != nil {
+		return nil, err
+	}
+	stateNames := []string{}
+	for _, blob := range blobs {
+		if strings.HasPrefix(blob.Name, SnapshotsPrefix) || !strings.HasSuffix(blob.Name, ".tfstate") {
+			console.Debugf("Skipping %s, it isn't the state for a stack\n", blob.Name)
+			continue
+		}
+		stateNames = append(stateNames, strings.TrimSuffix(blob.Name, ".tfstate"))
+	}
+	return stateNames, nil
+}
+
+// CopyWorkspace copies the state of every stack in one workspace to another, returning how many were copied
+// State in the destination is snapshotted first, and newer or unrelated state is only replaced when forced
+// Locked state is never replaced, snapshots aren't copied
+func CopyWorkspace(cloud azure.Cloud, conf StateBackendConfig, storageID string, src string, dest string, force bool) (int, error) {
+	stateNames, err := workspaceStates(cloud, storageID, src)
+	if err != nil {
+		return 0, err
+	}
+	tempDir, err := os.MkdirTemp("", "rover-workspace-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	backend := newAzurermBackend(conf)
+	for i, stateName := range stateNames {
+		srcOptions := &Options{Workspace: src, StateName: stateName, StateBackend: conf, Cloud: cloud}
+		destOptions := &Options{Workspace: dest, StateName: stateName, StateBackend: conf, Cloud: cloud}
+
+		lock, err := backend.LockInfo(destOptions, storageID)
+		if err != nil {
+			return i, err
+		}
+		if lock != nil {
+			return i, fmt.Errorf("state %s.tfstate in workspace '%s' is locked by %s, it can't be replaced", stateName, dest, lock.Holder())
+		}
+
+		console.Infof(" - Copying %s.tfstate\n", stateName)
+		stateFile := filepath.Join(tempDir, stateName+".tfstate")
+		_, err = backend.Download(srcOptions, storageID, stateFile)
+		if err != nil {
+			return i, err
+		}
+		err = destOptions.snapshotState(backend, storageID)
+		if err != nil {
+			return i, err
+		}
+		err = destOptions.uploadState(backend, storageID, stateFile, force)
+		if err != nil {
+			return i, fmt.Errorf("unable to copy %s.tfstate: %s", stateName, err)
+		}
+	}
+	return len(stateNames), nil
+}
+
+// BackupWorkspace saves a copy of the state of every stack in a workspace into the rover home, returning the directory
+// Snapshots are kept in the workspace, so they'd be lost with it
+func BackupWorkspace(cloud azure.Cloud, storageID string, workspace string) (string, int, error) {
+	stateNames, err := workspaceStates(cloud, storageID, workspace)
+	if err != nil || len(stateNames) == 0 {
+		return "", 0, err
+	}
+	roverHome, err := rover.HomeDirectory()
+	if err != nil {
+		return "", 0, err
+	}
+	backupDir := filepath.Join(roverHome, workspaceBackupsDirName, fmt.Sprintf("%s-%s", workspace, newSnapshotID(time.Now())))
+	err = os.MkdirAll(backupDir, os.ModePerm)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, stateName := range stateNames {
+		_, err = cloud.DownloadFileFromBlob(storageID, workspace, stateName+".tfstate", filepath.Join(backupDir, stateName+".tfstate"))
+		if err != nil {
+			return "", 0, fmt.Errorf("unable to save a copy of %s.tfstate: %s", stateName, err)
+		}
+	}
+	return backupDir, len(stateNames), nil
+}
@@ -0,0 +1,109 @@
+package rover
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aztfmod/rover/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+const configFileName = "config.yaml"
+
+// Config holds user settings from the rover config file, these apply to every rover run
+// Settings in a symphony config file take precedence over these
+type Config struct {
+	// TerraformVersion is a version constraint which the terraform binary must satisfy, e.g. "~> 1.0.0"
+	TerraformVersion string `yaml:"terraformVersion,omitempty"`
+	// TerraformMirror is a local directory holding terraform release zip archives, for offline installs
+	TerraformMirror string `yaml:"terraformMirror,omitempty"`
+	// PluginCacheDir overrides the provider plugin cache shared by all stacks, default is <rover-home>/plugin-cache
+	PluginCacheDir string `yaml:"pluginCacheDir,omitempty"`
+	// ProviderMirror is a filesystem provider mirror, when set providers are only installed from it
+	ProviderMirror string `yaml:"providerMirror,omitempty"`
+	// Timeouts limit how long each action may run against a stack, keyed by action name or "default" for all actions
+	// Values are durations, e.g. "45m" or "2h"
+	Timeouts map[string]string `yaml:"timeouts,omitempty"`
+	// Retry controls how operations which fail with known transient errors are retried
+	Retry *RetryConfig `yaml:"retry,omitempty"`
+	// AzureADAuth has rover and terraform access state with the signed in identity, rather than storage account keys
+	// Needed when shared key access is disabled on the launchpad storage account
+	AzureADAuth bool `yaml:"azureADAuth,omitempty"`
+	// Snapshots controls the copies of state taken before each apply, destroy or state change
+	Snapshots *SnapshotConfig `yaml:"snapshots,omitempty"`
+	// Cloud chooses a sovereign or custom Azure cloud, such as Azure Stack Hub
+	Cloud *CloudConfig `yaml:"cloud,omitempty"`
+	// LaunchpadTags are the tag names which identify launchpad storage accounts & key vaults
+	LaunchpadTags *LaunchpadTagsConfig `yaml:"launchpadTags,omitempty"`
+}
+
+// LaunchpadTagsConfig renames the launchpad tags, for organisations with their own tagging scheme
+type LaunchpadTagsConfig struct {
+	// Level is the tag holding the CAF level, default is "level"
+	Level string `yaml:"level,omitempty"`
+	// Environment is the tag holding the CAF environment, default is "environment"
+	Environment string `yaml:"environment,omitempty"`
+}
+
+// RetryConfig is the retry policy for transient failures, such as state blob leases or Azure throttling
+type RetryConfig struct {
+	// Attempts is the most times an operation is tried, 1 disables retries
+	Attempts int `yaml:"attempts,omitempty"`
+	// Delay is the wait before the first retry, it doubles for each retry after that, e.g. "30s"
+	Delay string `yaml:"delay,omitempty"`
+}
+
+// SnapshotConfig controls state snapshots
+type SnapshotConfig struct {
+	// Retain is how many snapshots are kept for each stack, the oldest are removed first
+	// Zero keeps the default of 10, a negative value stops snapshots being taken
+	Retain int `yaml:"retain,omitempty"`
+}
+
+// CloudConfig defines the Azure cloud, starting from a well known cloud or one loaded from ARM metadata
+// Any endpoints given override those of the starting cloud, a custom cloud has to give them all
+type CloudConfig struct {
+	// Name of a well known cloud (AzureCloud, AzureChinaCloud, AzureUSGovernment), or of a custom cloud
+	Name string `yaml:"name,omitempty"`
+	// MetadataHost loads the cloud from the ARM metadata endpoint on this host, e.g. management.local.azurestack.external
+	MetadataHost string `yaml:"metadataHost,omitempty"`
+	// TerraformName is the azurerm environment for the cloud, e.g. public, china or usgovernment
+	TerraformName string `yaml:"terraformName,omitempty"`
+	// ResourceManager is the Azure Resource Manager endpoint, e.g. https://management.azure.com/
+	ResourceManager string `yaml:"resourceManager,omitempty"`
+	// ActiveDirectory is the Azure AD login endpoint, e.g. https://login.microsoftonline.com/
+	ActiveDirectory string `yaml:"activeDirectory,omitempty"`
+	// Graph is the Microsoft Graph endpoint, e.g. https://graph.microsoft.com/
+	Graph string `yaml:"graph,omitempty"`
+	// KeyVaultDNS is the key vault DNS suffix, e.g. vault.azure.net
+	KeyVaultDNS string `yaml:"keyVaultDNS,omitempty"`
+	// StorageSuffix is the storage endpoint suffix, e.g. core.windows.net
+	StorageSuffix string `yaml:"storageSuffix,omitempty"`
+}
+
+var config *Config
+
+// LoadConfig returns the rover config, read from config.yaml (or .yml) in the rover home directory
+// A missing file is not an error, an empty config is returned
+func LoadConfig() (*Config, error) {
+	if config != nil {
+		return config, nil
+	}
+
+	roverHome, err := HomeDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &Config{}
+	content, configFile, err := utils.ReadYamlFile(filepath.Join(roverHome, configFileName))
+	if err == nil {
+		err = yaml.UnmarshalStrict(content, conf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml in %s. Internal Error:%s", configFile, err.Error())
+		}
+	}
+
+	config = conf
+	return config, nil
+}
@@ -55,6 +55,8 @@ func HomeDirectory() (string, error) {
 
 func SetHomeDirectory(dir string) {
 	homeDir = dir
+	// Config is held in the home directory, so it needs to be read again
+	config = nil
 }
 
 func createDefaultContents(roverHomePath string) error {
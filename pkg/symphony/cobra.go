@@ -13,12 +13,14 @@ func BuildOptions(cmd *cobra.Command) []landingzone.Options {
 	configFile, _ := cmd.Flags().GetString("config-file")
 	sourcePath, _ := cmd.Flags().GetString("source")
 	levelName, _ := cmd.Flags().GetString("level")
+	stackName, _ := cmd.Flags().GetString("stack")
 	env, _ := cmd.Flags().GetString("environment")
 	stateName, _ := cmd.Flags().GetString("statename")
 	ws, _ := cmd.Flags().GetString("workspace")
 	stateSub, _ := cmd.Flags().GetString("state-sub")
 	targetSub, _ := cmd.Flags().GetString("target-sub")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
 
 	if launchPadMode || env != "" || ws != "" || stateName != "" || stateSub != "" || targetSub != "" || sourcePath != "" {
 		cobra.CheckErr("Do not supply any options other than level when using a config file")
@@ -30,10 +32,11 @@ func BuildOptions(cmd *cobra.Command) []landingzone.Options {
 	if levelName == "" {
 		console.Info("Rover will operate on ALL levels...")
 		isDestroy := cmd.Name() == "destroy"
-		optionsList := conf.parseAllLevels(isDestroy)
+		optionsList := filterStack(conf.parseAllLevels(isDestroy), stackName)
 		// We munge some options here rather than passing it through all the parser functions
 		for i := range optionsList {
 			optionsList[i].DryRun = dryRun
+			optionsList[i].Timeout = timeout
 		}
 		return optionsList
 	}
@@ -55,16 +58,36 @@ func BuildOptions(cmd *cobra.Command) []landingzone.Options {
 	// nolint
 	console.Infof("Rover will operate on level '%s'...\n", level.Name)
 	// nolint
-	optionsList := conf.parseLevel(*level)
+	optionsList := filterStack(conf.parseLevel(*level), stackName)
 
 	// We munge some options here rather than passing it through all the parser functions
 	for i := range optionsList {
 		optionsList[i].DryRun = dryRun
+		optionsList[i].Timeout = timeout
 	}
 
 	return optionsList
 }
 
+// filterStack narrows the options down to a single named stack, when one was requested
+func filterStack(optionsList []landingzone.Options, stackName string) []landingzone.Options {
+	if stackName == "" {
+		return optionsList
+	}
+
+	filtered := []landingzone.Options{}
+	for _, opt := range optionsList {
+		if opt.Stack == stackName {
+			filtered = append(filtered, opt)
+		}
+	}
+
+	if len(filtered) == 0 {
+		cobra.CheckErr(fmt.Sprintf("stack '%s' not found in symphony config file", stackName))
+	}
+	return filtered
+}
+
 func SetDry(o *landingzone.Options) {
 	o.DryRun = true
 }
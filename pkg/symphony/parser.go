@@ -67,18 +67,33 @@ func (c Config) parseStack(level Level, stack *Stack) landingzone.Options {
 		stateName = stack.Name
 	}
 
+	timeouts := map[string]string{}
+	for action, timeout := range c.Content.Timeouts {
+		timeouts[action] = timeout
+	}
+	for action, timeout := range stack.Timeouts {
+		timeouts[action] = timeout
+	}
+
 	opt := landingzone.Options{
-		Level:          level.Name,
-		LaunchPadMode:  level.Launchpad,
-		CafEnvironment: cafEnv,
-		StateName:      stateName,
-		Workspace:      ws,
+		Level:            level.Name,
+		Stack:            stack.Name,
+		LaunchPadMode:    level.Launchpad,
+		CafEnvironment:   cafEnv,
+		StateName:        stateName,
+		Workspace:        ws,
+		TerraformVersion: c.Content.TerraformVersion,
+		Timeouts:         timeouts,
+		Retry:            c.Content.Retry,
+		Snapshots:        c.Content.Snapshots,
 	}
 
 	// Safely set the paths up
 	opt.SetSourcePath(sourcePath)
 	opt.SetConfigPath(configPath)
-	err := opt.SetDataDir()
+	err := opt.SetStateBackend(c.Content.StateBackend)
+	cobra.CheckErr(err)
+	err = opt.SetDataDir()
 	cobra.CheckErr(err)
 
 	return opt
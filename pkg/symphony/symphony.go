@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/landingzone"
+	"github.com/aztfmod/rover/pkg/rover"
 	"gopkg.in/yaml.v2"
 )
 
@@ -16,7 +18,17 @@ type Config struct {
 		Environment     string `yaml:"environment,omitempty"`
 		LandingZonePath string `yaml:"landingZonePath,omitempty"`
 		Workspace       string
-		Repositories    []struct {
+		// TerraformVersion is a version constraint all stacks must be run with, e.g. "~> 1.0.0"
+		TerraformVersion string `yaml:"terraformVersion,omitempty"`
+		// Timeouts limit how long each action may run against a stack, keyed by action name or "default"
+		Timeouts map[string]string `yaml:"timeouts,omitempty"`
+		// Retry is the policy for retrying transient failures, overriding the rover config file
+		Retry *rover.RetryConfig `yaml:"retry,omitempty"`
+		// Snapshots controls the state snapshots taken before changes, overriding the rover config file
+		Snapshots *rover.SnapshotConfig `yaml:"snapshots,omitempty"`
+		// StateBackend chooses where terraform state is held, azurerm when not set
+		StateBackend landingzone.StateBackendConfig `yaml:"stateBackend,omitempty"`
+		Repositories []struct {
 			Name   string `yaml:"name,omitempty"`
 			URI    string `yaml:"uri,omitempty"`
 			Branch string `yaml:"branch,omitempty"`
@@ -37,6 +49,8 @@ type Stack struct {
 	LandingZonePath   string `yaml:"landingZonePath,omitempty"`
 	ConfigurationPath string `yaml:"configurationPath,omitempty"`
 	TfState           string `yaml:"tfState,omitempty"`
+	// Timeouts for this stack only, these are merged over the top level timeouts
+	Timeouts map[string]string `yaml:"timeouts,omitempty"`
 }
 
 func NewSymphonyConfig(symphonyConfigFileName string) (*Config, error) {
@@ -0,0 +1,211 @@
+//
+// Rover - Terraform provider plugin cache
+// * All stacks share one plugin cache in the rover home, so providers are only downloaded once
+// * Optionally providers can be installed only from a filesystem mirror, for offline use
+//
+
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/hashicorp/go-version"
+)
+
+const pluginCacheDirName = "plugin-cache"
+const cliConfigFileName = "terraform.rc"
+
+// PluginCacheDir returns the shared provider plugin cache, from the rover config or the default in the rover home
+func PluginCacheDir() (string, error) {
+	conf, err := rover.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if conf.PluginCacheDir != "" {
+		return filepath.Abs(conf.PluginCacheDir)
+	}
+
+	roverHome, err := rover.HomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(roverHome, pluginCacheDirName), nil
+}
+
+// providerInstallationRegex finds a provider_installation block in a terraform CLI config file
+var providerInstallationRegex = regexp.MustCompile(`(?m)^\s*provider_installation\s*\{`)
+
+var setupOnce sync.Once
+var setupErr error
+
+// SetupPluginCache points terraform at the shared plugin cache, and the provider mirror if one is configured
+// It's only done once per run, so the environment rover sets up isn't mistaken for the user's on the next stack
+func SetupPluginCache() error {
+	setupOnce.Do(func() {
+		setupErr = setupPluginCache()
+	})
+	return setupErr
+}
+
+func setupPluginCache() error {
+	conf, err := rover.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// A cache dir set by the user is left alone, otherwise the shared one is used
+	if cacheDir := os.Getenv("TF_PLUGIN_CACHE_DIR"); cacheDir != "" {
+		console.Debugf("TF_PLUGIN_CACHE_DIR is set, using provider plugin cache %s\n", cacheDir)
+	} else {
+		cacheDir, err := PluginCacheDir()
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(cacheDir, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		console.Debugf("Using provider plugin cache %s\n", cacheDir)
+		err = os.Setenv("TF_PLUGIN_CACHE_DIR", cacheDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if conf.ProviderMirror == "" {
+		return nil
+	}
+	mirror, err := filepath.Abs(conf.ProviderMirror)
+	if err != nil {
+		return err
+	}
+
+	// The mirror needs a CLI config file, it's the user's with the mirror added so their credentials etc. still apply
+	userConfigFile, err := userCLIConfigFile()
+	if err != nil {
+		return err
+	}
+	userConfig, err := os.ReadFile(userConfigFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if providerInstallationRegex.Match(userConfig) {
+		console.Warningf("%s has a provider_installation block, provider mirror %s will not be used\n", userConfigFile, mirror)
+		return nil
+	}
+
+	// Written every run, so changes to the rover config and the user's file are always picked up
+	cliConfig := string(userConfig)
+	if cliConfig != "" && !strings.HasSuffix(cliConfig, "\n") {
+		cliConfig += "\n"
+	}
+	cliConfig += fmt.Sprintf("\n# Added by rover from providerMirror in the rover config file\nprovider_installation {\n  filesystem_mirror {\n    path = %q\n  }\n}\n", filepath.ToSlash(mirror))
+
+	roverHome, err := rover.HomeDirectory()
+	if err != nil {
+		return err
+	}
+	cliConfigFile := filepath.Join(roverHome, cliConfigFileName)
+	err = os.WriteFile(cliConfigFile, []byte(cliConfig), 0600)
+	if err != nil {
+		return err
+	}
+
+	console.Infof("Providers will only be installed from mirror %s\n", mirror)
+	return os.Setenv("TF_CLI_CONFIG_FILE", cliConfigFile)
+}
+
+// userCLIConfigFile returns the terraform CLI config file terraform would use without rover, it may not exist
+func userCLIConfigFile() (string, error) {
+	if configFile := os.Getenv("TF_CLI_CONFIG_FILE"); configFile != "" {
+		return configFile, nil
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "terraform.rc"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".terraformrc"), nil
+}
+
+// PrunePluginCache removes all but the newest version of each provider from the plugin cache
+// When all is set the cache is emptied. Removed paths are returned, nothing is removed when dryRun is set
+func PrunePluginCache(all bool, dryRun bool) ([]string, error) {
+	cacheDir, err := PluginCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	// The cache layout is <hostname>/<namespace>/<type>/<version>/<os_arch>
+	providerDirs, err := filepath.Glob(filepath.Join(cacheDir, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, providerDir := range providerDirs {
+		versionDirs, err := os.ReadDir(providerDir)
+		if err != nil {
+			return nil, err
+		}
+
+		versions := []*version.Version{}
+		for _, dir := range versionDirs {
+			ver, err := version.NewVersion(dir.Name())
+			if err != nil {
+				continue
+			}
+			versions = append(versions, ver)
+		}
+		sort.Sort(sort.Reverse(version.Collection(versions)))
+
+		for i, ver := range versions {
+			if i == 0 && !all {
+				continue
+			}
+			removed = append(removed, filepath.Join(providerDir, ver.Original()))
+		}
+	}
+
+	for _, path := range removed {
+		rel, _ := filepath.Rel(cacheDir, path)
+		if dryRun {
+			console.Infof(" - Would remove %s\n", rel)
+			continue
+		}
+		console.Infof(" - Removing %s\n", rel)
+		err = os.RemoveAll(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if all && !dryRun {
+		// Clear out anything else, e.g. empty provider directories
+		entries, err := os.ReadDir(cacheDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			err = os.RemoveAll(filepath.Join(cacheDir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return removed, nil
+}
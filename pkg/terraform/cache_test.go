@@ -0,0 +1,107 @@
+//go:build unit
+// +build unit
+
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Prune_Plugin_Cache(t *testing.T) {
+	// Arrange
+	roverHome := t.TempDir()
+	rover.SetHomeDirectory(roverHome)
+	provider := filepath.Join(roverHome, "plugin-cache", "registry.terraform.io", "hashicorp", "azurerm")
+	for _, ver := range []string{"2.9.0", "2.75.0", "2.10.0"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(provider, ver, "linux_amd64"), os.ModePerm))
+	}
+
+	// Act
+	dryRemoved, dryErr := PrunePluginCache(false, true)
+	removed, err := PrunePluginCache(false, false)
+
+	// Assert
+	assert.NoError(t, dryErr)
+	assert.Len(t, dryRemoved, 2)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(provider, "2.10.0"), filepath.Join(provider, "2.9.0")}, removed)
+	assert.DirExists(t, filepath.Join(provider, "2.75.0"))
+	assert.NoDirExists(t, filepath.Join(provider, "2.9.0"))
+}
+
+func Test_Prune_Plugin_Cache_All(t *testing.T) {
+	// Arrange
+	roverHome := t.TempDir()
+	rover.SetHomeDirectory(roverHome)
+	provider := filepath.Join(roverHome, "plugin-cache", "registry.terraform.io", "hashicorp", "random")
+	assert.NoError(t, os.MkdirAll(filepath.Join(provider, "3.1.0", "linux_amd64"), os.ModePerm))
+
+	// Act
+	removed, err := PrunePluginCache(true, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.NoDirExists(t, filepath.Join(roverHome, "plugin-cache", "registry.terraform.io"))
+}
+
+// setEnv sets or unsets env vars for a test, restoring them after
+func setEnv(t *testing.T, env map[string]string) {
+	for name, value := range env {
+		name := name
+		saved, set := os.LookupEnv(name)
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, saved)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func Test_Setup_Plugin_Cache(t *testing.T) {
+	roverHome := t.TempDir()
+	rover.SetHomeDirectory(roverHome)
+	setEnv(t, map[string]string{"TF_PLUGIN_CACHE_DIR": "", "TF_CLI_CONFIG_FILE": ""})
+
+	// Without a mirror only the cache dir is set, the user's CLI config is left alone
+	err := setupPluginCache()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(roverHome, "plugin-cache"), os.Getenv("TF_PLUGIN_CACHE_DIR"))
+	assert.Equal(t, "", os.Getenv("TF_CLI_CONFIG_FILE"))
+
+	// With a mirror the user's CLI config is kept, with the mirror added
+	userConfig := filepath.Join(t.TempDir(), "user.tfrc")
+	assert.NoError(t, os.WriteFile(userConfig, []byte("credentials \"app.terraform.io\" {\n  token = \"abc\"\n}"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(roverHome, "config.yaml"), []byte("providerMirror: /mnt/mirror\n"), 0644))
+	rover.SetHomeDirectory(roverHome)
+	setEnv(t, map[string]string{"TF_PLUGIN_CACHE_DIR": "/tmp/my-cache", "TF_CLI_CONFIG_FILE": userConfig})
+
+	err = setupPluginCache()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/my-cache", os.Getenv("TF_PLUGIN_CACHE_DIR"))
+	cliConfigFile := filepath.Join(roverHome, "terraform.rc")
+	assert.Equal(t, cliConfigFile, os.Getenv("TF_CLI_CONFIG_FILE"))
+	cliConfig, err := os.ReadFile(cliConfigFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cliConfig), "token = \"abc\"")
+	assert.Contains(t, string(cliConfig), "path = \"/mnt/mirror\"")
+
+	// A provider_installation block of the user's isn't replaced
+	assert.NoError(t, os.WriteFile(userConfig, []byte("provider_installation {\n  direct {}\n}\n"), 0600))
+	setEnv(t, map[string]string{"TF_CLI_CONFIG_FILE": userConfig})
+	err = setupPluginCache()
+	assert.NoError(t, err)
+	assert.Equal(t, userConfig, os.Getenv("TF_CLI_CONFIG_FILE"))
+}
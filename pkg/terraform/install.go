@@ -0,0 +1,329 @@
+//
+// Rover - Terraform installer
+// * Installs pinned versions of Terraform into the rover home, from a local mirror or releases.hashicorp.com
+//
+
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/aztfmod/rover/pkg/utils"
+	"github.com/hashicorp/go-version"
+)
+
+const releasesURL = "https://releases.hashicorp.com/terraform"
+const installDirName = "terraform"
+
+var archiveNameRegex = regexp.MustCompile(`^terraform_(.+)_` + runtime.GOOS + `_` + runtime.GOARCH + `\.zip$`)
+
+// InstallDir is where rover keeps installed versions of terraform, one sub directory per version
+func InstallDir() (string, error) {
+	roverHome, err := rover.HomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(roverHome, installDirName), nil
+}
+
+// Install places a terraform binary matching the version or constraint into the rover home
+// Archives are taken from the mirror directory when present, otherwise they are downloaded unless offline is set
+func Install(ctx context.Context, versionOrConstraint string, mirror string, offline bool) (string, error) {
+	constraint, err := version.NewConstraint(versionOrConstraint)
+	if err != nil {
+		return "", err
+	}
+
+	// Try the versions already installed first, so nothing is fetched when it doesn't need to be
+	path, _ := findInstalled(constraint)
+	if path != "" {
+		console.Successf("Terraform matching %s is already installed at %s\n", versionOrConstraint, path)
+		return path, nil
+	}
+
+	candidates, err := mirrorVersions(mirror)
+	if err != nil {
+		return "", err
+	}
+	if !offline {
+		released, err := releasedVersions(ctx)
+		if err != nil {
+			return "", err
+		}
+		candidates = append(candidates, released...)
+	}
+	tfVer := highestMatching(candidates, constraint)
+	if tfVer == nil {
+		return "", fmt.Errorf("no terraform release matching %s was found", versionOrConstraint)
+	}
+
+	installDir, err := InstallDir()
+	if err != nil {
+		return "", err
+	}
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", tfVer, runtime.GOOS, runtime.GOARCH)
+
+	archivePath := ""
+	if mirror != "" && utils.FileExists(filepath.Join(mirror, archiveName)) {
+		archivePath = filepath.Join(mirror, archiveName)
+		console.Infof("Installing terraform %s from mirror %s\n", tfVer, mirror)
+		// Mirrors aren't required to hold checksums, but when they do they are checked
+		sumsFile := filepath.Join(mirror, fmt.Sprintf("terraform_%s_SHA256SUMS", tfVer))
+		if utils.FileExists(sumsFile) {
+			sums, err := os.Open(sumsFile)
+			if err != nil {
+				return "", err
+			}
+			defer sums.Close()
+			if err := verifyChecksum(archivePath, archiveName, sums); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		if offline {
+			return "", fmt.Errorf("terraform %s is not in the mirror and rover is offline", tfVer)
+		}
+		console.Infof("Downloading terraform %s from %s\n", tfVer, releasesURL)
+		archivePath, err = downloadRelease(ctx, tfVer.String(), archiveName)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(archivePath)
+	}
+
+	return unzipBinary(archivePath, filepath.Join(installDir, tfVer.String()))
+}
+
+// findInstalled returns the path of the highest installed version matching the constraint
+func findInstalled(constraint version.Constraints) (string, *version.Version) {
+	installDir, err := InstallDir()
+	if err != nil {
+		return "", nil
+	}
+	dirs, err := os.ReadDir(installDir)
+	if err != nil {
+		return "", nil
+	}
+
+	installed := []*version.Version{}
+	for _, dir := range dirs {
+		ver, err := version.NewVersion(dir.Name())
+		if err != nil || !utils.FileExists(filepath.Join(installDir, dir.Name(), binaryName())) {
+			continue
+		}
+		installed = append(installed, ver)
+	}
+
+	tfVer := highestMatching(installed, constraint)
+	if tfVer == nil {
+		return "", nil
+	}
+	return filepath.Join(installDir, tfVer.Original(), binaryName()), tfVer
+}
+
+func highestMatching(versions []*version.Version, constraint version.Constraints) *version.Version {
+	sort.Sort(sort.Reverse(version.Collection(versions)))
+	for _, ver := range versions {
+		// Skip pre-releases unless asked for explicitly
+		if ver.Prerelease() != "" && !strings.Contains(constraint.String(), ver.Prerelease()) {
+			continue
+		}
+		if constraint.Check(ver) {
+			return ver
+		}
+	}
+	return nil
+}
+
+// mirrorVersions lists the versions of terraform held as zip archives in the mirror directory
+func mirrorVersions(mirror string) ([]*version.Version, error) {
+	versions := []*version.Version{}
+	if mirror == "" {
+		return versions, nil
+	}
+
+	files, err := os.ReadDir(mirror)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terraform mirror directory: %s", err)
+	}
+	for _, file := range files {
+		matches := archiveNameRegex.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+		ver, err := version.NewVersion(matches[1])
+		if err == nil {
+			versions = append(versions, ver)
+		}
+	}
+	return versions, nil
+}
+
+// releasedVersions lists all the versions of terraform published by HashiCorp
+func releasedVersions(ctx context.Context) ([]*version.Version, error) {
+	resp, err := httpGet(ctx, releasesURL+"/index.json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	index := struct {
+		Versions map[string]interface{} `json:"versions"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&index)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []*version.Version{}
+	for v := range index.Versions {
+		ver, err := version.NewVersion(v)
+		if err == nil {
+			versions = append(versions, ver)
+		}
+	}
+	return versions, nil
+}
+
+// downloadRelease fetches a release archive to a temp file and verifies it against the published checksums
+func downloadRelease(ctx context.Context, tfVer string, archiveName string) (string, error) {
+	resp, err := httpGet(ctx, fmt.Sprintf("%s/%s/%s", releasesURL, tfVer, archiveName))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	archive, err := ioutil.TempFile("", "terraform-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+	_, err = io.Copy(archive, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	sumsResp, err := httpGet(ctx, fmt.Sprintf("%s/%s/terraform_%s_SHA256SUMS", releasesURL, tfVer, tfVer))
+	if err != nil {
+		return "", err
+	}
+	defer sumsResp.Body.Close()
+
+	err = verifyChecksum(archive.Name(), archiveName, sumsResp.Body)
+	if err != nil {
+		os.Remove(archive.Name())
+		return "", err
+	}
+	return archive.Name(), nil
+}
+
+// verifyChecksum checks a file against its entry in a SHA256SUMS file
+func verifyChecksum(filePath string, archiveName string, sums io.Reader) error {
+	expected := ""
+	scanner := bufio.NewScanner(sums)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == archiveName {
+			expected = fields[0]
+		}
+	}
+	if expected == "" {
+		return fmt.Errorf("no checksum found for %s", archiveName)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s, expected %s got %s", archiveName, expected, actual)
+	}
+	return nil
+}
+
+// unzipBinary extracts the terraform binary from a release archive into the destination dir
+func unzipBinary(archivePath string, destDir string) (string, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name != binaryName() {
+			continue
+		}
+
+		err = os.MkdirAll(destDir, os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+		src, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+
+		destPath := filepath.Join(destDir, binaryName())
+		dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return "", err
+		}
+		defer dest.Close()
+		if _, err := io.Copy(dest, src); err != nil {
+			return "", err
+		}
+
+		console.Successf("Terraform installed at %s\n", destPath)
+		return destPath, nil
+	}
+
+	return "", fmt.Errorf("archive %s does not contain %s", archivePath, binaryName())
+}
+
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request to %s failed with %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "terraform.exe"
+	}
+	return "terraform"
+}
@@ -0,0 +1,86 @@
+//go:build unit
+// +build unit
+
+package terraform
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aztfmod/rover/pkg/rover"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Install_From_Mirror(t *testing.T) {
+	// Arrange
+	roverHome := t.TempDir()
+	rover.SetHomeDirectory(roverHome)
+	mirror := t.TempDir()
+	createMirrorArchive(t, mirror, "1.0.4", true)
+	createMirrorArchive(t, mirror, "1.0.11", true)
+	createMirrorArchive(t, mirror, "1.1.0", true)
+
+	// Act
+	path, err := Install(context.Background(), "~> 1.0.0", mirror, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(roverHome, "terraform", "1.0.11", binaryName()), path)
+	assert.FileExists(t, path)
+}
+
+func Test_Install_Bad_Checksum(t *testing.T) {
+	// Arrange
+	rover.SetHomeDirectory(t.TempDir())
+	mirror := t.TempDir()
+	createMirrorArchive(t, mirror, "1.0.4", false)
+
+	// Act
+	_, err := Install(context.Background(), "1.0.4", mirror, true)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func Test_Install_Offline_Not_In_Mirror(t *testing.T) {
+	// Arrange
+	rover.SetHomeDirectory(t.TempDir())
+
+	// Act
+	_, err := Install(context.Background(), "1.0.4", t.TempDir(), true)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func createMirrorArchive(t *testing.T, mirror string, tfVer string, goodChecksum bool) {
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", tfVer, runtime.GOOS, runtime.GOARCH)
+	archivePath := filepath.Join(mirror, archiveName)
+
+	file, err := os.Create(archivePath)
+	assert.NoError(t, err)
+	zipWriter := zip.NewWriter(file)
+	bin, err := zipWriter.Create(binaryName())
+	assert.NoError(t, err)
+	_, _ = bin.Write([]byte("#!/bin/sh\necho " + tfVer))
+	assert.NoError(t, zipWriter.Close())
+	assert.NoError(t, file.Close())
+
+	content, err := os.ReadFile(archivePath)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(content)
+	if !goodChecksum {
+		sum[0]++
+	}
+	sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), archiveName)
+	err = os.WriteFile(filepath.Join(mirror, fmt.Sprintf("terraform_%s_SHA256SUMS", tfVer)), []byte(sums), 0644)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,131 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// On Linux tfexec starts terraform in its own process group, so a Ctrl-C in the terminal never reaches it
+// Instead rover forwards signals to the process group of each terraform it has started
+// Terraform treats a second interrupt as a request to stop immediately, so each process is only interrupted once
+
+// CanInterrupt is true when rover can interrupt terraform itself, e.g. when an action times out
+const CanInterrupt = true
+
+var interruptedMu sync.Mutex
+var interrupted = map[int]bool{}
+
+// Interrupt asks any running terraform to stop cleanly, terraform will finish in-flight operations and release state locks
+func Interrupt() error {
+	return signalTerraform(syscall.SIGINT)
+}
+
+// Kill stops any running terraform immediately, state locks may be left behind
+func Kill() error {
+	return signalTerraform(syscall.SIGKILL)
+}
+
+// setProcAttr starts commands the same way tfexec does, so Interrupt & Kill reach them too
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+		Setpgid:   true,
+	}
+}
+
+// interruptCmd interrupts a command started by Run or Passthrough, signalling its process group when it leads one
+func interruptCmd(cmd *exec.Cmd) error {
+	return signalCmd(cmd, syscall.SIGINT)
+}
+
+// killCmd kills a command started by Run or Passthrough
+func killCmd(cmd *exec.Cmd) error {
+	return signalCmd(cmd, syscall.SIGKILL)
+}
+
+func signalCmd(cmd *exec.Cmd, sig syscall.Signal) error {
+	pid := cmd.Process.Pid
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		pid = -pid
+	}
+	err := signalOnce(pid, sig)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// signalOnce sends a signal to a process, or a process group when pid is negative, SIGINT is only sent once
+func signalOnce(pid int, sig syscall.Signal) error {
+	if sig == syscall.SIGINT {
+		interruptedMu.Lock()
+		defer interruptedMu.Unlock()
+		if interrupted[pid] {
+			return nil
+		}
+		interrupted[pid] = true
+	}
+	return syscall.Kill(pid, sig)
+}
+
+func signalTerraform(sig syscall.Signal) error {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return err
+	}
+
+	for _, statFile := range stats {
+		data, err := os.ReadFile(statFile)
+		if err != nil {
+			// The process may have exited since the glob
+			continue
+		}
+		pid, ppid, pgid, err := parseProcStat(string(data))
+		if err != nil {
+			continue
+		}
+		// Only our own children which lead their own process group, i.e. those started by tfexec or Run
+		if ppid != os.Getpid() || pgid != pid {
+			continue
+		}
+		err = signalOnce(-pgid, sig)
+		if err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("unable to signal terraform process %d: %s", pid, err)
+		}
+	}
+	return nil
+}
+
+// parseProcStat gets the pid, parent pid and process group from the contents of /proc/<pid>/stat
+func parseProcStat(stat string) (int, int, int, error) {
+	// The command name is in brackets and can itself contain spaces or brackets, so start after the last one
+	nameEnd := strings.LastIndex(stat, ")")
+	if nameEnd < 0 {
+		return 0, 0, 0, fmt.Errorf("unexpected format of process stat")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(stat, "(", 2)[0]))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// Fields after the name are: state ppid pgrp ...
+	fields := strings.Fields(stat[nameEnd+1:])
+	if len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected format of process stat")
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	pgid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return pid, ppid, pgid, nil
+}
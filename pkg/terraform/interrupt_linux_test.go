@@ -0,0 +1,45 @@
+//go:build unit
+// +build unit
+
+package terraform
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse_Proc_Stat(t *testing.T) {
+	pid, ppid, pgid, err := parseProcStat("4321 (terraform) S 1234 4321 1200 34816 4321 4194304 2451 0 0 0")
+	assert.Nil(t, err)
+	assert.Equal(t, 4321, pid)
+	assert.Equal(t, 1234, ppid)
+	assert.Equal(t, 4321, pgid)
+
+	// Process names can contain spaces and brackets
+	pid, ppid, pgid, err = parseProcStat("99 (my (odd) proc) R 98 97 97 0 -1 4194560")
+	assert.Nil(t, err)
+	assert.Equal(t, 99, pid)
+	assert.Equal(t, 98, ppid)
+	assert.Equal(t, 97, pgid)
+
+	_, _, _, err = parseProcStat("not a stat")
+	assert.NotNil(t, err)
+}
+
+func Test_Run_Cmd_Interrupts_On_Cancel(t *testing.T) {
+	// Stands in for terraform, which stops cleanly on an interrupt
+	cmd := exec.Command("sh", "-c", "trap 'exit 3' INT; while true; do sleep 0.1; done")
+	setProcAttr(cmd)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	exitCode, err := runCmd(ctx, cmd, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, exitCode)
+	assert.Less(t, int64(time.Since(started)), int64(KillGracePeriod))
+}
@@ -0,0 +1,48 @@
+//go:build !linux
+// +build !linux
+
+package terraform
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Elsewhere terraform shares the console & process group with rover, so it gets Ctrl-C directly from the terminal
+// and handles it the same way, stopping cleanly on the first and immediately on the second
+
+// CanInterrupt is true when rover can interrupt terraform itself, e.g. when an action times out
+// Here rover doesn't know which processes tfexec has started, so only Ctrl-C from the terminal reaches them
+const CanInterrupt = false
+
+// Interrupt asks any running terraform to stop cleanly, here terraform has already been sent the signal
+func Interrupt() error {
+	return nil
+}
+
+// Kill stops any running terraform immediately, here terraform has already been sent the signal
+func Kill() error {
+	return nil
+}
+
+func setProcAttr(cmd *exec.Cmd) {
+}
+
+// interruptCmd interrupts a command started by Run or Passthrough, e.g. when an action times out
+// Windows can't send an interrupt to a process, there terraform is only killed after the grace period
+func interruptCmd(cmd *exec.Cmd) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	err := cmd.Process.Signal(os.Interrupt)
+	if err == os.ErrProcessDone {
+		return nil
+	}
+	return err
+}
+
+// killCmd kills a command started by Run or Passthrough
+func killCmd(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
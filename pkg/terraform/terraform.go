@@ -9,11 +9,15 @@ package terraform
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aztfmod/rover/pkg/console"
+	"github.com/aztfmod/rover/pkg/rover"
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/hashicorp/terraform-exec/tfinstall"
@@ -21,50 +25,97 @@ import (
 
 var requiredMinVer, _ = version.NewVersion("0.15.0")
 
-// Setup gets a path to Terraform, optionally install it, and check version
-func Setup() (string, error) {
+// KillGracePeriod is how long terraform is given to stop cleanly once interrupted, before it's killed
+const KillGracePeriod = 5 * time.Minute
 
-	// First look in system path & installPath
+// Setup gets a path to Terraform and checks its version
+// When a version constraint is given, or set in the rover config, versions installed by rover are preferred
+// and the version found must satisfy the constraint
+func Setup(versionConstraint string) (string, *version.Version, error) {
+	if versionConstraint == "" {
+		conf, err := rover.LoadConfig()
+		if err != nil {
+			return "", nil, err
+		}
+		versionConstraint = conf.TerraformVersion
+	}
+
+	var constraint version.Constraints
+	if versionConstraint != "" {
+		var err error
+		constraint, err = version.NewConstraint(versionConstraint)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid terraform version constraint '%s': %s", versionConstraint, err)
+		}
+
+		installedPath, _ := findInstalled(constraint)
+		if installedPath != "" {
+			tfVer, err := CheckVersion(installedPath)
+			return installedPath, tfVer, err
+		}
+	}
+
+	// Then look in system path
 	path, err := tfinstall.Find(context.Background(), tfinstall.LookPath())
 	if err != nil {
-		return "", err
+		if constraint != nil {
+			return "", nil, fmt.Errorf("no terraform matching %s found, install it with 'rover terraform install'", versionConstraint)
+		}
+		return "", nil, err
 	}
 
-	err = CheckVersion(path)
+	tfVer, err := CheckVersion(path)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	if constraint != nil && !constraint.Check(tfVer) {
+		return "", nil, fmt.Errorf("terraform %v found on path does not satisfy %s, install a matching version with 'rover terraform install'", tfVer, versionConstraint)
 	}
 
 	path, err = filepath.Abs(path)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return path, nil
+	return path, tfVer, nil
 }
 
 // CheckVersion ensures that Terraform is at the required version
-func CheckVersion(path string) error {
+func CheckVersion(path string) (*version.Version, error) {
 	// Working dir shouldn't matter for this command
 	tf, err := tfexec.NewTerraform(".", path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	tfVer, _, err := tf.Version(context.Background(), false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !tfVer.GreaterThanOrEqual(requiredMinVer) {
-		return fmt.Errorf("Terrform version %v is behind required minimum %v", tfVer, requiredMinVer)
+		return nil, fmt.Errorf("Terrform version %v is behind required minimum %v", tfVer, requiredMinVer)
 	}
 	console.Successf("Terraform is at version %v\n", tfVer)
-	return nil
+	return tfVer, nil
 }
 
 // ExpandVarDirectory returns an array of var file options from a directory of tfvars
 func ExpandVarDirectory(varDir string) ([]*tfexec.VarFileOption, error) {
+	varFiles, err := FindVarFiles(varDir)
+	if err != nil {
+		return nil, err
+	}
+
 	varFileOpts := []*tfexec.VarFileOption{}
+	for _, varFileName := range varFiles {
+		varFileOpts = append(varFileOpts, tfexec.VarFile(varFileName))
+	}
+	return varFileOpts, nil
+}
+
+// FindVarFiles returns the paths of all the tfvars files in a directory
+func FindVarFiles(varDir string) ([]string, error) {
+	varFiles := []string{}
 
 	// Finds all .tfvars in directory, note. we no longer use walk as it was recursive
 	tfvarFiles, err := os.ReadDir(varDir)
@@ -76,14 +127,79 @@ func ExpandVarDirectory(varDir string) ([]*tfexec.VarFileOption, error) {
 			continue
 		}
 		varFileName := filepath.Join(varDir, file.Name())
-		varFileOpts = append(varFileOpts, tfexec.VarFile(varFileName))
+		varFiles = append(varFiles, varFileName)
 		console.Debugf("Found tfvar file to use: %s\n", varFileName)
 	}
 
 	// Ensure we have some tfvars, otherwise we're going to have a really bad time
-	if len(varFileOpts) <= 0 {
+	if len(varFiles) <= 0 {
 		return nil, fmt.Errorf("failed to find any tfvars files in config directory: %s", varDir)
 	}
 
-	return varFileOpts, nil
+	return varFiles, nil
+}
+
+// Run calls the terraform binary directly, for commands and flags that tfexec doesn't support
+// It runs in the working dir of tf with the current environment. The exit code is returned,
+// the error is only set if terraform could not be run at all
+// When ctx is done terraform is interrupted so it can release state locks, it's only killed after KillGracePeriod
+func Run(ctx context.Context, tf *tfexec.Terraform, stdout io.Writer, stderr io.Writer, args ...string) (int, error) {
+	cmd := exec.Command(tf.ExecPath(), args...)
+	cmd.Dir = tf.WorkingDir()
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=1", "TF_INPUT=0")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcAttr(cmd)
+
+	return runCmd(ctx, cmd, args)
+}
+
+// Passthrough calls the terraform binary directly, attached to the stdin, stdout & stderr of rover so it can be interactive
+// It runs in the working dir of tf with the current environment. Terraform shares the process group with rover,
+// so it gets Ctrl-C from the terminal directly. The exit code and ctx are handled as with Run
+func Passthrough(ctx context.Context, tf *tfexec.Terraform, args ...string) (int, error) {
+	cmd := exec.Command(tf.ExecPath(), args...)
+	cmd.Dir = tf.WorkingDir()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return runCmd(ctx, cmd, args)
+}
+
+func runCmd(ctx context.Context, cmd *exec.Cmd, args []string) (int, error) {
+	console.Debugf("Running terraform %s\n", strings.Join(args, " "))
+	err := cmd.Start()
+	if err != nil {
+		return -1, err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+		err := interruptCmd(cmd)
+		if err != nil {
+			console.Errorf("Unable to interrupt terraform: %s\n", err)
+		}
+		select {
+		case <-exited:
+		case <-time.After(KillGracePeriod):
+			console.Errorf("Terraform did not stop within %s, forcing it to stop, state may be left locked\n", KillGracePeriod)
+			_ = killCmd(cmd)
+		}
+	}()
+
+	err = cmd.Wait()
+	close(exited)
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
 }
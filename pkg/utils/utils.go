@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/aztfmod/rover/pkg/console"
@@ -99,6 +100,54 @@ func ReadYamlFile(filePath string) ([]byte, string, error) {
 
 }
 
+// EditDistance returns the Levenshtein distance between two strings
+func EditDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j] + 1
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(br)]
+}
+
+// ClosestMatches returns the candidates that are within maxDistance edits of target, closest first
+func ClosestMatches(target string, candidates []string, maxDistance int) []string {
+	distances := map[string]int{}
+	matches := []string{}
+	for _, candidate := range candidates {
+		dist := EditDistance(strings.ToLower(target), strings.ToLower(candidate))
+		if dist <= maxDistance {
+			distances[candidate] = dist
+			matches = append(matches, candidate)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return distances[matches[i]] < distances[matches[j]]
+	})
+	return matches
+}
+
 var CurrentCustomCommandsAndGroupsYamlFilePath = ""
 
 var SymphonyYamlFilePath = os.Getenv("HOME") + "/.rover/symphony.yaml"
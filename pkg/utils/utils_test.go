@@ -78,3 +78,24 @@ func Test_Read_Not_Exist_File(t *testing.T) {
 	assert.Nil(t, fileContent)
 	assert.Equal(t, "commands.eng", fileName)
 }
+
+func Test_Edit_Distance(t *testing.T) {
+
+	// Assert
+	assert.Equal(t, 0, EditDistance("tfstate", "tfstate"))
+	assert.Equal(t, 1, EditDistance("tfstate", "tfstat"))
+	assert.Equal(t, 2, EditDistance("tfsatte", "tfstate"))
+	assert.Equal(t, 7, EditDistance("", "tfstate"))
+}
+
+func Test_Closest_Matches(t *testing.T) {
+
+	// Arrange
+	candidates := []string{"level0", "tfstate", "tfstate-dev", "tfstates"}
+
+	// Act
+	matches := ClosestMatches("tfstat", candidates, 2)
+
+	// Assert
+	assert.Equal(t, []string{"tfstate", "tfstates"}, matches)
+}
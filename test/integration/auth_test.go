@@ -1,3 +1,4 @@
+//go:build integration && !unit
 // +build integration,!unit
 
 package test
@@ -114,7 +115,7 @@ package test
 // 	optionsList := landingzone.BuildOptions(testCmd)
 
 // 	action := actions.ActionMap[testCmd.Name()]
-// 	_ = action.Execute(&optionsList[0])
+// 	_ = action.Execute(context.Background(), &optionsList[0])
 
 // 	assert.Equal(t, "servicePrincipal", optionsList[0].Identity.ObjectType)
 // 	assert.Equal(t, "UserAssigned", optionsList[0].Identity.DisplayName)
@@ -181,7 +182,7 @@ package test
 // 	optionsList := landingzone.BuildOptions(testCmd)
 
 // 	action := actions.ActionMap[testCmd.Name()]
-// 	err = action.Execute(&optionsList[0])
+// 	err = action.Execute(context.Background(), &optionsList[0])
 
 // 	assert.NoError(t, err)
 
@@ -219,7 +220,7 @@ package test
 
 // 	os.Setenv("ARM_CLIENT_SECRET", "A")
 // 	action := actions.ActionMap[testCmd.Name()]
-// 	err = action.Execute(&optionsList[0])
+// 	err = action.Execute(context.Background(), &optionsList[0])
 
 // 	assert.NoError(t, err)
 
@@ -4,6 +4,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
@@ -51,13 +52,13 @@ func Test_Execute_Group_Deploy_Command(t *testing.T) {
 	deployOptions.Flags().Bool("launchpad", true, "")
 	optionsList := landingzone.BuildOptions(deployOptions)
 
-	err = deployAction.Execute(&optionsList[0])
+	err = deployAction.Execute(context.Background(), &optionsList[0])
 
 	//assert
 	assert.Equal(t, nil, err)
 
 	t.Cleanup(func() {
-		actions.ActionMap["destroy"].Execute(&optionsList[0])
+		actions.ActionMap["destroy"].Execute(context.Background(), &optionsList[0])
 
 		removeCommandYamlFromHomeDir(roverHome)
 	})
@@ -44,7 +44,7 @@ package test
 // 	testAction := actions.ActionMap["test"]
 
 // 	//assert
-// 	assert.Equal(t, nil, testAction.Execute(&optionsList[0]))
+// 	assert.Equal(t, nil, testAction.Execute(context.Background(), &optionsList[0]))
 
 // 	custom.copyCommandYamlToRoverHome(roverHome, "valid_group.yml", "commands.yml")
 // 	console.DebugEnabled = true